@@ -0,0 +1,4 @@
+// Package ledgerpb holds the protobuf messages and the gRPC client and server interfaces of the ledger API.
+package ledgerpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ledger.proto
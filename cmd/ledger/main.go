@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/api"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/checkpoint"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/config"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fix"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/ingestion"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/report"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/repository"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/rpc"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/shell"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/watch"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const usage = `usage: ledger [-config-dir dir] [-env name] <command> [args]
+
+commands:
+  process [-format text|csv|jsonl] [-resume-line n] [-resume-offset n] [-progress n] <file>
+                   match the orders in file and print the resulting trades, the format is taken
+                   from the configuration, the file extension or its first line. The file is
+                   streamed, progress is logged every n lines and an interrupted run can be
+                   resumed after the last line and byte offset it logged
+  watch [-archive dir] [-checkpoints file] [-interval d] [-idle d] <dir>
+                   process the order files dropped into dir as lines are written to them, and
+                   archive them once unchanged for the idle period. Every line is processed once
+                   across restarts with the file storage backend
+  tail [-checkpoints file] [-interval d] <file>
+                   process the lines appended to an order file as they are written
+  serve            serve the HTTP API, and the gRPC API and FIX gateway if configured, on the
+                   configured addresses
+  shell [-memory | -store file]
+                   read orders and commands interactively and print matches as they happen,
+                   against the configured storage, an in-memory ledger or an event store file
+  book <product> [-version n | -at time]
+                   print the book of a product, or rebuild it as it was after an event version
+                   or at an RFC 3339 time
+  fees <participant> [-month YYYY-MM]
+                   print the fee statement of a participant for a month, the current one by default
+  report [-date YYYY-MM-DD] [-format csv|json] [-out dir]
+                   build the end of day trade blotter, open orders and positions of a UTC day,
+                   today by default, from the event store
+  trial-balance    print the settlement accounts with their balances and check that they balance
+  rebuild [name]   replay the event store into the named projection, or into all of them
+  verify [-checkpoint file -public-key file]
+                   walk the hash chains of the file store and report the first broken link, and
+                   check a signed checkpoint against it
+  checkpoint -key file [-out file]
+                   export a checkpoint of the file store signed with an ed25519 key
+  keygen <private-key-file> <public-key-file>
+                   generate an ed25519 key pair for signing checkpoints
+`
+
+func main() {
+	flags := flag.NewFlagSet("ledger", flag.ExitOnError)
+	configDir := flags.String("config-dir", "configs", "directory holding default.yaml and environment overrides")
+	env := flags.String("env", os.Getenv(config.EnvironmentVariable), "environment whose overrides are layered on the defaults")
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	_ = flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configDir, *env)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	logger, err := logging.New(cfg.Logging.Level, cfg.Logging.Format, os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	switch flags.Arg(0) {
+	case "verify":
+		err = verify(cfg, flags.Args()[1:])
+	case "checkpoint":
+		err = exportCheckpoint(cfg, flags.Args()[1:])
+	case "shell":
+		err = runShell(cfg, flags.Args()[1:])
+	case "keygen":
+		if flags.NArg() != 3 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		err = checkpoint.GenerateKey(flags.Arg(1), flags.Arg(2))
+	default:
+		err = run(cfg, logger, flags)
+	}
+
+	if err != nil {
+		logger.Error("ledger failed", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, logger *slog.Logger, flags *flag.FlagSet) error {
+	ledger, err := app.New(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	switch flags.Arg(0) {
+	case "process":
+		err = process(ledger, cfg.Ingestion, logger, flags.Args()[1:])
+	case "watch", "tail":
+		err = watchOrders(ledger, cfg.Ingestion, logger, flags.Arg(0), flags.Args()[1:])
+	case "serve":
+		err = serve(ledger, cfg, logger)
+	case "rebuild":
+		err = rebuild(ledger, flags.Arg(1))
+	case "trial-balance":
+		err = trialBalance(ledger)
+	case "report":
+		err = endOfDayReport(ledger, flags.Args()[1:])
+	case "fees":
+		if flags.NArg() < 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		err = feeStatement(ledger, flags.Arg(1), flags.Args()[2:])
+	case "book":
+		if flags.NArg() < 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		err = book(ledger, flags.Arg(1), flags.Args()[2:])
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	return err
+}
+
+func serve(ledger *app.App, cfg *config.Config, logger *slog.Logger) error {
+	errs := make(chan error, 3)
+
+	if cfg.Server.GRPCAddr != "" {
+		listener, err := net.Listen("tcp", cfg.Server.GRPCAddr)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("serving ledger gRPC API", slog.String("addr", cfg.Server.GRPCAddr))
+		go func() { errs <- rpc.NewServer(ledger).Serve(listener) }()
+	}
+
+	if cfg.FIX.Addr != "" {
+		store, err := fix.LoadStore(cfg.FIX.Store)
+		if err != nil {
+			return err
+		}
+
+		listener, err := net.Listen("tcp", cfg.FIX.Addr)
+		if err != nil {
+			return err
+		}
+
+		opts := []fix.Option{fix.WithLogger(logger)}
+		if cfg.FIX.Heartbeat > 0 {
+			opts = append(opts, fix.WithHeartbeat(cfg.FIX.Heartbeat))
+		}
+
+		logger.Info("serving ledger FIX gateway", slog.String("addr", cfg.FIX.Addr), slog.String("comp_id", cfg.FIX.CompID))
+		go func() { errs <- fix.New(ledger, store, cfg.FIX.CompID, opts...).Serve(context.Background(), listener) }()
+	}
+
+	logger.Info("serving ledger API", slog.String("addr", cfg.Server.HTTPAddr))
+	go func() { errs <- http.ListenAndServe(cfg.Server.HTTPAddr, api.NewHandler(ledger)) }()
+
+	return <-errs
+}
+
+func runShell(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("shell", flag.ExitOnError)
+	memory := flags.Bool("memory", false, "keep the events in memory only")
+	store := flags.String("store", "", "event store file to load and append to")
+	_ = flags.Parse(args)
+
+	switch {
+	case *memory:
+		cfg.Storage = config.Storage{Backend: config.MemoryBackend}
+	case *store != "":
+		cfg.Storage = config.Storage{Backend: config.FileBackend, Path: *store}
+	}
+
+	// info logs would interleave with the shell output
+	logger, err := logging.New("warn", cfg.Logging.Format, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	ledger, err := app.New(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	// events are stored as each command runs, so an interrupt needs no handling
+	fmt.Println(`ledger shell, type "help" for the commands`)
+	return shell.New(ledger, os.Stdout, shell.WithPrompt("ledger> ")).Run(context.Background(), os.Stdin)
+}
+
+func process(ledger *app.App, cfg config.Ingestion, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("process", flag.ExitOnError)
+	format := flags.String("format", cfg.Format, "input format, text, csv or jsonl")
+	resumeLine := flags.Int("resume-line", 0, "skip the input up to and including this line")
+	resumeOffset := flags.Int64("resume-offset", 0, "seek to this byte offset, just after the resume line")
+	every := flags.Int("progress", 100000, "log progress every n lines, 0 only at the end")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	path := flags.Arg(0)
+
+	opts := []ingestion.Option{
+		ingestion.WithColumns(cfg.Columns),
+		ingestion.WithResume(file_ops.Position{Line: *resumeLine, Offset: *resumeOffset}),
+	}
+	if *format != "" {
+		parsed, err := ingestion.ParseFormat(*format)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, ingestion.WithFormat(parsed))
+	} else if detected, ok := ingestion.FormatOf(path); ok {
+		opts = append(opts, ingestion.WithFormat(detected))
+	}
+
+	lines, err := file_ops.Open(path, file_ops.WithProgress(*every, func(p file_ops.Progress) {
+		logger.Info("reading orders", slog.String("file", path), slog.Int("line", p.Line), slog.Int64("offset", p.Offset), slog.String("percent", fmt.Sprintf("%.1f", p.Percent())))
+	}))
+	if err != nil {
+		return err
+	}
+	defer lines.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	position, err := ledger.Stream(ctx, lines, os.Stdout, opts...)
+	if ctx.Err() != nil {
+		logger.Warn("processing interrupted", slog.String("file", path), slog.Int("resume_line", position.Line), slog.Int64("resume_offset", position.Offset))
+	}
+	return err
+}
+
+func watchOrders(ledger *app.App, cfg config.Ingestion, logger *slog.Logger, mode string, args []string) error {
+	flags := flag.NewFlagSet(mode, flag.ExitOnError)
+	archive := flags.String("archive", "", "directory completed files are moved to, <dir>/archive by default")
+	checkpoints := flags.String("checkpoints", "", "file keeping how far each file was read, next to the watched files by default")
+	interval := flags.Duration("interval", time.Second, "how often to look for new lines")
+	idle := flags.Duration("idle", 30*time.Second, "how long a file must stay unchanged to be complete")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	path := flags.Arg(0)
+
+	if *checkpoints == "" {
+		*checkpoints = filepath.Join(path, ".checkpoints.json")
+		if mode == "tail" {
+			*checkpoints = path + ".checkpoints.json"
+		}
+	}
+	if *archive == "" {
+		*archive = filepath.Join(path, "archive")
+	}
+
+	saved, err := watch.LoadCheckpoints(*checkpoints)
+	if err != nil {
+		return err
+	}
+
+	w := watch.New(ledger, saved, os.Stdout,
+		watch.WithArchive(*archive),
+		watch.WithInterval(*interval),
+		watch.WithIdle(*idle),
+		watch.WithColumns(cfg.Columns),
+		watch.WithLogger(logger),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("watching for orders", slog.String("path", path), slog.String("checkpoints", *checkpoints))
+	if mode == "tail" {
+		return w.TailFile(ctx, path)
+	}
+	return w.WatchDir(ctx, path)
+}
+
+func rebuild(ledger *app.App, name string) error {
+	if err := ledger.Rebuild(name); err != nil {
+		return err
+	}
+
+	names := []string{name}
+	if name == "" {
+		names = ledger.Projections()
+	}
+	for _, n := range names {
+		fmt.Println("rebuilt", n)
+	}
+	return nil
+}
+
+func trialBalance(ledger *app.App) error {
+	balances, err := ledger.TrialBalance()
+	for _, b := range balances {
+		fmt.Printf("%-40s %14s %14s %14s\n", b.Account.String(), b.Debit.String(), b.Credit.String(), b.Net().String())
+	}
+	return err
+}
+
+func feeStatement(ledger *app.App, participant string, args []string) error {
+	flags := flag.NewFlagSet("fees", flag.ExitOnError)
+	month := flags.String("month", time.Now().UTC().Format("2006-01"), "month of the statement as YYYY-MM")
+	_ = flags.Parse(args)
+
+	m, err := time.Parse("2006-01", *month)
+	if err != nil {
+		return err
+	}
+
+	st := ledger.FeeStatement(participant, m)
+	fmt.Printf("%s fees for %s\n", st.Participant, st.Month.Format("January 2006"))
+	for _, l := range st.Lines {
+		fmt.Printf("%s %-8s %-10s %-5s %12s %10s\n", l.At.UTC().Format(time.RFC3339), l.Product, l.OrderId, l.Liquidity, l.Notional.String(), l.Fee.String())
+	}
+	fmt.Printf("maker %s, taker %s, total %s on %s traded\n", st.MakerFees.String(), st.TakerFees.String(), st.Total().String(), st.Notional.String())
+	return nil
+}
+
+func endOfDayReport(ledger *app.App, args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	date := flags.String("date", time.Now().UTC().Format(report.DateLayout), "UTC day to report on as YYYY-MM-DD")
+	format := flags.String("format", "json", "csv writes blotter, open orders and positions files, json one document")
+	out := flags.String("out", "", "directory to write the report to, stdout for json when empty")
+	_ = flags.Parse(args)
+
+	day, err := time.Parse(report.DateLayout, *date)
+	if err != nil {
+		return err
+	}
+
+	r, err := ledger.Report(day)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		if *out == "" {
+			return r.WriteJSON(os.Stdout)
+		}
+		return writeReportFile(filepath.Join(*out, r.Date+".json"), r.WriteJSON)
+	case "csv":
+		for _, file := range []struct {
+			name  string
+			write func(w io.Writer) error
+		}{{"blotter", r.WriteBlotterCSV}, {"open-orders", r.WriteOpenOrdersCSV}, {"positions", r.WritePositionsCSV}} {
+			if err = writeReportFile(filepath.Join(*out, r.Date+"-"+file.name+".csv"), file.write); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("report: unknown format %q", *format)
+	}
+}
+
+func writeReportFile(path string, write func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Println("wrote", path)
+	return nil
+}
+
+func loadJournal(cfg *config.Config) ([]event_sourcing.Record, error) {
+	if cfg.Storage.Backend != config.FileBackend {
+		return nil, fmt.Errorf("storage backend %q keeps nothing to verify, use %q", cfg.Storage.Backend, config.FileBackend)
+	}
+	return repository.NewFileJournal(cfg.Storage.Path).Load()
+}
+
+func verify(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	checkpointPath := flags.String("checkpoint", "", "signed checkpoint to check the store against")
+	publicKeyPath := flags.String("public-key", "", "public key the checkpoint was signed with")
+	_ = flags.Parse(args)
+
+	records, err := loadJournal(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err = event_sourcing.VerifyChain(records); err != nil {
+		return err
+	}
+
+	if *checkpointPath != "" {
+		c, err := checkpoint.Read(*checkpointPath)
+		if err != nil {
+			return err
+		}
+
+		if *publicKeyPath != "" {
+			key, err := checkpoint.LoadPublicKey(*publicKeyPath)
+			if err != nil {
+				return err
+			}
+			if err = c.VerifySignature(key); err != nil {
+				return err
+			}
+		}
+
+		if err = c.Match(records); err != nil {
+			return err
+		}
+		fmt.Printf("checkpoint at sequence %d matches\n", c.Sequence)
+	}
+
+	head := ""
+	if len(records) > 0 {
+		head = records[len(records)-1].Hash
+	}
+	fmt.Printf("ok: %d events, head %s\n", len(records), head)
+	return nil
+}
+
+func exportCheckpoint(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("checkpoint", flag.ExitOnError)
+	keyPath := flags.String("key", "", "ed25519 private key to sign the checkpoint with")
+	out := flags.String("out", "", "file to write the checkpoint to instead of stdout")
+	_ = flags.Parse(args)
+
+	if *keyPath == "" {
+		return errors.New("checkpoint: -key is required")
+	}
+
+	key, err := checkpoint.LoadPrivateKey(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	records, err := loadJournal(cfg)
+	if err != nil {
+		return err
+	}
+	if err = event_sourcing.VerifyChain(records); err != nil {
+		return err
+	}
+
+	c := checkpoint.New(records, time.Now())
+	if err = c.Sign(key); err != nil {
+		return err
+	}
+
+	if *out != "" {
+		return checkpoint.Write(*out, c)
+	}
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(b))
+	return err
+}
+
+func book(ledger *app.App, name string, args []string) error {
+	flags := flag.NewFlagSet("book", flag.ExitOnError)
+	version := flags.Int("version", -1, "stream version to rebuild the book at")
+	at := flags.String("at", "", "RFC 3339 time to rebuild the book at")
+	_ = flags.Parse(args)
+
+	var demands, supplies []*order.Order
+	var err error
+	switch {
+	case *at != "":
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return err
+		}
+
+		var v int
+		if demands, supplies, v, err = ledger.BookAtTime(name, t); err != nil {
+			return err
+		}
+		fmt.Printf("%s as of %s (version %d)\n", name, t.Format(time.RFC3339), v)
+	case *version >= 0:
+		if demands, supplies, err = ledger.BookAtVersion(name, *version); err != nil {
+			return err
+		}
+		fmt.Printf("%s as of version %d\n", name, *version)
+	default:
+		if demands, supplies, err = ledger.Book(name); err != nil {
+			return err
+		}
+		fmt.Println(name)
+	}
+
+	for _, o := range append(demands, supplies...) {
+		fmt.Printf("%-6s %-10s %s/kg %skg\n", strings.ToLower(o.OrderType), o.Id, o.Price.String(), o.Qty.String())
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type recordResponse struct {
+	Sequence uint64                 `json:"sequence"`
+	Stream   string                 `json:"stream"`
+	Version  int                    `json:"version"`
+	Time     string                 `json:"time"`
+	Event    map[string]interface{} `json:"event"`
+}
+
+func (h *handler) getEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	query := r.URL.Query()
+
+	after, err := strconv.ParseUint(queryOr(query.Get("after"), "0"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid after: " + err.Error()})
+		return
+	}
+
+	limit, err := strconv.Atoi(queryOr(query.Get("limit"), "0"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid limit: " + err.Error()})
+		return
+	}
+
+	records := h.ledger.Events(after, limit)
+
+	resp := make([]recordResponse, 0, len(records))
+	for _, rec := range records {
+		resp = append(resp, recordResponse{
+			Sequence: rec.Sequence,
+			Stream:   rec.Stream,
+			Version:  rec.Version,
+			Time:     rec.Time.UTC().Format(time.RFC3339Nano),
+			Event:    valueToMap(rec.Event.LogValue()),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func valueToMap(v slog.Value) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, attr := range v.Resolve().Group() {
+		switch attr.Value.Kind() {
+		case slog.KindGroup:
+			m[attr.Key] = valueToMap(attr.Value)
+		case slog.KindTime:
+			m[attr.Key] = attr.Value.Time().UTC().Format(time.RFC3339Nano)
+		default:
+			m[attr.Key] = attr.Value.Any()
+		}
+	}
+	return m
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+const monthLayout = "2006-01"
+
+type feeLineResponse struct {
+	TradeId   string `json:"trade_id"`
+	Product   string `json:"product"`
+	OrderId   string `json:"order_id"`
+	Liquidity string `json:"liquidity"`
+	Notional  string `json:"notional"`
+	Fee       string `json:"fee"`
+	At        string `json:"at"`
+}
+
+type feeStatementResponse struct {
+	Participant string            `json:"participant"`
+	Month       string            `json:"month"`
+	Notional    string            `json:"notional"`
+	MakerFees   string            `json:"maker_fees"`
+	TakerFees   string            `json:"taker_fees"`
+	Total       string            `json:"total"`
+	Lines       []feeLineResponse `json:"lines"`
+}
+
+func (h *handler) getFeeStatement(w http.ResponseWriter, r *http.Request, parts []string) {
+	month := time.Now().UTC()
+	if v := r.URL.Query().Get("month"); v != "" {
+		var err error
+		if month, err = time.Parse(monthLayout, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid month, expected YYYY-MM"})
+			return
+		}
+	}
+
+	st := h.ledger.FeeStatement(parts[1], month)
+
+	resp := feeStatementResponse{
+		Participant: st.Participant,
+		Month:       st.Month.Format(monthLayout),
+		Notional:    st.Notional.String(),
+		MakerFees:   st.MakerFees.String(),
+		TakerFees:   st.TakerFees.String(),
+		Total:       st.Total().String(),
+		Lines:       make([]feeLineResponse, 0, len(st.Lines)),
+	}
+	for _, l := range st.Lines {
+		resp.Lines = append(resp.Lines, feeLineResponse{
+			TradeId:   l.TradeId,
+			Product:   l.Product,
+			OrderId:   l.OrderId,
+			Liquidity: l.Liquidity,
+			Notional:  l.Notional.String(),
+			Fee:       l.Fee.String(),
+			At:        l.At.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/candles"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type orderRequest struct {
+	Id             string  `json:"id"`
+	Participant    string  `json:"participant"`
+	Product        string  `json:"product"`
+	Side           string  `json:"side"`
+	Price          float64 `json:"price"`
+	Qty            float64 `json:"qty"`
+	IdempotencyKey string  `json:"idempotency_key"`
+	Peak           float64 `json:"peak"`
+	StopPrice      float64 `json:"stop_price"`
+	MinQty         float64 `json:"min_qty"`
+	AllOrNone      bool    `json:"all_or_none"`
+}
+
+type orderResponse struct {
+	Id          string `json:"id"`
+	Participant string `json:"participant,omitempty"`
+	Price       string `json:"price"`
+	Qty         string `json:"qty"`
+}
+
+type tradeResponse struct {
+	Product string        `json:"product"`
+	Demand  orderResponse `json:"demand"`
+	Supply  orderResponse `json:"supply"`
+}
+
+type bookResponse struct {
+	Product  string          `json:"product"`
+	Version  *int            `json:"version,omitempty"`
+	AsOf     string          `json:"as_of,omitempty"`
+	Demands  []orderResponse `json:"demands"`
+	Supplies []orderResponse `json:"supplies"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type handler struct {
+	ledger *app.App
+}
+
+// NewHandler exposes the ledger over HTTP:
+//
+//	POST   /orders                      submit a supply or demand order, a retry with the same Idempotency-Key
+//	                                    header or idempotency_key returns the trades of the original order, a
+//	                                    peak makes an iceberg order showing only that much of its qty, a
+//	                                    stop_price a stop order, which becomes a market order without a price,
+//	                                    min_qty and all_or_none refuse to fill less than a quantity or the
+//	                                    whole qty at once
+//	GET    /orders/{id}                 status of an order, also once it left its book, with its fills
+//	GET    /products/{name}/book        inspect the resting orders of a product, ?version=<n> or ?at=<RFC 3339>
+//	                                    rebuild the book as it was after an event or at a point in time
+//	GET    /products/{name}/candles     OHLCV candles, ?interval=1m|5m|1h|1d&from=&to= (RFC 3339)&format=json|csv
+//	DELETE /products/{name}/orders/{id} cancel a resting order, ?participant=<id> of the participant owning it
+//	GET    /participants/{id}/orders    open orders of a participant across products
+//	GET    /participants/{id}/accounts  settlement balances of a participant, inventory per product and cash
+//	GET    /participants/{id}/journal   settlement journal entries that touch a participant
+//	GET    /participants/{id}/balances  inventory and cash of a participant, reserved and available
+//	GET    /participants/{id}/fees      monthly fee statement of a participant, ?month=YYYY-MM
+//	GET    /trades                      trade history, ?product=&participant=
+//	GET    /settlement/trial-balance    balances of every settlement account and whether the journal balances
+//	GET    /events                      the global event log, ?after=<sequence>&limit=
+//	GET    /projections                 registered projections
+//	POST   /projections/{name}/rebuild  replay the event store into a projection from zero
+//	GET    /metrics                     metrics in the Prometheus text format
+//	GET    /healthz                     liveness probe
+func NewHandler(ledger *app.App) http.Handler {
+	h := &handler{ledger: ledger}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", h.submitOrder)
+	mux.HandleFunc("/orders/", h.getOrder)
+	mux.HandleFunc("/products/", h.routeProduct)
+	mux.HandleFunc("/participants/", h.routeParticipant)
+	mux.HandleFunc("/trades", h.getTrades)
+	mux.HandleFunc("/settlement/trial-balance", h.getTrialBalance)
+	mux.HandleFunc("/events", h.getEvents)
+	mux.HandleFunc("/projections", h.listProjections)
+	mux.HandleFunc("/projections/", h.routeProjection)
+	mux.Handle("/metrics", ledger.MetricsHandler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return mux
+}
+
+func (h *handler) submitOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	var req orderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+
+	trades, err := h.ledger.Submit(app.OrderCommand{
+		Id:             req.Id,
+		Participant:    req.Participant,
+		Product:        req.Product,
+		Side:           strings.ToUpper(req.Side),
+		Price:          req.Price,
+		Qty:            req.Qty,
+		IdempotencyKey: req.IdempotencyKey,
+		Peak:           req.Peak,
+		StopPrice:      req.StopPrice,
+		MinQty:         req.MinQty,
+		AllOrNone:      req.AllOrNone,
+	})
+	if err != nil && err.Error() == constants.IdempotencyKeyReusedErrorMessage {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := make([]tradeResponse, 0, len(trades))
+	for _, t := range trades {
+		resp = append(resp, tradeResponse{Product: t.Product, Demand: toOrderResponse(t.Demand), Supply: toOrderResponse(t.Supply)})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) routeProduct(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 3 && parts[2] == "book" && r.Method == http.MethodGet:
+		h.getBook(w, r, parts)
+	case len(parts) == 3 && parts[2] == "candles" && r.Method == http.MethodGet:
+		h.getCandles(w, r, parts)
+	case len(parts) == 4 && parts[2] == "orders" && r.Method == http.MethodDelete:
+		h.cancelOrder(w, r, parts)
+	case (len(parts) == 3 && (parts[2] == "book" || parts[2] == "candles")) || (len(parts) == 4 && parts[2] == "orders"):
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+	default:
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+	}
+}
+
+func (h *handler) cancelOrder(w http.ResponseWriter, r *http.Request, parts []string) {
+	if err := h.ledger.Cancel(parts[1], r.URL.Query().Get("participant"), parts[3]); err != nil {
+		status := http.StatusUnprocessableEntity
+		switch err.Error() {
+		case constants.OrderNotFoundErrorMessage:
+			status = http.StatusNotFound
+		case constants.OrderNotOwnedErrorMessage:
+			status = http.StatusForbidden
+		}
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) getBook(w http.ResponseWriter, r *http.Request, parts []string) {
+	query := r.URL.Query()
+	if query.Get("version") != "" || query.Get("at") != "" {
+		h.getHistoricalBook(w, query, parts[1])
+		return
+	}
+
+	demands, supplies, err := h.ledger.Book(parts[1])
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, bookResponse{Product: parts[1], Demands: toOrderResponses(demands), Supplies: toOrderResponses(supplies)})
+}
+
+func (h *handler) getHistoricalBook(w http.ResponseWriter, query url.Values, name string) {
+	resp := bookResponse{Product: name}
+
+	var demands, supplies []*order.Order
+	if v := query.Get("at"); v != "" {
+		at, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid at: " + err.Error()})
+			return
+		}
+
+		var version int
+		if demands, supplies, version, err = h.ledger.BookAtTime(name, at); err != nil {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+			return
+		}
+		resp.Version, resp.AsOf = &version, at.UTC().Format(time.RFC3339Nano)
+	} else {
+		version, err := strconv.Atoi(query.Get("version"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid version: " + err.Error()})
+			return
+		}
+
+		if demands, supplies, err = h.ledger.BookAtVersion(name, version); err != nil {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+			return
+		}
+		resp.Version = &version
+	}
+
+	resp.Demands, resp.Supplies = toOrderResponses(demands), toOrderResponses(supplies)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type candleResponse struct {
+	Start  string `json:"start"`
+	End    string `json:"end"`
+	Open   string `json:"open"`
+	High   string `json:"high"`
+	Low    string `json:"low"`
+	Close  string `json:"close"`
+	Volume string `json:"volume"`
+	VWAP   string `json:"vwap"`
+	Trades int    `json:"trades"`
+}
+
+func (h *handler) getCandles(w http.ResponseWriter, r *http.Request, parts []string) {
+	query := r.URL.Query()
+
+	interval, err := candles.ParseInterval(queryOr(query.Get("interval"), "1m"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	from, to := time.Time{}, time.Now().Add(interval)
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from: " + err.Error()})
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid to: " + err.Error()})
+			return
+		}
+	}
+
+	result := h.ledger.Candles(parts[1], interval, from, to)
+
+	if query.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		_ = candles.WriteCSV(w, result)
+		return
+	}
+
+	resp := make([]candleResponse, 0, len(result))
+	for _, c := range result {
+		resp = append(resp, candleResponse{
+			Start:  c.Start.UTC().Format(time.RFC3339),
+			End:    c.End().UTC().Format(time.RFC3339),
+			Open:   c.Open.String(),
+			High:   c.High.String(),
+			Low:    c.Low.String(),
+			Close:  c.Close.String(),
+			Volume: c.Volume.String(),
+			VWAP:   c.VWAP().StringFixed(4),
+			Trades: c.Trades,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func queryOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func toOrderResponses(orders []*order.Order) []orderResponse {
+	resp := make([]orderResponse, 0, len(orders))
+	for _, o := range orders {
+		resp = append(resp, toOrderResponse(o))
+	}
+	return resp
+}
+
+func toOrderResponse(o *order.Order) orderResponse {
+	return orderResponse{Id: o.Id, Participant: o.Participant, Price: o.Price.String(), Qty: o.Qty.String()}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
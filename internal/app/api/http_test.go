@@ -0,0 +1,421 @@
+package api_test
+
+import (
+	"encoding/json"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/api"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/config"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fees"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newServer(t *testing.T, configure ...func(cfg *config.Config)) *httptest.Server {
+	cfg, err := config.Load("../../../configs", "")
+	require.NoError(t, err)
+	for _, c := range configure {
+		c(cfg)
+	}
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	server := httptest.NewServer(api.NewHandler(ledger))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func submit(t *testing.T, server *httptest.Server, body string) *http.Response {
+	resp, err := http.Post(server.URL+"/orders", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestHandler_SubmitsOrdersAndReturnsTrades(t *testing.T) {
+	server := newServer(t)
+
+	resp := submit(t, server, `{"id":"s1","participant":"grower-1","product":"tomato","side":"supply","price":20,"qty":90}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = submit(t, server, `{"id":"d1","participant":"buyer-1","product":"tomato","side":"demand","price":22,"qty":100}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var trades []struct {
+		Product string            `json:"product"`
+		Demand  map[string]string `json:"demand"`
+		Supply  map[string]string `json:"supply"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&trades))
+	require.Len(t, trades, 1)
+	assert.Equal(t, "tomato", trades[0].Product)
+	assert.Equal(t, "s1", trades[0].Supply["id"])
+	assert.Equal(t, "grower-1", trades[0].Supply["participant"])
+	assert.Equal(t, "d1", trades[0].Demand["id"])
+	assert.Equal(t, "90", trades[0].Supply["qty"])
+
+	bookResp, err := http.Get(server.URL + "/products/tomato/book")
+	require.NoError(t, err)
+	defer bookResp.Body.Close()
+
+	var book struct {
+		Demands  []map[string]string `json:"demands"`
+		Supplies []map[string]string `json:"supplies"`
+	}
+	require.NoError(t, json.NewDecoder(bookResp.Body).Decode(&book))
+	require.Len(t, book.Demands, 1)
+	assert.Equal(t, "10", book.Demands[0]["qty"])
+	assert.Empty(t, book.Supplies)
+}
+
+func TestHandler_RetriedOrdersAreNotPlacedTwice(t *testing.T) {
+	server := newServer(t)
+	submit(t, server, `{"id":"s1","participant":"grower-1","product":"tomato","side":"supply","price":20,"qty":90}`)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/orders", strings.NewReader(`{"participant":"buyer-1","product":"tomato","side":"demand","price":22,"qty":30}`))
+		require.NoError(t, err)
+		req.Header.Set("Idempotency-Key", "retry-1")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var trades []map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&trades))
+		require.Len(t, trades, 1, "a retry returns the trades of the original order")
+	}
+
+	resp := submit(t, server, `{"participant":"buyer-1","product":"tomato","side":"demand","price":22,"qty":40,"idempotency_key":"retry-1"}`)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	bookResp, err := http.Get(server.URL + "/products/tomato/book")
+	require.NoError(t, err)
+	defer bookResp.Body.Close()
+
+	var book struct {
+		Supplies []map[string]string `json:"supplies"`
+	}
+	require.NoError(t, json.NewDecoder(bookResp.Body).Decode(&book))
+	require.Len(t, book.Supplies, 1)
+	assert.Equal(t, "60", book.Supplies[0]["qty"])
+}
+
+func TestHandler_CancelsOrders(t *testing.T) {
+	server := newServer(t)
+	submit(t, server, `{"id":"s1","participant":"grower-1","product":"tomato","side":"supply","price":20,"qty":90}`)
+
+	req, err := http.NewRequest(http.MethodDelete, server.URL+"/products/tomato/orders/s1?participant=buyer-1", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	req, err = http.NewRequest(http.MethodDelete, server.URL+"/products/tomato/orders/s1?participant=grower-1", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestHandler_RejectsInvalidOrders(t *testing.T) {
+	server := newServer(t)
+
+	resp := submit(t, server, `{"id":"s1","product":"tomato","side":"supply","price":20.0001,"qty":90}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	resp = submit(t, server, `{"id":"s1","product":"onion","side":"supply","price":20,"qty":90}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestHandler_ServesOrderStatus(t *testing.T) {
+	server := newServer(t)
+	submit(t, server, `{"id":"s1","participant":"grower-1","product":"tomato","side":"supply","price":20,"qty":90}`)
+	submit(t, server, `{"id":"d1","participant":"buyer-1","product":"tomato","side":"demand","price":21,"qty":30}`)
+	submit(t, server, `{"id":"d2","participant":"buyer-1","product":"tomato","side":"demand","price":21.0001,"qty":30}`)
+
+	getOrder := func(id string) (int, map[string]interface{}) {
+		resp, err := http.Get(server.URL + "/orders/" + id)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	status, s1 := getOrder("s1")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "partially_filled", s1["status"])
+	assert.Equal(t, "90", s1["qty"])
+	assert.Equal(t, "30", s1["filled_qty"])
+	assert.Equal(t, "60", s1["leaves_qty"])
+	assert.Equal(t, "20", s1["avg_price"])
+	fills := s1["fills"].([]interface{})
+	require.Len(t, fills, 1)
+	assert.Equal(t, "d1", fills[0].(map[string]interface{})["counter_order_id"])
+
+	status, d1 := getOrder("d1")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "filled", d1["status"])
+
+	status, d2 := getOrder("d2")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "rejected", d2["status"])
+	assert.Equal(t, "price 21.0001 violates precision of 2 decimals", d2["reason"])
+
+	status, _ = getOrder("unknown")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestHandler_ExposesMetrics(t *testing.T) {
+	server := newServer(t)
+	submit(t, server, `{"id":"s1","product":"tomato","side":"supply","price":20,"qty":90}`)
+	submit(t, server, `{"id":"d1","product":"tomato","side":"demand","price":21,"qty":30}`)
+	submit(t, server, `{"id":"d2","product":"tomato","side":"demand","price":21.0001,"qty":30}`)
+
+	req, err := http.NewRequest(http.MethodDelete, server.URL+"/products/tomato/orders/s1", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	metricsResp, err := http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer metricsResp.Body.Close()
+
+	body, err := io.ReadAll(metricsResp.Body)
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		`ledger_orders_total{product="tomato",side="supply"} 1`,
+		`ledger_orders_total{product="tomato",side="demand"} 1`,
+		`ledger_trades_total{product="tomato"} 1`,
+		`ledger_rejections_total{product="tomato",reason="instrument"} 1`,
+		`ledger_cancels_total{product="tomato",side="supply"} 1`,
+		`ledger_matching_duration_seconds_count{product="tomato"} 2`,
+		`ledger_book_depth{product="tomato",side="supply"} 0`,
+		`ledger_resting_quantity{product="tomato",side="demand"} 0`,
+		`ledger_event_store_append_duration_seconds_count 4`,
+	} {
+		assert.Contains(t, string(body), line)
+	}
+}
+
+func TestHandler_ServesCandles(t *testing.T) {
+	server := newServer(t)
+	submit(t, server, `{"id":"s1","product":"tomato","side":"supply","price":20,"qty":90}`)
+	submit(t, server, `{"id":"d1","product":"tomato","side":"demand","price":22,"qty":100}`)
+
+	resp, err := http.Get(server.URL + "/products/tomato/candles?interval=1m")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var candles []map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&candles))
+	require.Len(t, candles, 1)
+	assert.Equal(t, "20", candles[0]["close"])
+	assert.Equal(t, "90", candles[0]["volume"])
+	assert.Equal(t, "20.0000", candles[0]["vwap"])
+
+	csvResp, err := http.Get(server.URL + "/products/tomato/candles?interval=5m&format=csv")
+	require.NoError(t, err)
+	defer csvResp.Body.Close()
+	body, err := io.ReadAll(csvResp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "text/csv", csvResp.Header.Get("Content-Type"))
+	assert.Len(t, strings.Split(strings.TrimSpace(string(body)), "\n"), 2)
+
+	badResp, err := http.Get(server.URL + "/products/tomato/candles?interval=7m")
+	require.NoError(t, err)
+	_ = badResp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, badResp.StatusCode)
+}
+
+func TestHandler_ServesReadModels(t *testing.T) {
+	server := newServer(t)
+	submit(t, server, `{"id":"s1","participant":"grower-1","product":"tomato","side":"supply","price":20,"qty":90}`)
+	submit(t, server, `{"id":"d1","participant":"buyer-1","product":"tomato","side":"demand","price":22,"qty":50}`)
+
+	ordersResp, err := http.Get(server.URL + "/participants/grower-1/orders")
+	require.NoError(t, err)
+	defer ordersResp.Body.Close()
+
+	var orders []map[string]string
+	require.NoError(t, json.NewDecoder(ordersResp.Body).Decode(&orders))
+	require.Len(t, orders, 1)
+	assert.Equal(t, "s1", orders[0]["id"])
+	assert.Equal(t, "supply", orders[0]["side"])
+	assert.Equal(t, "40", orders[0]["qty"])
+
+	tradesResp, err := http.Get(server.URL + "/trades?participant=buyer-1")
+	require.NoError(t, err)
+	defer tradesResp.Body.Close()
+
+	var trades []map[string]string
+	require.NoError(t, json.NewDecoder(tradesResp.Body).Decode(&trades))
+	require.Len(t, trades, 1)
+	assert.Equal(t, "s1", trades[0]["supply_order_id"])
+	assert.Equal(t, "50", trades[0]["qty"])
+
+	rebuildResp, err := http.Post(server.URL+"/projections/open_orders/rebuild", "application/json", nil)
+	require.NoError(t, err)
+	_ = rebuildResp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, rebuildResp.StatusCode)
+
+	unknownResp, err := http.Post(server.URL+"/projections/unknown/rebuild", "application/json", nil)
+	require.NoError(t, err)
+	_ = unknownResp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, unknownResp.StatusCode)
+}
+
+func TestHandler_ServesGlobalEventLog(t *testing.T) {
+	server := newServer(t)
+	submit(t, server, `{"id":"s1","product":"tomato","side":"supply","price":20,"qty":90}`)
+	submit(t, server, `{"id":"s2","product":"potato","side":"supply","price":10,"qty":50}`)
+	submit(t, server, `{"id":"d1","product":"tomato","side":"demand","price":22,"qty":10}`)
+
+	resp, err := http.Get(server.URL + "/events?after=1&limit=2")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var records []struct {
+		Sequence uint64            `json:"sequence"`
+		Version  int               `json:"version"`
+		Event    map[string]string `json:"event"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&records))
+	require.Len(t, records, 2)
+	assert.Equal(t, uint64(2), records[0].Sequence)
+	assert.Equal(t, "potato", records[0].Event["product"])
+	assert.Equal(t, 1, records[0].Version)
+	assert.Equal(t, uint64(3), records[1].Sequence)
+	assert.Equal(t, "d1", records[1].Event["order_id"])
+	assert.Equal(t, 2, records[1].Version)
+}
+
+func TestHandler_ServesHistoricalBook(t *testing.T) {
+	server := newServer(t)
+	submit(t, server, `{"id":"s1","product":"tomato","side":"supply","price":20,"qty":90}`)
+	submit(t, server, `{"id":"d1","product":"tomato","side":"demand","price":22,"qty":100}`)
+
+	resp, err := http.Get(server.URL + "/products/tomato/book?version=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var book struct {
+		Version  int                 `json:"version"`
+		Demands  []map[string]string `json:"demands"`
+		Supplies []map[string]string `json:"supplies"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&book))
+	assert.Equal(t, 1, book.Version)
+	assert.Empty(t, book.Demands)
+	require.Len(t, book.Supplies, 1)
+	assert.Equal(t, "s1", book.Supplies[0]["id"])
+	assert.Equal(t, "90", book.Supplies[0]["qty"])
+
+	for query, status := range map[string]int{"version=9": http.StatusNotFound, "version=x": http.StatusBadRequest, "at=yesterday": http.StatusBadRequest} {
+		resp, err := http.Get(server.URL + "/products/tomato/book?" + query)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode, query)
+	}
+}
+
+func TestHandler_ServesSettlementAccounts(t *testing.T) {
+	server := newServer(t)
+	submit(t, server, `{"id":"s1","participant":"grower-1","product":"tomato","side":"supply","price":20,"qty":90}`)
+	submit(t, server, `{"id":"d1","participant":"buyer-1","product":"tomato","side":"demand","price":22,"qty":10}`)
+
+	resp, err := http.Get(server.URL + "/settlement/trial-balance")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var trialBalance struct {
+		Balanced bool                `json:"balanced"`
+		Accounts []map[string]string `json:"accounts"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&trialBalance))
+	assert.True(t, trialBalance.Balanced)
+	require.Len(t, trialBalance.Accounts, 4)
+	assert.Equal(t, "cash:buyer-1", trialBalance.Accounts[0]["account"])
+	assert.Equal(t, "-200", trialBalance.Accounts[0]["balance"])
+
+	accountsResp, err := http.Get(server.URL + "/participants/grower-1/accounts")
+	require.NoError(t, err)
+	defer accountsResp.Body.Close()
+
+	var accounts []map[string]string
+	require.NoError(t, json.NewDecoder(accountsResp.Body).Decode(&accounts))
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "200", accounts[0]["balance"])
+	assert.Equal(t, "inventory:grower-1:tomato", accounts[1]["account"])
+	assert.Equal(t, "-10", accounts[1]["balance"])
+
+	journalResp, err := http.Get(server.URL + "/participants/buyer-1/journal")
+	require.NoError(t, err)
+	defer journalResp.Body.Close()
+
+	var journal []struct {
+		Lines []map[string]string `json:"lines"`
+	}
+	require.NoError(t, json.NewDecoder(journalResp.Body).Decode(&journal))
+	require.Len(t, journal, 1)
+	assert.Len(t, journal[0].Lines, 4)
+}
+
+func TestHandler_ServesFeeStatements(t *testing.T) {
+	server := newServer(t, func(cfg *config.Config) {
+		cfg.Fees.Schedule = &fees.Schedule{MakerRate: decimal.RequireFromString("0.001"), TakerRate: decimal.RequireFromString("0.002"), Minimum: decimal.NewFromInt(1)}
+	})
+	submit(t, server, `{"id":"s1","participant":"grower-1","product":"tomato","side":"supply","price":20,"qty":90}`)
+	submit(t, server, `{"id":"d1","participant":"buyer-1","product":"tomato","side":"demand","price":22,"qty":100}`)
+
+	resp, err := http.Get(server.URL + "/participants/buyer-1/fees")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var statement struct {
+		TakerFees string              `json:"taker_fees"`
+		Total     string              `json:"total"`
+		Lines     []map[string]string `json:"lines"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&statement))
+	assert.Equal(t, "3.6", statement.TakerFees)
+	assert.Equal(t, "3.6", statement.Total)
+	require.Len(t, statement.Lines, 1)
+	assert.Equal(t, "taker", statement.Lines[0]["liquidity"])
+
+	tradesResp, err := http.Get(server.URL + "/trades")
+	require.NoError(t, err)
+	defer tradesResp.Body.Close()
+
+	var trades []map[string]string
+	require.NoError(t, json.NewDecoder(tradesResp.Body).Decode(&trades))
+	require.Len(t, trades, 1)
+	assert.Equal(t, "1.8", trades[0]["supply_fee"])
+	assert.Equal(t, "maker", trades[0]["supply_liquidity"])
+
+	resp, err = http.Get(server.URL + "/participants/buyer-1/fees?month=2023-13")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
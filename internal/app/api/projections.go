@@ -0,0 +1,191 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+type openOrderResponse struct {
+	Product string `json:"product"`
+	Side    string `json:"side"`
+	orderResponse
+	PlacedAt string `json:"placed_at"`
+}
+
+type orderStatusResponse struct {
+	Id          string         `json:"id"`
+	Product     string         `json:"product"`
+	Participant string         `json:"participant,omitempty"`
+	Side        string         `json:"side"`
+	Price       string         `json:"price"`
+	Qty         string         `json:"qty"`
+	Status      string         `json:"status"`
+	Reason      string         `json:"reason,omitempty"`
+	FilledQty   string         `json:"filled_qty"`
+	LeavesQty   string         `json:"leaves_qty"`
+	AvgPrice    string         `json:"avg_price"`
+	PlacedAt    string         `json:"placed_at"`
+	UpdatedAt   string         `json:"updated_at"`
+	Fills       []fillResponse `json:"fills"`
+}
+
+type fillResponse struct {
+	TradeId        string `json:"trade_id"`
+	CounterOrderId string `json:"counter_order_id"`
+	Price          string `json:"price"`
+	Qty            string `json:"qty"`
+	Liquidity      string `json:"liquidity,omitempty"`
+	ExecutedAt     string `json:"executed_at"`
+}
+
+type tradeHistoryResponse struct {
+	Id                string `json:"id"`
+	Product           string `json:"product"`
+	SupplyOrderId     string `json:"supply_order_id"`
+	SupplyParticipant string `json:"supply_participant,omitempty"`
+	DemandOrderId     string `json:"demand_order_id"`
+	DemandParticipant string `json:"demand_participant,omitempty"`
+	Price             string `json:"price"`
+	Qty               string `json:"qty"`
+	SupplyFee         string `json:"supply_fee,omitempty"`
+	SupplyLiquidity   string `json:"supply_liquidity,omitempty"`
+	DemandFee         string `json:"demand_fee,omitempty"`
+	DemandLiquidity   string `json:"demand_liquidity,omitempty"`
+	ExecutedAt        string `json:"executed_at"`
+}
+
+func (h *handler) routeParticipant(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 3 && parts[2] == "orders" && r.Method == http.MethodGet:
+		h.getOpenOrders(w, parts)
+	case len(parts) == 3 && parts[2] == "accounts" && r.Method == http.MethodGet:
+		h.getAccounts(w, parts)
+	case len(parts) == 3 && parts[2] == "journal" && r.Method == http.MethodGet:
+		h.getJournal(w, parts)
+	case len(parts) == 3 && parts[2] == "balances" && r.Method == http.MethodGet:
+		h.getHoldings(w, parts)
+	case len(parts) == 3 && parts[2] == "fees" && r.Method == http.MethodGet:
+		h.getFeeStatement(w, r, parts)
+	case len(parts) == 3 && (parts[2] == "orders" || parts[2] == "accounts" || parts[2] == "journal" || parts[2] == "balances" || parts[2] == "fees"):
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+	default:
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+	}
+}
+
+func (h *handler) getOpenOrders(w http.ResponseWriter, parts []string) {
+	orders := h.ledger.OpenOrders(parts[1])
+
+	resp := make([]openOrderResponse, 0, len(orders))
+	for _, o := range orders {
+		resp = append(resp, openOrderResponse{
+			Product:       o.Product,
+			Side:          strings.ToLower(o.OrderType),
+			orderResponse: toOrderResponse(&o.Order),
+			PlacedAt:      time.Unix(0, o.Timestamp).UTC().Format(time.RFC3339Nano),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) getOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/orders/")
+	o, ok := h.ledger.Order(id)
+	if id == "" || strings.Contains(id, "/") || !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+		return
+	}
+
+	resp := orderStatusResponse{
+		Id:          o.Id,
+		Product:     o.Product,
+		Participant: o.Participant,
+		Side:        strings.ToLower(o.OrderType),
+		Price:       o.Price.String(),
+		Qty:         o.Qty.String(),
+		Status:      o.Status,
+		Reason:      o.Reason,
+		FilledQty:   o.FilledQty.String(),
+		LeavesQty:   o.LeavesQty.String(),
+		AvgPrice:    o.AvgPrice().String(),
+		PlacedAt:    time.Unix(0, o.Timestamp).UTC().Format(time.RFC3339Nano),
+		UpdatedAt:   o.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		Fills:       make([]fillResponse, 0, len(o.Fills)),
+	}
+	for _, f := range o.Fills {
+		resp.Fills = append(resp.Fills, fillResponse{
+			TradeId:        f.TradeId,
+			CounterOrderId: f.CounterOrderId,
+			Price:          f.Price.String(),
+			Qty:            f.Qty.String(),
+			Liquidity:      f.Liquidity,
+			ExecutedAt:     f.Timestamp.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) getTrades(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	query := r.URL.Query()
+	trades := h.ledger.Trades(query.Get("product"), query.Get("participant"))
+
+	resp := make([]tradeHistoryResponse, 0, len(trades))
+	for _, t := range trades {
+		th := tradeHistoryResponse{
+			Id:                t.Id,
+			Product:           t.Product,
+			SupplyOrderId:     t.SupplyOrderId,
+			SupplyParticipant: t.SupplyParticipant,
+			DemandOrderId:     t.DemandOrderId,
+			DemandParticipant: t.DemandParticipant,
+			Price:             t.Price.String(),
+			Qty:               t.Qty.String(),
+			ExecutedAt:        t.Timestamp.UTC().Format(time.RFC3339Nano),
+		}
+		if t.SupplyFee.Liquidity != "" {
+			th.SupplyFee, th.SupplyLiquidity = t.SupplyFee.Amount.String(), t.SupplyFee.Liquidity
+			th.DemandFee, th.DemandLiquidity = t.DemandFee.Amount.String(), t.DemandFee.Liquidity
+		}
+		resp = append(resp, th)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) listProjections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.ledger.Projections())
+}
+
+func (h *handler) routeProjection(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 3 && parts[2] == "rebuild" && r.Method == http.MethodPost:
+		if err := h.ledger.Rebuild(parts[1]); err != nil {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 3 && parts[2] == "rebuild":
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+	default:
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/settlement"
+	"net/http"
+	"time"
+)
+
+type balanceResponse struct {
+	Account     string `json:"account"`
+	Participant string `json:"participant"`
+	Asset       string `json:"asset"`
+	Debit       string `json:"debit"`
+	Credit      string `json:"credit"`
+	Balance     string `json:"balance"`
+}
+
+type holdingResponse struct {
+	Asset     string `json:"asset"`
+	Total     string `json:"total"`
+	Reserved  string `json:"reserved"`
+	Available string `json:"available"`
+}
+
+type trialBalanceResponse struct {
+	Balanced bool              `json:"balanced"`
+	Error    string            `json:"error,omitempty"`
+	Accounts []balanceResponse `json:"accounts"`
+}
+
+type journalLineResponse struct {
+	Account string `json:"account"`
+	Amount  string `json:"amount"`
+}
+
+type journalEntryResponse struct {
+	Id          string                `json:"id"`
+	Sequence    uint64                `json:"sequence"`
+	PostedAt    string                `json:"posted_at"`
+	Description string                `json:"description"`
+	Lines       []journalLineResponse `json:"lines"`
+}
+
+func (h *handler) getTrialBalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	balances, err := h.ledger.TrialBalance()
+
+	resp := trialBalanceResponse{Balanced: err == nil, Accounts: toBalanceResponses(balances)}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) getAccounts(w http.ResponseWriter, parts []string) {
+	writeJSON(w, http.StatusOK, toBalanceResponses(h.ledger.Accounts(parts[1])))
+}
+
+func (h *handler) getHoldings(w http.ResponseWriter, parts []string) {
+	holdings := h.ledger.Holdings(parts[1])
+
+	resp := make([]holdingResponse, 0, len(holdings))
+	for _, hd := range holdings {
+		resp = append(resp, holdingResponse{
+			Asset:     hd.Account.Asset,
+			Total:     hd.Total.String(),
+			Reserved:  hd.Reserved.String(),
+			Available: hd.Available().String(),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) getJournal(w http.ResponseWriter, parts []string) {
+	entries := h.ledger.JournalEntries(parts[1])
+
+	resp := make([]journalEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		lines := make([]journalLineResponse, 0, len(e.Lines))
+		for _, l := range e.Lines {
+			lines = append(lines, journalLineResponse{Account: l.Account.String(), Amount: l.Amount.String()})
+		}
+
+		resp = append(resp, journalEntryResponse{
+			Id:          e.Id,
+			Sequence:    e.Sequence,
+			PostedAt:    e.Time.UTC().Format(time.RFC3339Nano),
+			Description: e.Description,
+			Lines:       lines,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func toBalanceResponses(balances []settlement.Balance) []balanceResponse {
+	resp := make([]balanceResponse, 0, len(balances))
+	for _, b := range balances {
+		resp = append(resp, balanceResponse{
+			Account:     b.Account.String(),
+			Participant: b.Account.Participant,
+			Asset:       b.Account.Asset,
+			Debit:       b.Debit.String(),
+			Credit:      b.Credit.String(),
+			Balance:     b.Net().String(),
+		})
+	}
+	return resp
+}
@@ -1 +1,619 @@
 package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/candles"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/checkpoint"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/config"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fees"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/ingestion"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/product"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/report"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/repository"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/settlement"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/telemetry"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/metrics"
+	"github.com/shopspring/decimal"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type OrderCommand = ingestion.OrderCommand
+
+// ParseLine parses an order line such as "s1 09:45 tomato 24/kg 100kg".
+func ParseLine(line string) (OrderCommand, error) {
+	return ingestion.ParseLine(line)
+}
+
+type Trade struct {
+	Product string
+	Demand  *order.Order
+	Supply  *order.Order
+}
+
+func (t Trade) String() string {
+	return fmt.Sprintf("%s %s %s/kg %skg", t.Demand.Id, t.Supply.Id, t.Supply.Price.String(), t.Supply.Qty.String())
+}
+
+type App struct {
+	mtx sync.Mutex
+
+	config     *config.Config
+	registry   *metrics.Registry
+	repository *repository.LedgerRepository
+	products   map[string]*product.Product
+
+	projections  *projection.Manager
+	candles      *candles.Projection
+	openOrders   *projection.OpenOrders
+	orders       *projection.Orders
+	tradeHistory *projection.TradeHistory
+	settlement   *settlement.Ledger
+	balances     *settlement.Balances
+	fees         *fees.Engine
+	statements   *fees.Statements
+	origins      *ingestion.Origins
+}
+
+func New(cfg *config.Config, logger *slog.Logger) (*App, error) {
+	registry := metrics.NewRegistry()
+	m := telemetry.NewMetrics(registry)
+	feeEngine := newFeeEngine(cfg)
+	balances := settlement.NewBalances(openingBalances(cfg.Funding), settlement.WithFees(feeEngine),
+		settlement.WithLots(lots(cfg)))
+
+	repoOpts := []repository.Option{
+		repository.WithLogger(logger),
+		repository.WithMetrics(m),
+		repository.WithSnapshotEvery(cfg.Storage.SnapshotEvery),
+		repository.WithDefaultProductOptions(productOptions(cfg, "", logger, m, balances, feeEngine)...),
+	}
+	for _, p := range cfg.Products {
+		repoOpts = append(repoOpts, repository.WithProductOptions(p.Name, productOptions(cfg, p.Name, logger, m, balances, feeEngine)...))
+	}
+
+	var history []event_sourcing.Record
+	if cfg.Storage.Backend == config.FileBackend {
+		journal := repository.NewFileJournal(cfg.Storage.Path)
+
+		var err error
+		if history, err = journal.Load(); err != nil {
+			return nil, err
+		}
+		repoOpts = append(repoOpts, repository.WithJournal(journal))
+	}
+
+	repo := repository.NewWarehouseRepository(repoOpts...)
+	if err := repo.Restore(history); err != nil {
+		return nil, err
+	}
+
+	if cp := cfg.Storage.Checkpoint; cp != nil {
+		key, err := checkpoint.LoadPrivateKey(cp.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = os.MkdirAll(cp.Dir, 0o755); err != nil {
+			return nil, err
+		}
+
+		writer := checkpoint.NewWriter(cp.Dir, key, cp.Every, history)
+		repo.Subscribe(func(records []event_sourcing.Record) {
+			if err := writer.Observe(records); err != nil {
+				logger.Error("checkpoint failed", slog.String("error", err.Error()))
+			}
+		})
+	}
+
+	candleProjection, _ := candles.NewProjection()
+
+	a := &App{
+		config:       cfg,
+		registry:     registry,
+		repository:   repo,
+		products:     make(map[string]*product.Product),
+		projections:  projection.NewManager(repo, projection.WithLogger(logger)),
+		candles:      candleProjection,
+		openOrders:   projection.NewOpenOrders(),
+		orders:       projection.NewOrders(),
+		tradeHistory: projection.NewTradeHistory(),
+		settlement:   settlement.NewLedger(),
+		balances:     balances,
+		fees:         feeEngine,
+		statements:   fees.NewStatements(),
+		origins:      ingestion.NewOrigins(),
+	}
+
+	for _, p := range []projection.Projection{a.candles, a.openOrders, a.orders, a.tradeHistory, a.settlement, a.balances, a.fees, a.statements, a.origins} {
+		_ = a.projections.Register(p)
+	}
+
+	return a, nil
+}
+
+func productOptions(cfg *config.Config, name string, logger *slog.Logger, m *telemetry.Metrics, balances *settlement.Balances, feeEngine *fees.Engine) []product.Option {
+	opts := []product.Option{product.WithLogger(logger), product.WithMetrics(m)}
+
+	if cfg.Funding.Enabled {
+		opts = append(opts, product.WithBalances(balances))
+	}
+
+	if _, ok := cfg.FeeScheduleFor(name); ok {
+		opts = append(opts, product.WithFees(feeEngine))
+	}
+
+	if p, ok := cfg.Product(name); ok {
+		opts = append(opts, product.WithInstrumentSpec(p.Instrument))
+	}
+
+	if cb, ok := cfg.CircuitBreakerFor(name); ok {
+		opts = append(opts, product.WithCircuitBreaker(cb))
+	}
+
+	return opts
+}
+
+func newFeeEngine(cfg *config.Config) *fees.Engine {
+	var opts []fees.Option
+	if cfg.Fees.Window > 0 {
+		opts = append(opts, fees.WithWindow(cfg.Fees.Window))
+	}
+	if cfg.Fees.Schedule != nil {
+		opts = append(opts, fees.WithDefaultSchedule(*cfg.Fees.Schedule))
+	}
+	for _, p := range cfg.Products {
+		if p.Fees != nil {
+			opts = append(opts, fees.WithSchedule(p.Name, *p.Fees))
+		}
+	}
+	return fees.NewEngine(opts...)
+}
+
+func lots(cfg *config.Config) map[string]decimal.Decimal {
+	lots := make(map[string]decimal.Decimal)
+	for _, p := range cfg.Products {
+		if lot, ok := p.Instrument.Lot(); ok {
+			lots[p.Name] = lot
+		}
+	}
+	return lots
+}
+
+func openingBalances(funding config.Funding) map[settlement.Account]decimal.Decimal {
+	opening := make(map[settlement.Account]decimal.Decimal)
+	for _, ob := range funding.OpeningBalances {
+		if !ob.Cash.IsZero() {
+			opening[settlement.Account{Participant: ob.Participant, Asset: settlement.Cash}] = ob.Cash
+		}
+		for name, qty := range ob.Inventory {
+			opening[settlement.Account{Participant: ob.Participant, Asset: name}] = qty
+		}
+	}
+	return opening
+}
+
+func (a *App) Submit(cmd OrderCommand) ([]Trade, error) {
+	_, trades, err := a.Place(cmd)
+	return trades, err
+}
+
+// Place submits an order like Submit and also returns its id, generated if the command has none. An order
+// retried with the same idempotency key returns the id of the original order. The trades are followed by those
+// of the stop orders that the order triggered, which fill the stop orders rather than the order.
+func (a *App) Place(cmd OrderCommand) (string, []Trade, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.place(cmd)
+}
+
+func (a *App) place(cmd OrderCommand) (string, []Trade, error) {
+	p, err := a.product(cmd.Product)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if cmd.Id == "" {
+		cmd.Id = uuid.New().String()
+	}
+
+	opts, err := orderOptions(cmd)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var matchDemand, matchSupply []*order.Order
+	switch {
+	case cmd.Side != constants.SupplyOrderType && cmd.Side != constants.DemandOrderType:
+		err = fmt.Errorf("unknown order side %q", cmd.Side)
+	case cmd.StopPrice > 0:
+		err, matchDemand, matchSupply = p.StopProduct(cmd.Side, cmd.StopPrice, cmd.Price, cmd.Qty, opts...)
+	case cmd.Side == constants.SupplyOrderType:
+		err, matchDemand, matchSupply = p.SupplyProduct(cmd.Price, cmd.Qty, opts...)
+	default:
+		err, matchDemand, matchSupply = p.DemandProduct(cmd.Price, cmd.Qty, opts...)
+	}
+	var duplicate *product.DuplicateOrderError
+	if errors.As(err, &duplicate) {
+		trades := make([]Trade, 0, len(duplicate.Supplies))
+		for i := range duplicate.Supplies {
+			trades = append(trades, Trade{Product: cmd.Product, Demand: duplicate.Demands[i], Supply: duplicate.Supplies[i]})
+		}
+		return duplicate.OrderId, trades, nil
+	}
+	if err != nil {
+		// the product recorded the rejection
+		if saveErr := a.repository.Save(p); saveErr != nil {
+			return "", nil, saveErr
+		}
+		return "", nil, err
+	}
+
+	trades, err := a.execute(p, cmd.Product, matchDemand, matchSupply)
+	if err != nil {
+		return "", nil, err
+	}
+	return cmd.Id, trades, nil
+}
+
+// orderOptions returns the options of the order event placing cmd.
+func orderOptions(cmd OrderCommand) ([]event_sourcing.OrderOption, error) {
+	opts := []event_sourcing.OrderOption{event_sourcing.WithParticipant(cmd.Participant), event_sourcing.WithOrderId(cmd.Id)}
+	if cmd.Origin != "" {
+		opts = append(opts, event_sourcing.WithOrigin(cmd.Origin))
+	}
+	if cmd.IdempotencyKey != "" {
+		opts = append(opts, event_sourcing.WithIdempotencyKey(cmd.IdempotencyKey))
+	}
+	if cmd.Peak < 0 {
+		return nil, fmt.Errorf("invalid peak %v", cmd.Peak)
+	}
+	if cmd.Peak > 0 {
+		opts = append(opts, event_sourcing.WithPeak(cmd.Peak))
+	}
+	switch {
+	case cmd.MinQty < 0 || cmd.MinQty > cmd.Qty:
+		return nil, fmt.Errorf("invalid min qty %v", cmd.MinQty)
+	case cmd.Peak > 0 && (cmd.AllOrNone || cmd.MinQty > cmd.Peak):
+		return nil, errors.New("an iceberg order cannot fill more than its peak at once")
+	}
+	if cmd.MinQty > 0 {
+		opts = append(opts, event_sourcing.WithMinQty(cmd.MinQty))
+	}
+	if cmd.AllOrNone {
+		opts = append(opts, event_sourcing.WithAllOrNone())
+	}
+
+	if cmd.StopPrice < 0 {
+		return nil, fmt.Errorf("invalid stop price %v", cmd.StopPrice)
+	}
+	return opts, nil
+}
+
+// execute records the trades of what an order matched and of the stop orders they trigger, then saves the
+// product. It saves the events recorded so far even if recording fails part way, so that the saved events
+// always add up to the state of the product.
+func (a *App) execute(p *product.Product, productName string, matchDemand, matchSupply []*order.Order) ([]Trade, error) {
+	trades, err := a.trade(p, productName, matchDemand, matchSupply)
+	if saveErr := a.repository.Save(p); saveErr != nil {
+		return nil, saveErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return trades, nil
+}
+
+func (a *App) trade(p *product.Product, productName string, matchDemand, matchSupply []*order.Order) ([]Trade, error) {
+	trades := make([]Trade, 0, len(matchSupply))
+	for i := 0; i < len(matchSupply); i++ {
+		if err := p.TradeProduct(matchSupply[i], matchDemand[i]); err != nil {
+			return nil, err
+		}
+		trades = append(trades, Trade{Product: productName, Demand: matchDemand[i], Supply: matchSupply[i]})
+	}
+
+	err, matchDemand, matchSupply := p.TriggerStops()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(matchSupply); i++ {
+		trades = append(trades, Trade{Product: productName, Demand: matchDemand[i], Supply: matchSupply[i]})
+	}
+	return trades, nil
+}
+
+// Cancel withdraws a resting or stop order of participant.
+func (a *App) Cancel(productName, participant, orderId string) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	p, err := a.product(productName)
+	if err != nil {
+		return err
+	}
+
+	if owner, ok := ownerOf(p, orderId); ok && owner != participant {
+		return errors.New(constants.OrderNotOwnedErrorMessage)
+	}
+	if err = p.CancelOrder(orderId); err != nil {
+		return err
+	}
+
+	return a.repository.Save(p)
+}
+
+// ownerOf returns the participant of the resting or stop order orderId.
+func ownerOf(p *product.Product, orderId string) (string, bool) {
+	if resting, ok := p.Resting(orderId); ok {
+		return resting.Participant, true
+	}
+	for _, stop := range p.GetCurrentState().Stops.Get() {
+		if stop.Id == orderId {
+			return stop.Participant, true
+		}
+	}
+	return "", false
+}
+
+// Amend replaces the price and quantity of a resting order of participant. The order is cancelled and placed
+// again under the same id, so it loses its time priority and may match right away. An iceberg order keeps its
+// peak and an order its minimum fill, cut to the new quantity if that is less. If the replacement is
+// rejected, the order stays as it was. Stop orders cannot be amended.
+func (a *App) Amend(productName, participant, orderId string, price, qty float64) ([]Trade, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	p, err := a.product(productName)
+	if err != nil {
+		return nil, err
+	}
+
+	resting, ok := p.Resting(orderId)
+	if !ok {
+		for _, stop := range p.GetCurrentState().Stops.Get() {
+			if stop.Id == orderId {
+				return nil, errors.New(constants.StopOrderAmendErrorMessage)
+			}
+		}
+		return nil, errors.New(constants.OrderNotFoundErrorMessage)
+	}
+	if resting.Participant != participant {
+		return nil, errors.New(constants.OrderNotOwnedErrorMessage)
+	}
+
+	peak, _ := resting.Peak.Float64()
+	minQty, _ := decimal.Min(resting.MinQty, decimal.NewFromFloat(qty)).Float64()
+	opts, err := orderOptions(OrderCommand{Id: orderId, Participant: resting.Participant, Price: price, Qty: qty, Peak: peak, MinQty: minQty, AllOrNone: resting.AllOrNone})
+	if err != nil {
+		return nil, err
+	}
+
+	err, matchDemand, matchSupply := p.AmendOrder(orderId, price, qty, opts...)
+	if err != nil {
+		// a replacement refused after the cancel was recorded as rejected
+		if saveErr := a.repository.Save(p); saveErr != nil {
+			return nil, saveErr
+		}
+		return nil, err
+	}
+	return a.execute(p, productName, matchDemand, matchSupply)
+}
+
+// Candles returns the candles of a product at the given interval whose start lies in [from, to).
+func (a *App) Candles(productName string, interval time.Duration, from, to time.Time) []candles.Candle {
+	return a.candles.Candles(productName, interval, from, to)
+}
+
+func (a *App) OpenOrders(participant string) []projection.OpenOrder {
+	return a.openOrders.ByParticipant(participant)
+}
+
+// OpenOrder returns the open order with the given id, in whichever product it rests.
+func (a *App) OpenOrder(orderId string) (projection.OpenOrder, bool) {
+	return a.openOrders.ById(orderId)
+}
+
+// Order returns the lifecycle of the order with the given id and its fills, whether or not it is still in
+// its book.
+func (a *App) Order(orderId string) (projection.OrderState, bool) {
+	return a.orders.ById(orderId)
+}
+
+func (a *App) Trades(productName, participant string) []event_sourcing.Trade {
+	return a.tradeHistory.Trades(productName, participant)
+}
+
+// TrialBalance returns the settlement accounts with their balances, and an error if the settlement journal
+// does not balance.
+func (a *App) TrialBalance() ([]settlement.Balance, error) {
+	return a.settlement.TrialBalance(), a.settlement.Verify()
+}
+
+func (a *App) Accounts(participant string) []settlement.Balance {
+	return a.settlement.Balances(participant)
+}
+
+// Holdings returns the inventory and cash of a participant with what their resting orders reserve of it.
+func (a *App) Holdings(participant string) []settlement.Holding {
+	return a.balances.Holdings(participant)
+}
+
+// FeeStatement returns the fees a participant was charged in the calendar month holding month.
+func (a *App) FeeStatement(participant string, month time.Time) fees.Statement {
+	return a.statements.Statement(participant, month)
+}
+
+// Ingested returns the last line of an ingestion source that placed an order.
+func (a *App) Ingested(source string) int {
+	return a.origins.Line(source)
+}
+
+func (a *App) JournalEntries(participant string) []settlement.Entry {
+	return a.settlement.Entries(participant)
+}
+
+// Rebuild replays the event store from zero into the named projection, or into every projection if name is empty.
+func (a *App) Rebuild(name string) error {
+	if name == "" {
+		a.projections.RebuildAll()
+		return nil
+	}
+	return a.projections.Rebuild(name)
+}
+
+func (a *App) Projections() []string {
+	return a.projections.Names()
+}
+
+// Events returns up to limit records of the global log whose sequence is greater than after. A limit of
+// zero or less returns all of them.
+func (a *App) Events(after uint64, limit int) []event_sourcing.Record {
+	records := a.repository.ReadAll(after)
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records
+}
+
+// Subscribe registers fn to receive the records appended to the ledger, whichever channel changed it.
+func (a *App) Subscribe(fn func(records []event_sourcing.Record)) {
+	a.repository.Subscribe(fn)
+}
+
+// MetricsHandler serves the ledger metrics in the Prometheus text format.
+func (a *App) MetricsHandler() http.Handler {
+	return a.registry.Handler()
+}
+
+func (a *App) Book(name string) ([]*order.Order, []*order.Order, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	p, err := a.product(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	demands, supplies := p.GetCurrentState().OrderBook.Get()
+	return demands, supplies, nil
+}
+
+// BookAtVersion rebuilds the book of a product as it was right after the event with the given version
+// of its stream.
+func (a *App) BookAtVersion(name string, version int) ([]*order.Order, []*order.Order, error) {
+	id, ok := a.repository.StreamOf(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("no events recorded for product %s", name)
+	}
+
+	state, err := a.repository.StateAt(id, name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	demands, supplies := state.OrderBook.Get()
+	return demands, supplies, nil
+}
+
+// BookAtTime rebuilds the book of a product as it was at the given time. It also returns the version of
+// the last event applied.
+func (a *App) BookAtTime(name string, at time.Time) ([]*order.Order, []*order.Order, int, error) {
+	id, ok := a.repository.StreamOf(name)
+	if !ok {
+		return nil, nil, 0, fmt.Errorf("no events recorded for product %s", name)
+	}
+
+	version := a.repository.VersionAt(id, at)
+	demands, supplies, err := a.BookAtVersion(name, version)
+	return demands, supplies, version, err
+}
+
+// Report builds the end of day report of the UTC day holding day from the event store.
+func (a *App) Report(day time.Time) (*report.Report, error) {
+	return report.Build(a.repository, day)
+}
+
+// Process matches every order read from r and writes the resulting trades to w, one per line. The input is
+// text, CSV or JSON lines, detected from its first line unless a format is given. Orders that cannot be
+// processed are reported with their line number once the whole input was read.
+func (a *App) Process(r io.Reader, w io.Writer, opts ...ingestion.Option) error {
+	_, err := a.Stream(context.Background(), file_ops.NewLineReader(r), w, opts...)
+	return err
+}
+
+// Stream processes the orders of source one line at a time, so that inputs larger than memory can be
+// processed. It returns the position after the last line processed, from which an interrupted run can be
+// resumed with ingestion.WithResume.
+func (a *App) Stream(ctx context.Context, source ingestion.Source, w io.Writer, opts ...ingestion.Option) (file_ops.Position, error) {
+	parser := ingestion.NewSourceParser(source, opts...)
+
+	var position file_ops.Position
+	var result error
+	for {
+		line, err := parser.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return position, multierror.Append(result, err)
+		}
+
+		if line.Err != nil {
+			result = multierror.Append(result, fmt.Errorf("line %d: %w", line.Number, line.Err))
+			position = line.Position
+			continue
+		}
+
+		trades, err := a.Submit(line.Command)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("line %d: %w", line.Number, err))
+			position = line.Position
+			continue
+		}
+
+		for _, t := range trades {
+			if _, err = fmt.Fprintln(w, t.String()); err != nil {
+				return position, err
+			}
+		}
+		position = line.Position
+	}
+
+	return position, result
+}
+
+func (a *App) product(name string) (*product.Product, error) {
+	if p, ok := a.products[name]; ok {
+		return p, nil
+	}
+
+	if len(a.config.Products) > 0 {
+		if _, ok := a.config.Product(name); !ok {
+			return nil, fmt.Errorf("unknown product %s", name)
+		}
+	}
+
+	id, ok := a.repository.StreamOf(name)
+	if !ok {
+		id = uuid.New().String()
+	}
+
+	p := a.repository.Get(id, name)
+	a.products[name] = p
+	return p, nil
+}
@@ -0,0 +1,289 @@
+package app_test
+
+import (
+	"bytes"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/config"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestApp_ProcessMatchesExpectedOutputs(t *testing.T) {
+	for _, scenario := range []struct{ input, output string }{
+		{"../../test/dist/input1.txt", "../../test/dist/output1.txt"},
+		{"../../test/dist/input2.txt", "../../test/dist/output2.txt"},
+	} {
+		cfg, err := config.Load("../../configs", "")
+		require.NoError(t, err)
+
+		f, err := os.Open(scenario.input)
+		require.NoError(t, err)
+
+		ledger, err := app.New(cfg, logging.Discard())
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		err = ledger.Process(f, &out)
+		_ = f.Close()
+		require.NoError(t, err)
+
+		expected, err := file_ops.Read(scenario.output)
+		require.NoError(t, err)
+		assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(out.String()), scenario.input)
+	}
+}
+
+func TestApp_ProcessReportsLineNumbers(t *testing.T) {
+	cfg, err := config.Load("../../configs", "")
+	require.NoError(t, err)
+
+	input := "s1 09:45 tomato 24/kg 100kg\nx1 09:46 tomato 20/kg 90kg\nd1 09:47 onion 22/kg 110kg\n"
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	err = ledger.Process(strings.NewReader(input), &out)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+	assert.Contains(t, err.Error(), "line 3: unknown product onion")
+}
+
+func TestApp_RejectsUnfundedOrdersWhenFundingIsEnabled(t *testing.T) {
+	cfg, err := config.Load("../../configs", "")
+	require.NoError(t, err)
+	cfg.Funding = config.Funding{Enabled: true, OpeningBalances: []config.OpeningBalance{
+		{Participant: "grower-1", Inventory: map[string]decimal.Decimal{"tomato": decimal.NewFromInt(100)}},
+		{Participant: "buyer-1", Cash: decimal.NewFromInt(500)},
+	}}
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	_, err = ledger.Submit(app.OrderCommand{Id: "s1", Participant: "grower-1", Product: "tomato", Side: "SUPPLY", Price: 20, Qty: 120})
+	assert.ErrorContains(t, err, "insufficient balance: inventory:grower-1:tomato needs 120, 100 available")
+
+	_, err = ledger.Submit(app.OrderCommand{Id: "s1", Participant: "grower-1", Product: "tomato", Side: "SUPPLY", Price: 20, Qty: 100})
+	require.NoError(t, err)
+
+	trades, err := ledger.Submit(app.OrderCommand{Id: "d1", Participant: "buyer-1", Product: "tomato", Side: "DEMAND", Price: 25, Qty: 20})
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+
+	holdings := ledger.Holdings("buyer-1")
+	require.Len(t, holdings, 2)
+	assert.Equal(t, "100", holdings[0].Available().String())
+	assert.Equal(t, "20", holdings[1].Total.String())
+}
+
+func TestApp_AmendLeavesTheOrderAsItWasWhenTheReplacementIsRefused(t *testing.T) {
+	cfg, err := config.Load("../../configs", "")
+	require.NoError(t, err)
+	cfg.Funding = config.Funding{Enabled: true, OpeningBalances: []config.OpeningBalance{
+		{Participant: "grower-1", Inventory: map[string]decimal.Decimal{"tomato": decimal.NewFromInt(100)}},
+		{Participant: "buyer-1", Cash: decimal.NewFromInt(500)},
+	}}
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	_, err = ledger.Submit(app.OrderCommand{Id: "s1", Participant: "grower-1", Product: "tomato", Side: "SUPPLY", Price: 20, Qty: 60})
+	require.NoError(t, err)
+	_, err = ledger.Submit(app.OrderCommand{Id: "d1", Participant: "buyer-1", Product: "tomato", Side: "DEMAND", Price: 15, Qty: 10, StopPrice: 25})
+	require.NoError(t, err)
+
+	_, err = ledger.Amend("tomato", "grower-1", "s1", 21, 90)
+	require.NoError(t, err, "the reservation of s1 backs its replacement")
+
+	_, err = ledger.Amend("tomato", "grower-1", "s1", 21, 120)
+	assert.ErrorContains(t, err, "insufficient balance")
+	_, err = ledger.Amend("tomato", "grower-1", "s1", 21.005, 90)
+	assert.ErrorContains(t, err, "price")
+	_, err = ledger.Amend("tomato", "buyer-1", "s1", 22, 90)
+	assert.EqualError(t, err, "order belongs to another participant")
+	_, err = ledger.Amend("tomato", "buyer-1", "d1", 16, 10)
+	assert.EqualError(t, err, "stop orders cannot be amended, cancel and place them again")
+
+	_, supplies, err := ledger.Book("tomato")
+	require.NoError(t, err)
+	require.Len(t, supplies, 1)
+	assert.Equal(t, "21", supplies[0].Price.String())
+	assert.Equal(t, "90", supplies[0].Qty.String())
+
+	s1, ok := ledger.Order("s1")
+	require.True(t, ok)
+	assert.Equal(t, "new", s1.Status)
+}
+
+func TestParseLine(t *testing.T) {
+	cmd, err := app.ParseLine("s1 09:45 tomato 24/kg 100kg")
+	require.NoError(t, err)
+	assert.Equal(t, app.OrderCommand{Id: "s1", Participant: "s1", Product: "tomato", Side: "SUPPLY", Price: 24, Qty: 100}, cmd)
+
+	_, err = app.ParseLine("s1 09:45 tomato 24/kg")
+	assert.Error(t, err)
+
+	_, err = app.ParseLine("d1 09:45 tomato abc/kg 10kg")
+	assert.Error(t, err)
+}
+
+func TestApp_TriggersStopLimitOrdersOnTheLastTradePrice(t *testing.T) {
+	cfg, err := config.Load("../../configs", "")
+	require.NoError(t, err)
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	for _, cmd := range []app.OrderCommand{
+		{Id: "s1", Product: "tomato", Side: "SUPPLY", Price: 20, Qty: 10},
+		{Id: "s2", Product: "tomato", Side: "SUPPLY", Price: 22, Qty: 50},
+		{Id: "stop-1", Product: "tomato", Side: "DEMAND", StopPrice: 21, Price: 22, Qty: 60},
+		{Id: "stop-2", Product: "tomato", Side: "DEMAND", StopPrice: 30, Qty: 10},
+	} {
+		trades, err := ledger.Submit(cmd)
+		require.NoError(t, err)
+		assert.Empty(t, trades, cmd.Id)
+	}
+
+	stop, ok := ledger.Order("stop-1")
+	require.True(t, ok)
+	assert.Equal(t, "pending", stop.Status)
+	require.NoError(t, ledger.Cancel("tomato", "", "stop-2"))
+
+	trades, err := ledger.Submit(app.OrderCommand{Id: "d1", Product: "tomato", Side: "DEMAND", Price: 20, Qty: 10})
+	require.NoError(t, err)
+	require.Len(t, trades, 1, "20 does not reach the stop price")
+
+	trades, err = ledger.Submit(app.OrderCommand{Id: "d2", Product: "tomato", Side: "DEMAND", Price: 22, Qty: 5})
+	require.NoError(t, err)
+	require.Len(t, trades, 2)
+	assert.Equal(t, "d2 s2 22/kg 5kg", trades[0].String())
+	assert.Equal(t, "stop-1 s2 22/kg 45kg", trades[1].String())
+
+	demands, _, err := ledger.Book("tomato")
+	require.NoError(t, err)
+	require.Len(t, demands, 1)
+	assert.Equal(t, "stop-1", demands[0].Id)
+	assert.Equal(t, "15", demands[0].Qty.String())
+
+	stop, _ = ledger.Order("stop-1")
+	assert.Equal(t, "partially_filled", stop.Status)
+	cancelled, _ := ledger.Order("stop-2")
+	assert.Equal(t, "cancelled", cancelled.Status)
+
+	var types []string
+	for _, r := range ledger.Events(0, 0) {
+		types = append(types, r.Event.Type())
+	}
+	assert.Equal(t, []string{"supply", "supply", "stop", "stop", "cancel", "demand", "trade", "demand", "trade", "trigger", "trade"}, types)
+}
+
+func TestApp_TriggersStopMarketOrdersAndCancelsTheirRemainder(t *testing.T) {
+	cfg, err := config.Load("../../configs", "")
+	require.NoError(t, err)
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	for _, cmd := range []app.OrderCommand{
+		{Id: "d1", Product: "tomato", Side: "DEMAND", Price: 20, Qty: 10},
+		{Id: "d2", Product: "tomato", Side: "DEMAND", Price: 18.5, Qty: 10},
+		{Id: "stop", Product: "tomato", Side: "SUPPLY", StopPrice: 19.5, Qty: 30},
+	} {
+		_, err := ledger.Submit(cmd)
+		require.NoError(t, err)
+	}
+
+	trades, err := ledger.Submit(app.OrderCommand{Id: "s1", Product: "tomato", Side: "SUPPLY", Price: 19.5, Qty: 5})
+	require.NoError(t, err)
+	require.Len(t, trades, 3)
+	assert.Equal(t, "d1 s1 19.5/kg 5kg", trades[0].String())
+	assert.Equal(t, "d1 stop 20/kg 5kg", trades[1].String(), "a market supply sells at the price of each demand")
+	assert.Equal(t, "d2 stop 18.5/kg 10kg", trades[2].String())
+
+	demands, supplies, err := ledger.Book("tomato")
+	require.NoError(t, err)
+	assert.Empty(t, demands)
+	assert.Empty(t, supplies)
+
+	stop, ok := ledger.Order("stop")
+	require.True(t, ok)
+	assert.Equal(t, "cancelled", stop.Status)
+	assert.Equal(t, "15", stop.FilledQty.String())
+	assert.Equal(t, "15", stop.LeavesQty.String())
+
+	_, err = ledger.Submit(app.OrderCommand{Id: "late", Product: "tomato", Side: "DEMAND", StopPrice: 15, Qty: 10})
+	assert.EqualError(t, err, "stop price was already reached by the last trade price")
+}
+
+func TestApp_StopMarketDemandsSpendNoMoreThanTheirStopPriceReserved(t *testing.T) {
+	cfg, err := config.Load("../../configs", "")
+	require.NoError(t, err)
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	for _, cmd := range []app.OrderCommand{
+		{Id: "s1", Product: "tomato", Side: "SUPPLY", Price: 20, Qty: 5},
+		{Id: "s2", Product: "tomato", Side: "SUPPLY", Price: 21, Qty: 10},
+		{Id: "s3", Product: "tomato", Side: "SUPPLY", Price: 30, Qty: 10},
+		{Id: "stop", Product: "tomato", Side: "DEMAND", StopPrice: 20, Qty: 15},
+	} {
+		_, err := ledger.Submit(cmd)
+		require.NoError(t, err)
+	}
+
+	trades, err := ledger.Submit(app.OrderCommand{Id: "d1", Product: "tomato", Side: "DEMAND", Price: 20, Qty: 5})
+	require.NoError(t, err)
+	require.Len(t, trades, 2, "5kg more at 30 would spend past the 300 reserved at the stop price")
+	assert.Equal(t, "d1 s1 20/kg 5kg", trades[0].String())
+	assert.Equal(t, "stop s2 21/kg 10kg", trades[1].String())
+
+	stop, ok := ledger.Order("stop")
+	require.True(t, ok)
+	assert.Equal(t, "cancelled", stop.Status)
+	assert.Equal(t, "10", stop.FilledQty.String())
+}
+
+func TestApp_FillsAllOrNoneOrdersAtOnce(t *testing.T) {
+	cfg, err := config.Load("../../configs", "")
+	require.NoError(t, err)
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	for _, cmd := range []app.OrderCommand{
+		{Id: "d3", Product: "tomato", Side: "DEMAND", Price: 110, Qty: 10, AllOrNone: true},
+		{Id: "d1", Product: "tomato", Side: "DEMAND", Price: 110, Qty: 1},
+	} {
+		_, err := ledger.Submit(cmd)
+		require.NoError(t, err)
+	}
+
+	trades, err := ledger.Submit(app.OrderCommand{Id: "s4", Product: "tomato", Side: "SUPPLY", Price: 110, Qty: 4})
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	assert.Equal(t, "d1 s4 110/kg 1kg", trades[0].String(), "4kg cannot fill d3 at once")
+
+	trades, err = ledger.Submit(app.OrderCommand{Id: "s5", Product: "tomato", Side: "SUPPLY", Price: 110, Qty: 10})
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	assert.Equal(t, "d3 s5 110/kg 10kg", trades[0].String())
+
+	d3, ok := ledger.Order("d3")
+	require.True(t, ok)
+	assert.Equal(t, "filled", d3.Status)
+	require.Len(t, d3.Fills, 1)
+
+	_, err = ledger.Submit(app.OrderCommand{Id: "s6", Product: "tomato", Side: "SUPPLY", Price: 110, Qty: 10, MinQty: 12})
+	assert.EqualError(t, err, "invalid min qty 12")
+
+	_, err = ledger.Submit(app.OrderCommand{Id: "s7", Product: "tomato", Side: "SUPPLY", Price: 110, Qty: 10, Peak: 2, AllOrNone: true})
+	assert.EqualError(t, err, "an iceberg order cannot fill more than its peak at once")
+}
@@ -0,0 +1,230 @@
+package candles
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/shopspring/decimal"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const ProjectionName = "candles"
+
+var SupportedIntervals = []time.Duration{time.Minute, 5 * time.Minute, time.Hour, 24 * time.Hour}
+
+type Candle struct {
+	Product  string
+	Interval time.Duration
+	Start    time.Time
+	Open     decimal.Decimal
+	High     decimal.Decimal
+	Low      decimal.Decimal
+	Close    decimal.Decimal
+	Volume   decimal.Decimal
+	Turnover decimal.Decimal
+	Trades   int
+}
+
+func (c Candle) End() time.Time {
+	return c.Start.Add(c.Interval)
+}
+
+// VWAP is the volume weighted average price of the trades in the candle.
+func (c Candle) VWAP() decimal.Decimal {
+	if c.Volume.IsZero() {
+		return decimal.Zero
+	}
+	return c.Turnover.Div(c.Volume)
+}
+
+func (c *Candle) add(t event_sourcing.Trade) {
+	if c.Trades == 0 {
+		c.Open, c.High, c.Low = t.Price, t.Price, t.Price
+	}
+
+	if t.Price.GreaterThan(c.High) {
+		c.High = t.Price
+	}
+	if t.Price.LessThan(c.Low) {
+		c.Low = t.Price
+	}
+
+	c.Close = t.Price
+	c.Volume = c.Volume.Add(t.Qty)
+	c.Turnover = c.Turnover.Add(t.Price.Mul(t.Qty))
+	c.Trades++
+}
+
+type seriesKey struct {
+	product  string
+	interval time.Duration
+}
+
+// Projection maintains OHLCV candles per product for every configured interval from trade events.
+type Projection struct {
+	mtx       sync.RWMutex
+	intervals []time.Duration
+	series    map[seriesKey]map[int64]*Candle
+}
+
+func NewProjection(intervals ...time.Duration) (*Projection, error) {
+	if len(intervals) == 0 {
+		intervals = SupportedIntervals
+	}
+
+	for _, interval := range intervals {
+		if !isSupported(interval) {
+			return nil, fmt.Errorf("unsupported candle interval %v", interval)
+		}
+	}
+
+	return &Projection{intervals: intervals, series: make(map[seriesKey]map[int64]*Candle)}, nil
+}
+
+func (p *Projection) Name() string {
+	return ProjectionName
+}
+
+func (p *Projection) Handlers() map[string]projection.Handler {
+	return map[string]projection.Handler{
+		constants.TradeEventType: func(record event_sourcing.Record) { p.Apply(record.Event) },
+	}
+}
+
+func (p *Projection) Reset() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.series = make(map[seriesKey]map[int64]*Candle)
+}
+
+// Apply folds a trade event into the candles of its product; any other event is ignored.
+func (p *Projection) Apply(ev event_sourcing.Event) {
+	te, ok := ev.(event_sourcing.TradeEvent)
+	if !ok {
+		return
+	}
+	t := te.Trade()
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, interval := range p.intervals {
+		key := seriesKey{product: t.Product, interval: interval}
+		if _, ok := p.series[key]; !ok {
+			p.series[key] = make(map[int64]*Candle)
+		}
+
+		start := t.Timestamp.UTC().Truncate(interval)
+		c, ok := p.series[key][start.UnixNano()]
+		if !ok {
+			c = &Candle{Product: t.Product, Interval: interval, Start: start}
+			p.series[key][start.UnixNano()] = c
+		}
+		c.add(t)
+	}
+}
+
+// Candles returns the candles of a product whose start lies in [from, to), oldest first.
+func (p *Projection) Candles(product string, interval time.Duration, from, to time.Time) []Candle {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	result := make([]Candle, 0)
+	for _, c := range p.series[seriesKey{product: product, interval: interval}] {
+		if !c.Start.Before(from) && c.Start.Before(to) {
+			result = append(result, *c)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Start.Before(result[j].Start) })
+	return result
+}
+
+// Summary rolls the candles of a product in [from, to) into one, answering what the product traded at in that window.
+// The window is resolved with the finest configured interval.
+func (p *Projection) Summary(product string, from, to time.Time) (Candle, bool) {
+	interval := p.intervals[0]
+	for _, i := range p.intervals {
+		if i < interval {
+			interval = i
+		}
+	}
+
+	candles := p.Candles(product, interval, from.UTC().Truncate(interval), to)
+	if len(candles) == 0 {
+		return Candle{}, false
+	}
+
+	summary := Candle{Product: product, Interval: to.Sub(from), Start: from, Open: candles[0].Open, High: candles[0].High, Low: candles[0].Low}
+	for _, c := range candles {
+		if c.High.GreaterThan(summary.High) {
+			summary.High = c.High
+		}
+		if c.Low.LessThan(summary.Low) {
+			summary.Low = c.Low
+		}
+		summary.Close = c.Close
+		summary.Volume = summary.Volume.Add(c.Volume)
+		summary.Turnover = summary.Turnover.Add(c.Turnover)
+		summary.Trades += c.Trades
+	}
+
+	return summary, true
+}
+
+func WriteCSV(w io.Writer, candles []Candle) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"product", "interval", "start", "end", "open", "high", "low", "close", "volume", "vwap", "trades"}); err != nil {
+		return err
+	}
+
+	for _, c := range candles {
+		record := []string{
+			c.Product,
+			c.Interval.String(),
+			c.Start.UTC().Format(time.RFC3339),
+			c.End().UTC().Format(time.RFC3339),
+			c.Open.String(),
+			c.High.String(),
+			c.Low.String(),
+			c.Close.String(),
+			c.Volume.String(),
+			c.VWAP().StringFixed(4),
+			strconv.Itoa(c.Trades),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func ParseInterval(value string) (time.Duration, error) {
+	interval, err := time.ParseDuration(value)
+	if value == "1d" {
+		interval, err = 24*time.Hour, nil
+	}
+	if err != nil || !isSupported(interval) {
+		return 0, fmt.Errorf("unsupported candle interval %q, expected one of 1m, 5m, 1h, 1d", value)
+	}
+	return interval, nil
+}
+
+func isSupported(interval time.Duration) bool {
+	for _, supported := range SupportedIntervals {
+		if interval == supported {
+			return true
+		}
+	}
+	return false
+}
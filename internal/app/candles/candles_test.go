@@ -0,0 +1,131 @@
+package candles_test
+
+import (
+	"bytes"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/candles"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+	"time"
+)
+
+type candlesSuite struct {
+	suite.Suite
+	projection *candles.Projection
+	opening    time.Time
+}
+
+func TestCandlesSuite(t *testing.T) {
+	suite.Run(t, new(candlesSuite))
+}
+
+func (suite *candlesSuite) SetupTest() {
+	projection, err := candles.NewProjection()
+	suite.Require().NoError(err)
+
+	suite.projection = projection
+	suite.opening = time.Date(2023, 3, 1, 9, 45, 0, 0, time.UTC)
+}
+
+func (suite *candlesSuite) trade(product string, price, qty float64, at time.Time) event_sourcing.Event {
+	supply := &order.Order{Id: "s1", Price: decimal.NewFromFloat(price), Qty: decimal.NewFromFloat(qty), OrderType: constants.SupplyOrderType, Timestamp: at.UnixNano()}
+	demand := &order.Order{Id: "d1", Price: decimal.NewFromFloat(price), Qty: decimal.NewFromFloat(qty), OrderType: constants.DemandOrderType, Timestamp: at.UnixNano()}
+	return event_sourcing.NewTradeEvent(product, supply, demand)
+}
+
+func (suite *candlesSuite) TestBuildsOHLCVPerInterval() {
+	suite.projection.Apply(suite.trade("tomato", 20, 10, suite.opening.Add(5*time.Second)))
+	suite.projection.Apply(suite.trade("tomato", 24, 30, suite.opening.Add(20*time.Second)))
+	suite.projection.Apply(suite.trade("tomato", 18, 20, suite.opening.Add(40*time.Second)))
+	suite.projection.Apply(suite.trade("tomato", 22, 40, suite.opening.Add(90*time.Second)))
+
+	minutes := suite.projection.Candles("tomato", time.Minute, suite.opening, suite.opening.Add(time.Hour))
+	suite.Require().Len(minutes, 2)
+
+	first := minutes[0]
+	suite.Assert().Equal(suite.opening, first.Start)
+	suite.Assert().Equal(suite.opening.Add(time.Minute), first.End())
+	suite.Assert().True(decimal.NewFromFloat(20).Equal(first.Open))
+	suite.Assert().True(decimal.NewFromFloat(24).Equal(first.High))
+	suite.Assert().True(decimal.NewFromFloat(18).Equal(first.Low))
+	suite.Assert().True(decimal.NewFromFloat(18).Equal(first.Close))
+	suite.Assert().True(decimal.NewFromFloat(60).Equal(first.Volume))
+	suite.Assert().Equal(3, first.Trades)
+	suite.Assert().Equal("21.3333", first.VWAP().StringFixed(4))
+
+	fiveMinutes := suite.projection.Candles("tomato", 5*time.Minute, suite.opening, suite.opening.Add(time.Hour))
+	suite.Require().Len(fiveMinutes, 1)
+	suite.Assert().True(decimal.NewFromFloat(22).Equal(fiveMinutes[0].Close))
+	suite.Assert().True(decimal.NewFromFloat(100).Equal(fiveMinutes[0].Volume))
+	suite.Assert().Equal(4, fiveMinutes[0].Trades)
+}
+
+func (suite *candlesSuite) TestKeepsProductsApart() {
+	suite.projection.Apply(suite.trade("tomato", 20, 10, suite.opening))
+	suite.projection.Apply(suite.trade("potato", 30, 5, suite.opening))
+
+	tomato := suite.projection.Candles("tomato", time.Hour, suite.opening.Add(-time.Hour), suite.opening.Add(time.Hour))
+	suite.Require().Len(tomato, 1)
+	suite.Assert().True(decimal.NewFromFloat(10).Equal(tomato[0].Volume))
+
+	suite.Assert().Empty(suite.projection.Candles("onion", time.Hour, suite.opening.Add(-time.Hour), suite.opening.Add(time.Hour)))
+}
+
+func (suite *candlesSuite) TestIgnoresNonTradeEvents() {
+	suite.projection.Apply(event_sourcing.NewProductSupplyEvent("tomato", 20, 10))
+
+	suite.Assert().Empty(suite.projection.Candles("tomato", time.Minute, time.Time{}, time.Now().Add(time.Hour)))
+}
+
+func (suite *candlesSuite) TestSummarisesWindow() {
+	suite.projection.Apply(suite.trade("tomato", 20, 10, suite.opening.Add(-time.Minute)))
+	suite.projection.Apply(suite.trade("tomato", 21, 10, suite.opening.Add(2*time.Minute)))
+	suite.projection.Apply(suite.trade("tomato", 25, 30, suite.opening.Add(9*time.Minute)))
+	suite.projection.Apply(suite.trade("tomato", 19, 10, suite.opening.Add(14*time.Minute)))
+	suite.projection.Apply(suite.trade("tomato", 30, 10, suite.opening.Add(15*time.Minute)))
+
+	summary, ok := suite.projection.Summary("tomato", suite.opening, suite.opening.Add(15*time.Minute))
+	suite.Require().True(ok)
+
+	suite.Assert().True(decimal.NewFromFloat(21).Equal(summary.Open))
+	suite.Assert().True(decimal.NewFromFloat(25).Equal(summary.High))
+	suite.Assert().True(decimal.NewFromFloat(19).Equal(summary.Low))
+	suite.Assert().True(decimal.NewFromFloat(19).Equal(summary.Close))
+	suite.Assert().True(decimal.NewFromFloat(50).Equal(summary.Volume))
+	suite.Assert().Equal(3, summary.Trades)
+	suite.Assert().Equal("23.0000", summary.VWAP().StringFixed(4))
+
+	_, ok = suite.projection.Summary("tomato", suite.opening.Add(time.Hour), suite.opening.Add(2*time.Hour))
+	suite.Assert().False(ok)
+}
+
+func (suite *candlesSuite) TestWritesCSV() {
+	suite.projection.Apply(suite.trade("tomato", 20, 10, suite.opening))
+	suite.projection.Apply(suite.trade("tomato", 22, 10, suite.opening.Add(time.Second)))
+
+	var buf bytes.Buffer
+	suite.Require().NoError(candles.WriteCSV(&buf, suite.projection.Candles("tomato", time.Minute, suite.opening, suite.opening.Add(time.Minute))))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	suite.Require().Len(lines, 2)
+	suite.Assert().Equal("product,interval,start,end,open,high,low,close,volume,vwap,trades", lines[0])
+	suite.Assert().Equal("tomato,1m0s,2023-03-01T09:45:00Z,2023-03-01T09:46:00Z,20,22,20,22,20,21.0000,2", lines[1])
+}
+
+func (suite *candlesSuite) TestParsesIntervals() {
+	for value, expected := range map[string]time.Duration{"1m": time.Minute, "5m": 5 * time.Minute, "1h": time.Hour, "1d": 24 * time.Hour, "24h": 24 * time.Hour} {
+		interval, err := candles.ParseInterval(value)
+		suite.Require().NoError(err)
+		suite.Assert().Equal(expected, interval)
+	}
+
+	_, err := candles.ParseInterval("7m")
+	suite.Assert().Error(err)
+
+	_, err = candles.NewProjection(7 * time.Minute)
+	suite.Assert().Error(err)
+}
@@ -0,0 +1,252 @@
+package checkpoint
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type StreamHead struct {
+	Stream  string `json:"stream"`
+	Product string `json:"product"`
+	Version int    `json:"version"`
+	Hash    string `json:"hash"`
+}
+
+// Checkpoint pins the heads of the global and per stream hash chains at a sequence number. Once signed it can
+// be handed to counterparties, who can later check that the ledger they are shown still extends it.
+type Checkpoint struct {
+	Sequence  uint64       `json:"sequence"`
+	Hash      string       `json:"hash"`
+	Streams   []StreamHead `json:"streams"`
+	CreatedAt time.Time    `json:"created_at"`
+	PublicKey string       `json:"public_key,omitempty"`
+	Signature string       `json:"signature,omitempty"`
+}
+
+type heads struct {
+	sequence uint64
+	hash     string
+	streams  map[string]StreamHead
+}
+
+func (h *heads) add(records []event_sourcing.Record) {
+	for _, r := range records {
+		h.sequence, h.hash = r.Sequence, r.Hash
+		h.streams[r.Stream] = StreamHead{Stream: r.Stream, Product: r.Event.Product(), Version: r.Version, Hash: r.StreamHash}
+	}
+}
+
+func (h *heads) checkpoint(now time.Time) Checkpoint {
+	c := Checkpoint{Sequence: h.sequence, Hash: h.hash, Streams: make([]StreamHead, 0, len(h.streams)), CreatedAt: now.UTC()}
+	for _, head := range h.streams {
+		c.Streams = append(c.Streams, head)
+	}
+	sort.Slice(c.Streams, func(i, j int) bool { return c.Streams[i].Stream < c.Streams[j].Stream })
+	return c
+}
+
+// New builds an unsigned checkpoint at the last of records, which must be the whole log in global order.
+func New(records []event_sourcing.Record, now time.Time) Checkpoint {
+	h := &heads{streams: make(map[string]StreamHead)}
+	h.add(records)
+	return h.checkpoint(now)
+}
+
+func (c *Checkpoint) payload() ([]byte, error) {
+	unsigned := *c
+	unsigned.PublicKey, unsigned.Signature = "", ""
+	return json.Marshal(unsigned)
+}
+
+func (c *Checkpoint) Sign(key ed25519.PrivateKey) error {
+	payload, err := c.payload()
+	if err != nil {
+		return err
+	}
+
+	c.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return nil
+}
+
+// VerifySignature checks that the checkpoint was signed by the holder of key.
+func (c *Checkpoint) VerifySignature(key ed25519.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil {
+		return fmt.Errorf("signature: %w", err)
+	}
+
+	payload, err := c.payload()
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(key, payload, signature) {
+		return errors.New("checkpoint signature is invalid")
+	}
+	return nil
+}
+
+// Match checks that records, the whole log in global order, still contain the chain heads of the checkpoint.
+func (c *Checkpoint) Match(records []event_sourcing.Record) error {
+	if c.Sequence == 0 {
+		return nil
+	}
+	if uint64(len(records)) < c.Sequence {
+		return fmt.Errorf("checkpoint at sequence %d, but the ledger holds %d events", c.Sequence, len(records))
+	}
+
+	h := &heads{streams: make(map[string]StreamHead)}
+	h.add(records[:c.Sequence])
+
+	if h.hash != c.Hash {
+		return fmt.Errorf("hash at sequence %d is %s, checkpoint has %s", c.Sequence, h.hash, c.Hash)
+	}
+	for _, want := range c.Streams {
+		if got := h.streams[want.Stream]; got != want {
+			return fmt.Errorf("stream %s: head is version %d hash %s, checkpoint has version %d hash %s", want.Stream, got.Version, got.Hash, want.Version, want.Hash)
+		}
+	}
+	return nil
+}
+
+func Write(path string, c Checkpoint) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func Read(path string) (Checkpoint, error) {
+	var c Checkpoint
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// Writer signs and writes a checkpoint every `every` appended events. It is fed by subscribing Observe to
+// the repository.
+type Writer struct {
+	mtx   sync.Mutex
+	dir   string
+	key   ed25519.PrivateKey
+	every uint64
+	heads *heads
+	now   func() time.Time
+}
+
+func NewWriter(dir string, key ed25519.PrivateKey, every uint64, history []event_sourcing.Record) *Writer {
+	w := &Writer{dir: dir, key: key, every: every, heads: &heads{streams: make(map[string]StreamHead)}, now: time.Now}
+	w.heads.add(history)
+	return w
+}
+
+func (w *Writer) Observe(records []event_sourcing.Record) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for _, r := range records {
+		w.heads.add([]event_sourcing.Record{r})
+		if r.Sequence%w.every != 0 {
+			continue
+		}
+
+		c := w.heads.checkpoint(w.now())
+		if err := c.Sign(w.key); err != nil {
+			return err
+		}
+		if err := Write(filepath.Join(w.dir, fmt.Sprintf("checkpoint-%020d.json", r.Sequence)), c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateKey writes a new ed25519 key pair as PEM encoded PKCS #8 and PKIX files.
+func GenerateKey(privatePath, publicPath string) error {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644)
+}
+
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 private key", path)
+	}
+	return private, nil
+}
+
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 public key", path)
+	}
+	return public, nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: no %s block found", path, blockType)
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,89 @@
+package checkpoint_test
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/checkpoint"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/repository"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type checkpointSuite struct {
+	suite.Suite
+	dir  string
+	repo *repository.LedgerRepository
+}
+
+func TestCheckpointSuite(t *testing.T) {
+	suite.Run(t, new(checkpointSuite))
+}
+
+func (suite *checkpointSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	suite.Require().NoError(checkpoint.GenerateKey(filepath.Join(suite.dir, "key.pem"), filepath.Join(suite.dir, "key.pub")))
+
+	suite.repo = repository.NewWarehouseRepository()
+	suite.place("tomato-id", "tomato", 3)
+	suite.place("potato-id", "potato", 2)
+}
+
+func (suite *checkpointSuite) place(id, name string, orders int) {
+	p := suite.repo.Get(id, name)
+	for i := 0; i < orders; i++ {
+		err, _, _ := p.SupplyProduct(float64(20+i), 10)
+		suite.Require().NoError(err)
+	}
+	suite.Require().NoError(suite.repo.Save(p))
+}
+
+func (suite *checkpointSuite) TestSignedCheckpointMatchesLedger() {
+	private, err := checkpoint.LoadPrivateKey(filepath.Join(suite.dir, "key.pem"))
+	suite.Require().NoError(err)
+	public, err := checkpoint.LoadPublicKey(filepath.Join(suite.dir, "key.pub"))
+	suite.Require().NoError(err)
+
+	records := suite.repo.ReadAll(0)
+	c := checkpoint.New(records, time.Now())
+	suite.Require().NoError(c.Sign(private))
+
+	path := filepath.Join(suite.dir, "checkpoint.json")
+	suite.Require().NoError(checkpoint.Write(path, c))
+	exported, err := checkpoint.Read(path)
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal(uint64(5), exported.Sequence)
+	suite.Assert().Len(exported.Streams, 2)
+	suite.Assert().NoError(exported.VerifySignature(public))
+	suite.Assert().NoError(exported.Match(records))
+
+	suite.place("tomato-id", "tomato", 4)
+	suite.Assert().NoError(exported.Match(suite.repo.ReadAll(0)), "the ledger may grow past a checkpoint")
+
+	exported.Sequence = 4
+	suite.Assert().Error(exported.VerifySignature(public))
+	suite.Assert().Error(exported.Match(records))
+}
+
+func (suite *checkpointSuite) TestWriterCheckpointsPeriodically() {
+	private, err := checkpoint.LoadPrivateKey(filepath.Join(suite.dir, "key.pem"))
+	suite.Require().NoError(err)
+
+	out := filepath.Join(suite.dir, "checkpoints")
+	suite.Require().NoError(os.Mkdir(out, 0o755))
+
+	writer := checkpoint.NewWriter(out, private, 4, suite.repo.ReadAll(0))
+	suite.repo.Subscribe(func(records []event_sourcing.Record) { suite.Require().NoError(writer.Observe(records)) })
+	suite.place("potato-id", "potato", 4)
+
+	entries, err := os.ReadDir(out)
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 1)
+	suite.Assert().Equal("checkpoint-00000000000000000008.json", entries[0].Name())
+
+	c, err := checkpoint.Read(filepath.Join(out, entries[0].Name()))
+	suite.Require().NoError(err)
+	suite.Assert().NoError(c.Match(suite.repo.ReadAll(0)))
+}
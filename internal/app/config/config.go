@@ -0,0 +1,342 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fees"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/ingestion"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/circuit_breaker"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/instrument"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+const (
+	PriceTimePolicy = "price_time"
+	MemoryBackend   = "memory"
+	FileBackend     = "file"
+)
+
+type Config struct {
+	Environment string    `yaml:"environment"`
+	Products    []Product `yaml:"products"`
+	Matching    Matching  `yaml:"matching"`
+	Storage     Storage   `yaml:"storage"`
+	Funding     Funding   `yaml:"funding"`
+	Fees        Fees      `yaml:"fees"`
+	Ingestion   Ingestion `yaml:"ingestion"`
+	Server      Server    `yaml:"server"`
+	FIX         FIX       `yaml:"fix"`
+	Logging     Logging   `yaml:"logging"`
+}
+
+type Product struct {
+	Name           string          `yaml:"name"`
+	Instrument     instrument.Spec `yaml:"instrument"`
+	CircuitBreaker *CircuitBreaker `yaml:"circuit_breaker"`
+	Fees           *fees.Schedule  `yaml:"fees"`
+}
+
+type CircuitBreaker struct {
+	MaxMovePercent decimal.Decimal `yaml:"max_move_percent"`
+	Window         time.Duration   `yaml:"window"`
+	CoolOff        time.Duration   `yaml:"cool_off"`
+}
+
+// Matching holds engine wide defaults. The circuit breaker applies to every product that does not define its own.
+type Matching struct {
+	Policy         string          `yaml:"policy"`
+	CircuitBreaker *CircuitBreaker `yaml:"circuit_breaker"`
+}
+
+type Storage struct {
+	Backend    string      `yaml:"backend"`
+	Path       string      `yaml:"path"`
+	Checkpoint *Checkpoint `yaml:"checkpoint"`
+	// SnapshotEvery is the number of events between snapshots of a product's state, 0 disables snapshots.
+	SnapshotEvery int `yaml:"snapshot_every"`
+}
+
+// Checkpoint enables signed checkpoints of the event store, written to Dir every Every events.
+type Checkpoint struct {
+	Every uint64 `yaml:"every"`
+	Dir   string `yaml:"dir"`
+	Key   string `yaml:"key"`
+}
+
+// Funding enables pre-funding checks: orders are rejected unless their participant holds the available
+// inventory or cash to back them. Balances start from the opening balances and move with every trade.
+type Funding struct {
+	Enabled         bool             `yaml:"enabled"`
+	OpeningBalances []OpeningBalance `yaml:"opening_balances"`
+}
+
+type OpeningBalance struct {
+	Participant string                     `yaml:"participant"`
+	Cash        decimal.Decimal            `yaml:"cash"`
+	Inventory   map[string]decimal.Decimal `yaml:"inventory"`
+}
+
+// Fees charges every trade. Schedule applies to every product that does not define its own, volume
+// discounts look back over Window.
+type Fees struct {
+	Window   time.Duration  `yaml:"window"`
+	Schedule *fees.Schedule `yaml:"schedule"`
+}
+
+// Ingestion sets how order files are read. An empty format is detected from the file, Columns maps order
+// fields to the headers of CSV files.
+type Ingestion struct {
+	Format  string            `yaml:"format"`
+	Columns map[string]string `yaml:"columns"`
+}
+
+// Server holds the listen addresses of the APIs. The gRPC API is only served when GRPCAddr is set.
+type Server struct {
+	HTTPAddr string `yaml:"http_addr"`
+	GRPCAddr string `yaml:"grpc_addr"`
+}
+
+// FIX serves the FIX order entry gateway on Addr when it is set. Sessions must target CompID, and their
+// sequence numbers and open orders are kept in the Store file so that they resume after a restart.
+type FIX struct {
+	Addr      string        `yaml:"addr"`
+	CompID    string        `yaml:"comp_id"`
+	Store     string        `yaml:"store"`
+	Heartbeat time.Duration `yaml:"heartbeat"`
+}
+
+type Logging struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+func (c *Config) Product(name string) (Product, bool) {
+	for _, p := range c.Products {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Product{}, false
+}
+
+// CircuitBreakerFor returns the breaker settings of a product, falling back to the matching defaults.
+func (c *Config) CircuitBreakerFor(name string) (circuit_breaker.Config, bool) {
+	cb := c.Matching.CircuitBreaker
+	if p, ok := c.Product(name); ok && p.CircuitBreaker != nil {
+		cb = p.CircuitBreaker
+	}
+
+	if cb == nil {
+		return circuit_breaker.Config{}, false
+	}
+
+	return circuit_breaker.Config{MaxMovePercent: cb.MaxMovePercent, Window: cb.Window, CoolOff: cb.CoolOff}, true
+}
+
+// FeeScheduleFor returns the fee schedule of a product, falling back to the default schedule.
+func (c *Config) FeeScheduleFor(name string) (fees.Schedule, bool) {
+	schedule := c.Fees.Schedule
+	if p, ok := c.Product(name); ok && p.Fees != nil {
+		schedule = p.Fees
+	}
+
+	if schedule == nil {
+		return fees.Schedule{}, false
+	}
+	return *schedule, true
+}
+
+func (c *Config) Validate() error {
+	var result error
+
+	seen := make(map[string]bool, len(c.Products))
+	for i, p := range c.Products {
+		if p.Name == "" {
+			result = multierror.Append(result, fmt.Errorf("products[%d]: name is required", i))
+			continue
+		}
+		if seen[p.Name] {
+			result = multierror.Append(result, fmt.Errorf("products[%d]: duplicate product %s", i, p.Name))
+		}
+		seen[p.Name] = true
+
+		if err := validateInstrument(p.Instrument); err != nil {
+			result = multierror.Append(result, fmt.Errorf("product %s: %w", p.Name, err))
+		}
+		if err := validateCircuitBreaker(p.CircuitBreaker); err != nil {
+			result = multierror.Append(result, fmt.Errorf("product %s: %w", p.Name, err))
+		}
+		if err := validateFeeSchedule(p.Fees); err != nil {
+			result = multierror.Append(result, fmt.Errorf("product %s: fees: %w", p.Name, err))
+		}
+	}
+
+	if c.Matching.Policy != PriceTimePolicy {
+		result = multierror.Append(result, fmt.Errorf("matching: unsupported policy %q", c.Matching.Policy))
+	}
+	if err := validateCircuitBreaker(c.Matching.CircuitBreaker); err != nil {
+		result = multierror.Append(result, fmt.Errorf("matching: %w", err))
+	}
+
+	if c.Fees.Window < 0 {
+		result = multierror.Append(result, errors.New("fees: window must not be negative"))
+	}
+	if err := validateFeeSchedule(c.Fees.Schedule); err != nil {
+		result = multierror.Append(result, fmt.Errorf("fees: schedule: %w", err))
+	}
+
+	switch c.Storage.Backend {
+	case MemoryBackend:
+	case FileBackend:
+		if c.Storage.Path == "" {
+			result = multierror.Append(result, errors.New("storage: path is required by the file backend"))
+		}
+	default:
+		result = multierror.Append(result, fmt.Errorf("storage: unsupported backend %q", c.Storage.Backend))
+	}
+	if c.Storage.SnapshotEvery < 0 {
+		result = multierror.Append(result, errors.New("storage: snapshot_every must not be negative"))
+	}
+	if cp := c.Storage.Checkpoint; cp != nil {
+		if cp.Every == 0 {
+			result = multierror.Append(result, errors.New("storage: checkpoint.every must be positive"))
+		}
+		if cp.Dir == "" || cp.Key == "" {
+			result = multierror.Append(result, errors.New("storage: checkpoint.dir and checkpoint.key are required"))
+		}
+	}
+
+	seen = make(map[string]bool, len(c.Funding.OpeningBalances))
+	for i, ob := range c.Funding.OpeningBalances {
+		if ob.Participant == "" {
+			result = multierror.Append(result, fmt.Errorf("funding: opening_balances[%d]: participant is required", i))
+			continue
+		}
+		if seen[ob.Participant] {
+			result = multierror.Append(result, fmt.Errorf("funding: opening_balances[%d]: duplicate participant %s", i, ob.Participant))
+		}
+		seen[ob.Participant] = true
+
+		if ob.Cash.IsNegative() {
+			result = multierror.Append(result, fmt.Errorf("funding: %s: cash must not be negative", ob.Participant))
+		}
+		for name, qty := range ob.Inventory {
+			if qty.IsNegative() {
+				result = multierror.Append(result, fmt.Errorf("funding: %s: inventory of %s must not be negative", ob.Participant, name))
+			}
+			if _, ok := c.Product(name); len(c.Products) > 0 && !ok {
+				result = multierror.Append(result, fmt.Errorf("funding: %s: unknown product %s", ob.Participant, name))
+			}
+		}
+	}
+
+	if c.Ingestion.Format != "" {
+		if _, err := ingestion.ParseFormat(c.Ingestion.Format); err != nil {
+			result = multierror.Append(result, fmt.Errorf("ingestion: %w", err))
+		}
+	}
+	if err := ingestion.ValidateColumns(c.Ingestion.Columns); err != nil {
+		result = multierror.Append(result, fmt.Errorf("ingestion: %w", err))
+	}
+
+	if c.Server.HTTPAddr == "" {
+		result = multierror.Append(result, errors.New("server: http_addr is required"))
+	}
+
+	if c.FIX.Addr != "" {
+		if c.FIX.CompID == "" {
+			result = multierror.Append(result, errors.New("fix: comp_id is required"))
+		}
+		if c.FIX.Store == "" {
+			result = multierror.Append(result, errors.New("fix: store is required"))
+		}
+		if c.FIX.Heartbeat < 0 {
+			result = multierror.Append(result, errors.New("fix: heartbeat must not be negative"))
+		}
+	}
+
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		result = multierror.Append(result, fmt.Errorf("logging: unknown level %q", c.Logging.Level))
+	}
+
+	switch c.Logging.Format {
+	case logging.TextFormat, logging.JSONFormat:
+	default:
+		result = multierror.Append(result, fmt.Errorf("logging: unknown format %q", c.Logging.Format))
+	}
+
+	return result
+}
+
+func validateInstrument(spec instrument.Spec) error {
+	switch spec.Rounding {
+	case instrument.RejectPolicy, instrument.RoundPolicy:
+	default:
+		return fmt.Errorf("unknown rounding policy %q", spec.Rounding)
+	}
+
+	if spec.TickSize.IsNegative() {
+		return errors.New("tick_size must not be negative")
+	}
+	if spec.LotSize.IsNegative() {
+		return errors.New("lot_size must not be negative")
+	}
+	if spec.MinQty.IsNegative() {
+		return errors.New("min_qty must not be negative")
+	}
+
+	if spec.PricePrecision != nil && *spec.PricePrecision < 0 {
+		return errors.New("price_precision must not be negative")
+	}
+	if spec.QtyPrecision != nil && *spec.QtyPrecision < 0 {
+		return errors.New("qty_precision must not be negative")
+	}
+
+	return nil
+}
+
+func validateCircuitBreaker(cb *CircuitBreaker) error {
+	if cb == nil {
+		return nil
+	}
+
+	if !cb.MaxMovePercent.IsPositive() {
+		return errors.New("circuit_breaker.max_move_percent must be positive")
+	}
+	if cb.Window <= 0 {
+		return errors.New("circuit_breaker.window must be positive")
+	}
+	if cb.CoolOff < 0 {
+		return errors.New("circuit_breaker.cool_off must not be negative")
+	}
+
+	return nil
+}
+
+func validateFeeSchedule(s *fees.Schedule) error {
+	if s == nil {
+		return nil
+	}
+
+	if s.MakerRate.IsNegative() || s.TakerRate.IsNegative() {
+		return errors.New("maker_rate and taker_rate must not be negative")
+	}
+	if s.Fixed.IsNegative() || s.Minimum.IsNegative() {
+		return errors.New("fixed and minimum must not be negative")
+	}
+	for i, t := range s.Tiers {
+		if t.Volume.IsNegative() {
+			return fmt.Errorf("tiers[%d]: volume must not be negative", i)
+		}
+		if t.DiscountPercent.IsNegative() || t.DiscountPercent.GreaterThan(decimal.NewFromInt(100)) {
+			return fmt.Errorf("tiers[%d]: discount_percent must be between 0 and 100", i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package config_test
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/config"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/instrument"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const configDir = "../../../configs"
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := config.Load(configDir, "")
+	require.NoError(t, err)
+
+	tomato, ok := cfg.Product("tomato")
+	require.True(t, ok)
+	assert.True(t, decimal.NewFromFloat(0.01).Equal(tomato.Instrument.TickSize))
+	assert.Equal(t, instrument.RejectPolicy, tomato.Instrument.Rounding)
+
+	assert.Equal(t, config.PriceTimePolicy, cfg.Matching.Policy)
+	assert.Equal(t, config.MemoryBackend, cfg.Storage.Backend)
+	assert.Equal(t, ":8080", cfg.Server.HTTPAddr)
+	assert.Equal(t, ":9090", cfg.Server.GRPCAddr)
+	assert.Equal(t, "info", cfg.Logging.Level)
+	assert.Equal(t, "text", cfg.Logging.Format)
+
+	_, ok = cfg.CircuitBreakerFor("tomato")
+	assert.False(t, ok)
+}
+
+func TestLoad_LayersEnvironment(t *testing.T) {
+	cfg, err := config.Load(configDir, "local")
+	require.NoError(t, err)
+
+	assert.Equal(t, "local", cfg.Environment)
+	assert.Equal(t, "127.0.0.1:8080", cfg.Server.HTTPAddr)
+	assert.Equal(t, "debug", cfg.Logging.Level)
+	assert.Len(t, cfg.Products, 2)
+}
+
+func TestLoad_MergesProductsByName(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "default.yaml", `
+products:
+  - name: tomato
+    instrument:
+      tick_size: 0.5
+      lot_size: 1
+  - name: potato
+matching:
+  policy: price_time
+storage:
+  backend: memory
+server:
+  http_addr: ":8080"
+logging:
+  level: info
+  format: text
+`)
+	writeFile(t, dir, "staging.yaml", `
+products:
+  - name: tomato
+    instrument:
+      tick_size: 0.25
+    circuit_breaker:
+      max_move_percent: 5
+      window: 1m
+      cool_off: 10m
+  - name: onion
+`)
+
+	cfg, err := config.Load(dir, "staging")
+	require.NoError(t, err)
+	require.Len(t, cfg.Products, 3)
+
+	tomato, _ := cfg.Product("tomato")
+	assert.True(t, decimal.NewFromFloat(0.25).Equal(tomato.Instrument.TickSize))
+	assert.True(t, decimal.NewFromFloat(1).Equal(tomato.Instrument.LotSize))
+
+	cb, ok := cfg.CircuitBreakerFor("tomato")
+	require.True(t, ok)
+	assert.Equal(t, 10*time.Minute, cb.CoolOff)
+
+	_, ok = cfg.CircuitBreakerFor("potato")
+	assert.False(t, ok)
+
+	_, ok = cfg.Product("onion")
+	assert.True(t, ok)
+}
+
+func TestLoad_AppliesEnvironmentVariables(t *testing.T) {
+	t.Setenv("LEDGER_SERVER_HTTP_ADDR", "0.0.0.0:9090")
+	t.Setenv("LEDGER_LOGGING_LEVEL", "warn")
+
+	cfg, err := config.Load(configDir, "local")
+	require.NoError(t, err)
+
+	assert.Equal(t, "0.0.0.0:9090", cfg.Server.HTTPAddr)
+	assert.Equal(t, "warn", cfg.Logging.Level)
+}
+
+func TestLoad_RejectsInvalidConfiguration(t *testing.T) {
+	t.Setenv("LEDGER_STORAGE_BACKEND", "cassandra")
+	t.Setenv("LEDGER_LOGGING_LEVEL", "verbose")
+
+	_, err := config.Load(configDir, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cassandra")
+	assert.Contains(t, err.Error(), "verbose")
+}
+
+func TestLoad_FailsOnMissingEnvironment(t *testing.T) {
+	_, err := config.Load(configDir, "production")
+	require.Error(t, err)
+}
+
+func TestValidate_ReportsProductErrors(t *testing.T) {
+	cfg := &config.Config{
+		Products: []config.Product{
+			{Name: "tomato", Instrument: instrument.Spec{Rounding: "sometimes"}},
+			{Name: "tomato", Instrument: instrument.Spec{Rounding: instrument.RejectPolicy, TickSize: decimal.NewFromFloat(-1)}},
+			{Instrument: instrument.Spec{Rounding: instrument.RejectPolicy}},
+		},
+		Matching: config.Matching{Policy: config.PriceTimePolicy},
+		Storage:  config.Storage{Backend: config.MemoryBackend},
+		Server:   config.Server{HTTPAddr: ":8080"},
+		Logging:  config.Logging{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown rounding policy")
+	assert.Contains(t, err.Error(), "duplicate product tomato")
+	assert.Contains(t, err.Error(), "tick_size must not be negative")
+	assert.Contains(t, err.Error(), "name is required")
+}
+
+func TestValidate_ReportsStorageErrors(t *testing.T) {
+	cfg := &config.Config{
+		Matching: config.Matching{Policy: config.PriceTimePolicy},
+		Storage:  config.Storage{Backend: config.FileBackend, Checkpoint: &config.Checkpoint{Dir: "checkpoints"}},
+		Server:   config.Server{HTTPAddr: ":8080"},
+		Logging:  config.Logging{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "path is required by the file backend")
+	assert.Contains(t, err.Error(), "checkpoint.every must be positive")
+	assert.Contains(t, err.Error(), "checkpoint.dir and checkpoint.key are required")
+
+	cfg.Storage = config.Storage{Backend: config.FileBackend, Path: "ledger.jsonl", Checkpoint: &config.Checkpoint{Every: 100, Dir: "checkpoints", Key: "ledger.key"}}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_ReportsFundingErrors(t *testing.T) {
+	cfg := &config.Config{
+		Products: []config.Product{{Name: "tomato", Instrument: instrument.Spec{Rounding: instrument.RejectPolicy}}},
+		Matching: config.Matching{Policy: config.PriceTimePolicy},
+		Storage:  config.Storage{Backend: config.MemoryBackend},
+		Funding: config.Funding{Enabled: true, OpeningBalances: []config.OpeningBalance{
+			{Participant: "grower-1", Inventory: map[string]decimal.Decimal{"onion": decimal.NewFromInt(10)}},
+			{Participant: "grower-1", Cash: decimal.NewFromInt(-1)},
+			{Cash: decimal.NewFromInt(100)},
+		}},
+		Server:  config.Server{HTTPAddr: ":8080"},
+		Logging: config.Logging{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown product onion")
+	assert.Contains(t, err.Error(), "duplicate participant grower-1")
+	assert.Contains(t, err.Error(), "cash must not be negative")
+	assert.Contains(t, err.Error(), "participant is required")
+}
+
+func TestValidate_ReportsIngestionErrors(t *testing.T) {
+	cfg := &config.Config{
+		Matching:  config.Matching{Policy: config.PriceTimePolicy},
+		Storage:   config.Storage{Backend: config.MemoryBackend},
+		Ingestion: config.Ingestion{Format: "xml", Columns: map[string]string{"quantity": "Qty"}},
+		Server:    config.Server{HTTPAddr: ":8080"},
+		Logging:   config.Logging{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `ingestion: unknown input format "xml"`)
+	assert.Contains(t, err.Error(), `ingestion: unknown order field "quantity" in column mapping`)
+
+	cfg.Ingestion = config.Ingestion{Format: "CSV", Columns: map[string]string{"qty": "Quantity"}}
+	assert.NoError(t, cfg.Validate())
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+}
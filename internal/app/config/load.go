@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/instrument"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+)
+
+const (
+	EnvironmentVariable = "LEDGER_ENV"
+	DefaultFile         = "default.yaml"
+)
+
+var envOverrides = map[string]func(c *Config, value string){
+	"LEDGER_MATCHING_POLICY":  func(c *Config, value string) { c.Matching.Policy = value },
+	"LEDGER_STORAGE_BACKEND":  func(c *Config, value string) { c.Storage.Backend = value },
+	"LEDGER_STORAGE_PATH":     func(c *Config, value string) { c.Storage.Path = value },
+	"LEDGER_SERVER_HTTP_ADDR": func(c *Config, value string) { c.Server.HTTPAddr = value },
+	"LEDGER_LOGGING_LEVEL":    func(c *Config, value string) { c.Logging.Level = value },
+	"LEDGER_LOGGING_FORMAT":   func(c *Config, value string) { c.Logging.Format = value },
+}
+
+// Load reads default.yaml from dir, layers <environment>.yaml on top of it when an environment is given,
+// applies LEDGER_* environment variable overrides and validates the result. Products are merged by name.
+func Load(dir, environment string) (*Config, error) {
+	merged, err := readLayer(filepath.Join(dir, DefaultFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if environment != "" {
+		layer, err := readLayer(filepath.Join(dir, environment+".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeMaps(merged, layer)
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err = yaml.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+
+	cfg.Environment = environment
+	for i := range cfg.Products {
+		if cfg.Products[i].Instrument.Rounding == "" {
+			cfg.Products[i].Instrument.Rounding = instrument.RejectPolicy
+		}
+	}
+
+	for name, override := range envOverrides {
+		if value, ok := os.LookupEnv(name); ok {
+			override(cfg, value)
+		}
+	}
+
+	if err = cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func readLayer(path string) (map[string]interface{}, error) {
+	data, err := file_ops.Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	layer := make(map[string]interface{})
+	if err = yaml.Unmarshal([]byte(data), &layer); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return layer, nil
+}
+
+func mergeMaps(base, override map[string]interface{}) map[string]interface{} {
+	for key, value := range override {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if b, ok := base[key].(map[string]interface{}); ok {
+				base[key] = mergeMaps(b, v)
+				continue
+			}
+		case []interface{}:
+			if b, ok := base[key].([]interface{}); ok {
+				base[key] = mergeNamedLists(b, v)
+				continue
+			}
+		}
+		base[key] = value
+	}
+
+	return base
+}
+
+// mergeNamedLists merges lists of mappings by their name key, any other list is replaced.
+func mergeNamedLists(base, override []interface{}) []interface{} {
+	index := make(map[string]int, len(base))
+	for i, item := range base {
+		name, ok := nameOf(item)
+		if !ok {
+			return override
+		}
+		index[name] = i
+	}
+
+	for _, item := range override {
+		name, ok := nameOf(item)
+		if !ok {
+			return override
+		}
+
+		if i, found := index[name]; found {
+			base[i] = mergeMaps(base[i].(map[string]interface{}), item.(map[string]interface{}))
+			continue
+		}
+
+		index[name] = len(base)
+		base = append(base, item)
+	}
+
+	return base
+}
+
+func nameOf(item interface{}) (string, bool) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	name, ok := m["name"].(string)
+	return name, ok
+}
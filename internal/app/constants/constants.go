@@ -1,7 +1,42 @@
 package constants
 
 const (
-	OrderMismatchErrorMessage = "order did not match"
-	SupplyOrderType           = "SUPPLY"
-	DemandOrderType           = "DEMAND"
+	OrderMismatchErrorMessage        = "order did not match"
+	ProductHaltedErrorMessage        = "product is halted"
+	ProductNotHaltedErrorMessage     = "product is not halted"
+	OrderNotFoundErrorMessage        = "order not found"
+	IdempotencyKeyReusedErrorMessage = "idempotency key was already used for a different order"
+	StopPriceCrossedErrorMessage     = "stop price was already reached by the last trade price"
+	OrderNotOwnedErrorMessage        = "order belongs to another participant"
+	StopOrderAmendErrorMessage       = "stop orders cannot be amended, cancel and place them again"
+	SupplyOrderType                  = "SUPPLY"
+	DemandOrderType                  = "DEMAND"
+)
+
+const (
+	SupplyEventType  = "supply"
+	DemandEventType  = "demand"
+	TradeEventType   = "trade"
+	HaltEventType    = "halt"
+	ResumeEventType  = "resume"
+	CancelEventType  = "cancel"
+	RejectEventType  = "reject"
+	StopEventType    = "stop"
+	TriggerEventType = "trigger"
+	ExpireEventType  = "expire"
+)
+
+const (
+	PendingOrderStatus         = "pending"
+	NewOrderStatus             = "new"
+	PartiallyFilledOrderStatus = "partially_filled"
+	FilledOrderStatus          = "filled"
+	CancelledOrderStatus       = "cancelled"
+	ExpiredOrderStatus         = "expired"
+	RejectedOrderStatus        = "rejected"
+)
+
+const (
+	MakerLiquidity = "maker"
+	TakerLiquidity = "taker"
 )
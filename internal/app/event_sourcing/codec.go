@@ -0,0 +1,305 @@
+package event_sourcing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/shopspring/decimal"
+	"strconv"
+)
+
+// eventData is the canonical encoding of every event type. Fields are encoded in declaration order and
+// numbers as strings, so the same event always encodes to the same bytes.
+type eventData struct {
+	Type           string     `json:"type"`
+	Id             string     `json:"id"`
+	Product        string     `json:"product"`
+	OrderId        string     `json:"order_id,omitempty"`
+	Participant    string     `json:"participant,omitempty"`
+	Origin         string     `json:"origin,omitempty"`
+	IdempotencyKey string     `json:"idempotency_key,omitempty"`
+	Side           string     `json:"side,omitempty"`
+	Price          string     `json:"price,omitempty"`
+	Qty            string     `json:"qty,omitempty"`
+	Peak           string     `json:"peak,omitempty"`
+	MinQty         string     `json:"min_qty,omitempty"`
+	AllOrNone      bool       `json:"all_or_none,omitempty"`
+	StopPrice      string     `json:"stop_price,omitempty"`
+	ReferencePrice string     `json:"reference_price,omitempty"`
+	LastPrice      string     `json:"last_price,omitempty"`
+	Supply         *orderData `json:"supply,omitempty"`
+	Demand         *orderData `json:"demand,omitempty"`
+	SupplyFee      *feeData   `json:"supply_fee,omitempty"`
+	DemandFee      *feeData   `json:"demand_fee,omitempty"`
+	Automatic      bool       `json:"automatic,omitempty"`
+	Reason         string     `json:"reason,omitempty"`
+	Timestamp      int64      `json:"timestamp"`
+}
+
+type orderData struct {
+	Id          string `json:"id"`
+	Participant string `json:"participant,omitempty"`
+	Price       string `json:"price"`
+	Qty         string `json:"qty"`
+	OrderType   string `json:"order_type"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+type feeData struct {
+	Liquidity string `json:"liquidity"`
+	Amount    string `json:"amount"`
+}
+
+// Marshal returns the canonical encoding of an event.
+func Marshal(ev Event) ([]byte, error) {
+	var data eventData
+
+	switch e := ev.(type) {
+	case productSupplyEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, OrderId: e.details.orderId, Participant: e.details.participant, Origin: e.details.origin, IdempotencyKey: e.details.key, Price: formatFloat(e.price), Qty: formatFloat(e.qty), Peak: formatOptional(e.details.peak), MinQty: formatOptional(e.details.minQty), AllOrNone: e.details.allOrNone, Timestamp: e.timestamp}
+	case productDemandEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, OrderId: e.details.orderId, Participant: e.details.participant, Origin: e.details.origin, IdempotencyKey: e.details.key, Price: formatFloat(e.price), Qty: formatFloat(e.qty), Peak: formatOptional(e.details.peak), MinQty: formatOptional(e.details.minQty), AllOrNone: e.details.allOrNone, Timestamp: e.timestamp}
+	case tradeEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, Supply: toOrderData(e.supply), Demand: toOrderData(e.demand), SupplyFee: toFeeData(e.supplyFee), DemandFee: toFeeData(e.demandFee), Timestamp: e.timestamp}
+	case productHaltEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, ReferencePrice: e.referencePrice.String(), Price: e.price.String(), Timestamp: e.timestamp}
+	case productResumeEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, Automatic: e.automatic, Timestamp: e.timestamp}
+	case productCancelEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, OrderId: e.orderId, Timestamp: e.timestamp}
+	case productExpireEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, OrderId: e.orderId, Timestamp: e.timestamp}
+	case productRejectEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, OrderId: e.details.orderId, Participant: e.details.participant, Origin: e.details.origin, IdempotencyKey: e.details.key, Side: e.orderType, Price: formatFloat(e.price), Qty: formatFloat(e.qty), Peak: formatOptional(e.details.peak), MinQty: formatOptional(e.details.minQty), AllOrNone: e.details.allOrNone, Reason: e.reason, Timestamp: e.timestamp}
+	case productStopEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, OrderId: e.details.orderId, Participant: e.details.participant, Origin: e.details.origin, IdempotencyKey: e.details.key, Side: e.orderType, Price: formatFloat(e.price), Qty: formatFloat(e.qty), Peak: formatOptional(e.details.peak), MinQty: formatOptional(e.details.minQty), AllOrNone: e.details.allOrNone, StopPrice: formatFloat(e.stopPrice), Timestamp: e.timestamp}
+	case productTriggerEvent:
+		data = eventData{Id: e.id.String(), Product: e.productName, OrderId: e.stop.Id, Participant: e.stop.Participant, Side: e.stop.OrderType, Price: e.stop.Price.String(), Qty: e.stop.Qty.String(), StopPrice: e.stop.StopPrice.String(), LastPrice: e.lastPrice.String(), Timestamp: e.timestamp}
+		if e.stop.Peak.IsPositive() {
+			data.Peak = e.stop.Peak.String()
+		}
+		if e.stop.MinQty.IsPositive() {
+			data.MinQty = e.stop.MinQty.String()
+		}
+		data.AllOrNone = e.stop.AllOrNone
+	default:
+		return nil, fmt.Errorf("cannot encode event of type %T", ev)
+	}
+
+	data.Type = ev.Type()
+	return json.Marshal(data)
+}
+
+func Unmarshal(b []byte) (Event, error) {
+	var data eventData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(data.Id)
+	if err != nil {
+		return nil, fmt.Errorf("event id: %w", err)
+	}
+
+	switch data.Type {
+	case constants.SupplyEventType, constants.DemandEventType:
+		price, err := strconv.ParseFloat(data.Price, 64)
+		if err != nil {
+			return nil, fmt.Errorf("price: %w", err)
+		}
+		qty, err := strconv.ParseFloat(data.Qty, 64)
+		if err != nil {
+			return nil, fmt.Errorf("qty: %w", err)
+		}
+
+		details, err := decodeOrderDetails(data)
+		if err != nil {
+			return nil, err
+		}
+		if data.Type == constants.SupplyEventType {
+			return productSupplyEvent{id: id, productName: data.Product, details: details, price: price, qty: qty, timestamp: data.Timestamp}, nil
+		}
+		return productDemandEvent{id: id, productName: data.Product, details: details, price: price, qty: qty, timestamp: data.Timestamp}, nil
+	case constants.TradeEventType:
+		supply, err := fromOrderData(data.Supply)
+		if err != nil {
+			return nil, fmt.Errorf("supply: %w", err)
+		}
+		demand, err := fromOrderData(data.Demand)
+		if err != nil {
+			return nil, fmt.Errorf("demand: %w", err)
+		}
+		supplyFee, err := fromFeeData(data.SupplyFee)
+		if err != nil {
+			return nil, fmt.Errorf("supply fee: %w", err)
+		}
+		demandFee, err := fromFeeData(data.DemandFee)
+		if err != nil {
+			return nil, fmt.Errorf("demand fee: %w", err)
+		}
+		return tradeEvent{id: id, productName: data.Product, supply: supply, demand: demand, supplyFee: supplyFee, demandFee: demandFee, timestamp: data.Timestamp}, nil
+	case constants.HaltEventType:
+		referencePrice, err := decimal.NewFromString(data.ReferencePrice)
+		if err != nil {
+			return nil, fmt.Errorf("reference price: %w", err)
+		}
+		price, err := decimal.NewFromString(data.Price)
+		if err != nil {
+			return nil, fmt.Errorf("price: %w", err)
+		}
+		return productHaltEvent{id: id, productName: data.Product, referencePrice: referencePrice, price: price, timestamp: data.Timestamp}, nil
+	case constants.ResumeEventType:
+		return productResumeEvent{id: id, productName: data.Product, automatic: data.Automatic, timestamp: data.Timestamp}, nil
+	case constants.CancelEventType:
+		return productCancelEvent{id: id, productName: data.Product, orderId: data.OrderId, timestamp: data.Timestamp}, nil
+	case constants.ExpireEventType:
+		return productExpireEvent{id: id, productName: data.Product, orderId: data.OrderId, timestamp: data.Timestamp}, nil
+	case constants.RejectEventType:
+		price, err := strconv.ParseFloat(data.Price, 64)
+		if err != nil {
+			return nil, fmt.Errorf("price: %w", err)
+		}
+		qty, err := strconv.ParseFloat(data.Qty, 64)
+		if err != nil {
+			return nil, fmt.Errorf("qty: %w", err)
+		}
+
+		details, err := decodeOrderDetails(data)
+		if err != nil {
+			return nil, err
+		}
+		return productRejectEvent{id: id, productName: data.Product, orderType: data.Side, details: details, price: price, qty: qty, reason: data.Reason, timestamp: data.Timestamp}, nil
+	case constants.StopEventType:
+		stopPrice, err := strconv.ParseFloat(data.StopPrice, 64)
+		if err != nil {
+			return nil, fmt.Errorf("stop price: %w", err)
+		}
+		price, err := strconv.ParseFloat(data.Price, 64)
+		if err != nil {
+			return nil, fmt.Errorf("price: %w", err)
+		}
+		qty, err := strconv.ParseFloat(data.Qty, 64)
+		if err != nil {
+			return nil, fmt.Errorf("qty: %w", err)
+		}
+
+		details, err := decodeOrderDetails(data)
+		if err != nil {
+			return nil, err
+		}
+		return productStopEvent{id: id, productName: data.Product, orderType: data.Side, details: details, stopPrice: stopPrice, price: price, qty: qty, timestamp: data.Timestamp}, nil
+	case constants.TriggerEventType:
+		price, err := decimal.NewFromString(data.Price)
+		if err != nil {
+			return nil, fmt.Errorf("price: %w", err)
+		}
+		qty, err := decimal.NewFromString(data.Qty)
+		if err != nil {
+			return nil, fmt.Errorf("qty: %w", err)
+		}
+		stopPrice, err := decimal.NewFromString(data.StopPrice)
+		if err != nil {
+			return nil, fmt.Errorf("stop price: %w", err)
+		}
+
+		stop := order.Order{Id: data.OrderId, Participant: data.Participant, Price: price, Qty: qty, OrderType: data.Side, StopPrice: stopPrice, AllOrNone: data.AllOrNone}
+		if data.Peak != "" {
+			peak, err := decimal.NewFromString(data.Peak)
+			if err != nil {
+				return nil, fmt.Errorf("peak: %w", err)
+			}
+			stop.Peak = peak
+		}
+		if data.MinQty != "" {
+			minQty, err := decimal.NewFromString(data.MinQty)
+			if err != nil {
+				return nil, fmt.Errorf("min qty: %w", err)
+			}
+			stop.MinQty = minQty
+		}
+		lastPrice, err := decimal.NewFromString(data.LastPrice)
+		if err != nil {
+			return nil, fmt.Errorf("last price: %w", err)
+		}
+		return productTriggerEvent{id: id, productName: data.Product, stop: stop, lastPrice: lastPrice, timestamp: data.Timestamp}, nil
+	default:
+		return nil, fmt.Errorf("unknown event type %q", data.Type)
+	}
+}
+
+func decodeOrderDetails(data eventData) (orderDetails, error) {
+	details := orderDetails{orderId: data.OrderId, participant: data.Participant, origin: data.Origin, key: data.IdempotencyKey, allOrNone: data.AllOrNone}
+	if data.Peak != "" {
+		peak, err := strconv.ParseFloat(data.Peak, 64)
+		if err != nil {
+			return orderDetails{}, fmt.Errorf("peak: %w", err)
+		}
+		details.peak = peak
+	}
+	if data.MinQty != "" {
+		minQty, err := strconv.ParseFloat(data.MinQty, 64)
+		if err != nil {
+			return orderDetails{}, fmt.Errorf("min qty: %w", err)
+		}
+		details.minQty = minQty
+	}
+	return details, nil
+}
+
+func toOrderData(o *order.Order) *orderData {
+	return &orderData{Id: o.Id, Participant: o.Participant, Price: o.Price.String(), Qty: o.Qty.String(), OrderType: o.OrderType, Timestamp: o.Timestamp}
+}
+
+func fromOrderData(data *orderData) (*order.Order, error) {
+	if data == nil {
+		return nil, errors.New("missing order")
+	}
+
+	price, err := decimal.NewFromString(data.Price)
+	if err != nil {
+		return nil, err
+	}
+	qty, err := decimal.NewFromString(data.Qty)
+	if err != nil {
+		return nil, err
+	}
+
+	return &order.Order{Id: data.Id, Participant: data.Participant, Price: price, Qty: qty, OrderType: data.OrderType, Timestamp: data.Timestamp}, nil
+}
+
+// toFeeData leaves out fees of trades recorded without them, so their encoding stays what it was before
+// trades carried fees.
+func toFeeData(f Fee) *feeData {
+	if f.Liquidity == "" {
+		return nil
+	}
+	return &feeData{Liquidity: f.Liquidity, Amount: f.Amount.String()}
+}
+
+func fromFeeData(data *feeData) (Fee, error) {
+	if data == nil {
+		return Fee{}, nil
+	}
+
+	amount, err := decimal.NewFromString(data.Amount)
+	if err != nil {
+		return Fee{}, err
+	}
+	return Fee{Liquidity: data.Liquidity, Amount: amount}, nil
+}
+
+// formatOptional leaves out the peak of orders that are not icebergs, and the minimum fill of orders without one,
+// so their encoding stays what it was before orders had them.
+func formatOptional(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return formatFloat(v)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -1,11 +1,128 @@
 package event_sourcing
 
 import (
+	"github.com/google/uuid"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/current_state"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/shopspring/decimal"
+	"log/slog"
+	"strconv"
+	"time"
 )
 
+// Event is a fact recorded against a product. Events render themselves as a slog group, so they can be
+// logged with any handler as slog.Any("event", ev).
 type Event interface {
 	Apply(currState *current_state.CurrentState) (error, []*order.Order, []*order.Order)
-	Display()
+	Type() string
+	Product() string
+	LogValue() slog.Value
+}
+
+// OrderEvent places an order. Order returns it as it entered the book, before any matching, Origin where
+// it was read from and IdempotencyKey the key its participant tagged it with, if they were recorded.
+type OrderEvent interface {
+	Event
+	Order() order.Order
+	Origin() string
+	IdempotencyKey() string
+}
+
+// RejectEvent records an order that was refused. Order returns it as it was submitted, Reason why it was
+// refused and Origin where it was read from. Rejected orders are not OrderEvents, they never placed anything.
+type RejectEvent interface {
+	Event
+	Order() order.Order
+	Reason() string
+	Origin() string
+}
+
+// TriggerEvent records a stop order that the last trade price reached. Order returns the limit or market order
+// it became and LastPrice the price that triggered it.
+type TriggerEvent interface {
+	Event
+	Order() order.Order
+	LastPrice() decimal.Decimal
+}
+
+// CancelEvent takes an order out of its book, cancelled by its participant or, as an expire event, withdrawn by
+// the ledger.
+type CancelEvent interface {
+	Event
+	OrderId() string
+}
+
+// Fee is what one side of a trade was charged. Liquidity is constants.MakerLiquidity for the resting order
+// and constants.TakerLiquidity for the order that crossed the spread. A trade recorded without fees has
+// zero fees with no liquidity.
+type Fee struct {
+	Liquidity string
+	Amount    decimal.Decimal
+}
+
+// Trade is the execution recorded by a trade event. Trades execute at the supply price.
+type Trade struct {
+	Id                string
+	Product           string
+	SupplyOrderId     string
+	SupplyParticipant string
+	DemandOrderId     string
+	DemandParticipant string
+	Price             decimal.Decimal
+	Qty               decimal.Decimal
+	SupplyFee         Fee
+	DemandFee         Fee
+	Timestamp         time.Time
+}
+
+// TradeEvent records a trade. Orders returns the matched orders, each with the traded quantity.
+type TradeEvent interface {
+	Event
+	Trade() Trade
+	Orders() (supply order.Order, demand order.Order)
+}
+
+// peakOf returns the peak of an order placed with details, zero unless it is an iceberg order.
+func peakOf(details orderDetails) decimal.Decimal {
+	if details.peak == 0 {
+		return decimal.Decimal{}
+	}
+	return decimal.NewFromFloat(details.peak)
+}
+
+// minQtyOf returns the minimum fill of an order placed with details, zero unless it has one.
+func minQtyOf(details orderDetails) decimal.Decimal {
+	if details.minQty == 0 {
+		return decimal.Decimal{}
+	}
+	return decimal.NewFromFloat(details.minQty)
+}
+
+func orderAttrs(eventType string, id uuid.UUID, productName string, details orderDetails, price, qty float64, timestamp int64) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("type", eventType),
+		slog.String("id", id.String()),
+		slog.String("product", productName),
+		slog.String("order_id", details.orderId),
+		slog.String("participant", details.participant),
+		slog.String("price", strconv.FormatFloat(price, 'f', -1, 64)),
+		slog.String("qty", strconv.FormatFloat(qty, 'f', -1, 64)),
+		slog.Time("at", time.Unix(0, timestamp)),
+	}
+	if details.origin != "" {
+		attrs = append(attrs, slog.String("origin", details.origin))
+	}
+	if details.key != "" {
+		attrs = append(attrs, slog.String("idempotency_key", details.key))
+	}
+	if details.peak != 0 {
+		attrs = append(attrs, slog.String("peak", strconv.FormatFloat(details.peak, 'f', -1, 64)))
+	}
+	if details.minQty != 0 {
+		attrs = append(attrs, slog.String("min_qty", strconv.FormatFloat(details.minQty, 'f', -1, 64)))
+	}
+	if details.allOrNone {
+		attrs = append(attrs, slog.Bool("all_or_none", true))
+	}
+	return attrs
 }
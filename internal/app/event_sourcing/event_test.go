@@ -0,0 +1,62 @@
+package event_sourcing_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+func TestEvent_RendersStructuredFields(t *testing.T) {
+	tests := []struct {
+		event    event_sourcing.Event
+		expected map[string]interface{}
+	}{
+		{
+			event: event_sourcing.NewProductSupplyEvent("tomato", 24, 100, event_sourcing.WithOrderId("s1"), event_sourcing.WithParticipant("grower-1")),
+			expected: map[string]interface{}{
+				"type": constants.SupplyEventType, "product": "tomato", "order_id": "s1", "participant": "grower-1", "price": "24", "qty": "100",
+			},
+		},
+		{
+			event: event_sourcing.NewProductDemandEvent("potato", 110.5, 10, event_sourcing.WithOrderId("d2")),
+			expected: map[string]interface{}{
+				"type": constants.DemandEventType, "product": "potato", "order_id": "d2", "price": "110.5", "qty": "10",
+			},
+		},
+		{
+			event: event_sourcing.NewTradeEvent("tomato",
+				&order.Order{Id: "s2", Participant: "grower-2", Price: decimal.NewFromFloat(20), Qty: decimal.NewFromFloat(90)},
+				&order.Order{Id: "d1", Participant: "buyer-1", Price: decimal.NewFromFloat(22), Qty: decimal.NewFromFloat(90)}),
+			expected: map[string]interface{}{
+				"type": constants.TradeEventType, "product": "tomato", "supply_id": "s2", "demand_id": "d1",
+				"supply_participant": "grower-2", "demand_participant": "buyer-1", "price": "20", "qty": "90",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, nil))
+		logger.Info("event recorded", slog.Any("event", tt.event))
+
+		var record struct {
+			Event map[string]interface{} `json:"event"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+
+		rendered := record.Event
+		require.NotNil(t, rendered)
+		assert.NotEmpty(t, rendered["id"])
+		assert.NotEmpty(t, rendered["at"])
+		for key, value := range tt.expected {
+			assert.Equal(t, value, rendered[key], key)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package event_sourcing
+
+type OrderOption func(d *orderDetails)
+
+type orderDetails struct {
+	orderId     string
+	participant string
+	origin      string
+	key         string
+	peak        float64
+	minQty      float64
+	allOrNone   bool
+}
+
+func WithOrderId(id string) OrderOption {
+	return func(d *orderDetails) {
+		d.orderId = id
+	}
+}
+
+func WithParticipant(participant string) OrderOption {
+	return func(d *orderDetails) {
+		d.participant = participant
+	}
+}
+
+// WithOrigin records where an order was read from, such as a line of an ingested file.
+func WithOrigin(origin string) OrderOption {
+	return func(d *orderDetails) {
+		d.origin = origin
+	}
+}
+
+// WithIdempotencyKey tags an order with a key chosen by its participant, so that a retried submission is
+// recognised instead of placing the order twice.
+func WithIdempotencyKey(key string) OrderOption {
+	return func(d *orderDetails) {
+		d.key = key
+	}
+}
+
+// WithPeak makes an iceberg order that shows only peak of its quantity in the book. Whenever the shown slice
+// fills, the next one is cut from the hidden reserve and queues behind the orders already in the book.
+func WithPeak(peak float64) OrderOption {
+	return func(d *orderDetails) {
+		d.peak = peak
+	}
+}
+
+// WithMinQty refuses to fill less than minQty of an order at once, unless less than that is left of it. An
+// incoming order sweeps the book only if it fills that much across the counterparties it takes, iceberg
+// reserves included. A resting order is skipped by the incoming orders that cannot fill that much of it.
+func WithMinQty(minQty float64) OrderOption {
+	return func(d *orderDetails) {
+		d.minQty = minQty
+	}
+}
+
+// WithAllOrNone makes an order that only fills its whole quantity at once, like a minimum fill of all of it.
+func WithAllOrNone() OrderOption {
+	return func(d *orderDetails) {
+		d.allOrNone = true
+	}
+}
+
+func newOrderDetails(defaultId string, opts []OrderOption) orderDetails {
+	d := orderDetails{orderId: defaultId}
+	for _, opt := range opts {
+		opt(&d)
+	}
+	return d
+}
+
+type TradeOption func(te *tradeEvent)
+
+// WithFees records the fees charged to each side of a trade.
+func WithFees(supply, demand Fee) TradeOption {
+	return func(te *tradeEvent) {
+		te.supplyFee, te.demandFee = supply, demand
+	}
+}
@@ -7,39 +7,47 @@ import (
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/current_state"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order_book"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/stop_book"
 	"github.com/shopspring/decimal"
-	"log"
+	"log/slog"
 	"strings"
+	"time"
 )
 
 type productSupplyEvent struct {
 	id          uuid.UUID
 	productName string
+	details     orderDetails
 	price       float64
 	qty         float64
-	status      string
 	timestamp   int64
 }
 
-func NewProductSupplyEvent(productName string, price, quantity float64) Event {
+func NewProductSupplyEvent(productName string, price, quantity float64, opts ...OrderOption) Event {
+	id := uuid.New()
 	return productSupplyEvent{
-		id:          uuid.New(),
+		id:          id,
 		productName: productName,
+		details:     newOrderDetails(id.String(), opts),
 		price:       price,
 		qty:         quantity,
+		timestamp:   time.Now().UnixNano(),
 	}
 }
 
 func (pse productSupplyEvent) Apply(state *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
-	newSupplyOrder := &order.Order{
-		Id:        uuid.New().String(),
-		Price:     decimal.NewFromFloat(pse.price),
-		Qty:       decimal.NewFromFloat(pse.qty),
-		OrderType: constants.SupplyOrderType,
+	if state.CircuitBreaker != nil && state.CircuitBreaker.IsHalted() {
+		return errors.New(constants.ProductHaltedErrorMessage), nil, nil
 	}
 
-	_ = state.OrderBook.Update(nil, []*order.Order{newSupplyOrder})
-	d, s := matchOrder(state.OrderBook, newSupplyOrder)
+	newSupplyOrder := pse.Order()
+
+	_ = state.OrderBook.Update(nil, []*order.Order{&newSupplyOrder})
+	// the remainder of an order that halted the product is expired by the product
+	d, s, halted := matchOrder(state, &newSupplyOrder, false, decimal.Decimal{})
+	if !halted {
+		hideReserve(state.OrderBook, &newSupplyOrder)
+	}
 
 	if len(d) == 0 && len(s) == 0 {
 		return errors.New(constants.OrderMismatchErrorMessage), nil, nil
@@ -48,38 +56,74 @@ func (pse productSupplyEvent) Apply(state *current_state.CurrentState) (error, [
 	return nil, d, s
 }
 
-func (pse productSupplyEvent) Display() {
-	log.Printf("Supply order for product (%s) registered with quantity: %v, status: %s at %d\n", pse.productName, pse.qty, pse.status, pse.timestamp)
+func (pse productSupplyEvent) Type() string {
+	return constants.SupplyEventType
+}
+
+func (pse productSupplyEvent) Product() string {
+	return pse.productName
+}
+
+func (pse productSupplyEvent) Order() order.Order {
+	return order.Order{
+		Id:          pse.details.orderId,
+		Participant: pse.details.participant,
+		Price:       decimal.NewFromFloat(pse.price),
+		Qty:         decimal.NewFromFloat(pse.qty),
+		OrderType:   constants.SupplyOrderType,
+		Timestamp:   pse.timestamp,
+		Peak:        peakOf(pse.details),
+		MinQty:      minQtyOf(pse.details),
+		AllOrNone:   pse.details.allOrNone,
+	}
+}
+
+func (pse productSupplyEvent) Origin() string {
+	return pse.details.origin
+}
+
+func (pse productSupplyEvent) IdempotencyKey() string {
+	return pse.details.key
+}
+
+func (pse productSupplyEvent) LogValue() slog.Value {
+	return slog.GroupValue(orderAttrs(pse.Type(), pse.id, pse.productName, pse.details, pse.price, pse.qty, pse.timestamp)...)
 }
 
 type productDemandEvent struct {
 	id          uuid.UUID
 	productName string
+	details     orderDetails
 	price       float64
 	qty         float64
-	status      string
 	timestamp   int64
 }
 
-func NewProductDemandEvent(productName string, price, quantity float64) Event {
+func NewProductDemandEvent(productName string, price, quantity float64, opts ...OrderOption) Event {
+	id := uuid.New()
 	return productDemandEvent{
-		id:          uuid.New(),
+		id:          id,
 		productName: productName,
+		details:     newOrderDetails(id.String(), opts),
 		price:       price,
 		qty:         quantity,
+		timestamp:   time.Now().UnixNano(),
 	}
 }
 
 func (pde productDemandEvent) Apply(state *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
-	newDemandOrder := &order.Order{
-		Id:        uuid.New().String(),
-		Price:     decimal.NewFromFloat(pde.price),
-		Qty:       decimal.NewFromFloat(pde.qty),
-		OrderType: constants.DemandOrderType,
+	if state.CircuitBreaker != nil && state.CircuitBreaker.IsHalted() {
+		return errors.New(constants.ProductHaltedErrorMessage), nil, nil
 	}
 
-	_ = state.OrderBook.Update([]*order.Order{newDemandOrder}, nil)
-	d, s := matchOrder(state.OrderBook, newDemandOrder)
+	newDemandOrder := pde.Order()
+
+	_ = state.OrderBook.Update([]*order.Order{&newDemandOrder}, nil)
+	// the remainder of an order that halted the product is expired by the product
+	d, s, halted := matchOrder(state, &newDemandOrder, false, decimal.Decimal{})
+	if !halted {
+		hideReserve(state.OrderBook, &newDemandOrder)
+	}
 
 	if len(d) == 0 && len(s) == 0 {
 		return errors.New(constants.OrderMismatchErrorMessage), nil, nil
@@ -88,40 +132,605 @@ func (pde productDemandEvent) Apply(state *current_state.CurrentState) (error, [
 	return nil, d, s
 }
 
-func (pde productDemandEvent) Display() {
-	log.Printf("Demand order for product (%s) registered with quantity: %v, status: %s at %d\n", pde.productName, pde.qty, pde.status, pde.timestamp)
+func (pde productDemandEvent) Type() string {
+	return constants.DemandEventType
+}
+
+func (pde productDemandEvent) Product() string {
+	return pde.productName
+}
+
+func (pde productDemandEvent) Order() order.Order {
+	return order.Order{
+		Id:          pde.details.orderId,
+		Participant: pde.details.participant,
+		Price:       decimal.NewFromFloat(pde.price),
+		Qty:         decimal.NewFromFloat(pde.qty),
+		OrderType:   constants.DemandOrderType,
+		Timestamp:   pde.timestamp,
+		Peak:        peakOf(pde.details),
+		MinQty:      minQtyOf(pde.details),
+		AllOrNone:   pde.details.allOrNone,
+	}
+}
+
+func (pde productDemandEvent) Origin() string {
+	return pde.details.origin
+}
+
+func (pde productDemandEvent) IdempotencyKey() string {
+	return pde.details.key
+}
+
+func (pde productDemandEvent) LogValue() slog.Value {
+	return slog.GroupValue(orderAttrs(pde.Type(), pde.id, pde.productName, pde.details, pde.price, pde.qty, pde.timestamp)...)
 }
 
 type tradeEvent struct {
-	id     uuid.UUID
-	supply *order.Order
-	demand *order.Order
+	id          uuid.UUID
+	productName string
+	supply      *order.Order
+	demand      *order.Order
+	supplyFee   Fee
+	demandFee   Fee
+	timestamp   int64
+}
+
+// NewTradeEvent records a match between two orders. The trade happens when the later of the two orders
+// arrived, so replaying or re-projecting the ledger yields the same trade times.
+func NewTradeEvent(productName string, supplyEvent *order.Order, demandEvent *order.Order, opts ...TradeOption) Event {
+	timestamp := supplyEvent.Timestamp
+	if demandEvent.Timestamp > timestamp {
+		timestamp = demandEvent.Timestamp
+	}
+	if timestamp == 0 {
+		timestamp = time.Now().UnixNano()
+	}
+
+	te := tradeEvent{
+		id:          uuid.New(),
+		productName: productName,
+		supply:      supplyEvent,
+		demand:      demandEvent,
+		timestamp:   timestamp,
+	}
+	for _, opt := range opts {
+		opt(&te)
+	}
+	return te
+}
+
+// Apply moves the last trade price. It does not trigger the stop orders the price reaches, the product does
+// that once every trade of a placement is recorded.
+func (te tradeEvent) Apply(state *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
+	state.LastPrice = te.supply.Price
+	return nil, nil, nil
+}
+
+func (te tradeEvent) Trade() Trade {
+	return Trade{
+		Id:                te.id.String(),
+		Product:           te.productName,
+		SupplyOrderId:     te.supply.Id,
+		SupplyParticipant: te.supply.Participant,
+		DemandOrderId:     te.demand.Id,
+		DemandParticipant: te.demand.Participant,
+		Price:             te.supply.Price,
+		Qty:               te.supply.Qty,
+		SupplyFee:         te.supplyFee,
+		DemandFee:         te.demandFee,
+		Timestamp:         time.Unix(0, te.timestamp),
+	}
+}
+
+func (te tradeEvent) Orders() (order.Order, order.Order) {
+	return *te.supply, *te.demand
+}
+
+func (te tradeEvent) Type() string {
+	return constants.TradeEventType
+}
+
+func (te tradeEvent) Product() string {
+	return te.productName
+}
+
+func (te tradeEvent) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("type", te.Type()),
+		slog.String("id", te.id.String()),
+		slog.String("product", te.productName),
+		slog.String("supply_id", te.supply.Id),
+		slog.String("supply_participant", te.supply.Participant),
+		slog.String("demand_id", te.demand.Id),
+		slog.String("demand_participant", te.demand.Participant),
+		slog.String("price", te.supply.Price.String()),
+		slog.String("qty", te.supply.Qty.String()),
+	}
+	if te.supplyFee.Liquidity != "" {
+		attrs = append(attrs,
+			slog.String("supply_fee", te.supplyFee.Amount.String()),
+			slog.String("supply_liquidity", te.supplyFee.Liquidity),
+			slog.String("demand_fee", te.demandFee.Amount.String()),
+			slog.String("demand_liquidity", te.demandFee.Liquidity),
+		)
+	}
+	attrs = append(attrs, slog.Time("at", time.Unix(0, te.timestamp)))
+
+	return slog.GroupValue(attrs...)
+}
+
+type productHaltEvent struct {
+	id             uuid.UUID
+	productName    string
+	referencePrice decimal.Decimal
+	price          decimal.Decimal
+	timestamp      int64
+}
+
+func NewProductHaltEvent(productName string, referencePrice, price decimal.Decimal, timestamp int64) Event {
+	return productHaltEvent{
+		id:             uuid.New(),
+		productName:    productName,
+		referencePrice: referencePrice,
+		price:          price,
+		timestamp:      timestamp,
+	}
+}
+
+func (phe productHaltEvent) Apply(state *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
+	if state.CircuitBreaker != nil {
+		state.CircuitBreaker.Halt(phe.timestamp)
+	}
+
+	return nil, nil, nil
+}
+
+func (phe productHaltEvent) Type() string {
+	return constants.HaltEventType
+}
+
+func (phe productHaltEvent) Product() string {
+	return phe.productName
+}
+
+func (phe productHaltEvent) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", phe.Type()),
+		slog.String("id", phe.id.String()),
+		slog.String("product", phe.productName),
+		slog.String("reference_price", phe.referencePrice.String()),
+		slog.String("price", phe.price.String()),
+		slog.Time("at", time.Unix(0, phe.timestamp)),
+	)
+}
+
+type productResumeEvent struct {
+	id          uuid.UUID
+	productName string
+	automatic   bool
+	timestamp   int64
+}
+
+func NewProductResumeEvent(productName string, automatic bool) Event {
+	return productResumeEvent{
+		id:          uuid.New(),
+		productName: productName,
+		automatic:   automatic,
+		timestamp:   time.Now().UnixNano(),
+	}
+}
+
+func (pre productResumeEvent) Apply(state *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
+	if state.CircuitBreaker == nil || !state.CircuitBreaker.IsHalted() {
+		return errors.New(constants.ProductNotHaltedErrorMessage), nil, nil
+	}
+
+	state.CircuitBreaker.Resume()
+	return nil, nil, nil
+}
+
+func (pre productResumeEvent) Type() string {
+	return constants.ResumeEventType
+}
+
+func (pre productResumeEvent) Product() string {
+	return pre.productName
+}
+
+func (pre productResumeEvent) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", pre.Type()),
+		slog.String("id", pre.id.String()),
+		slog.String("product", pre.productName),
+		slog.Bool("automatic", pre.automatic),
+		slog.Time("at", time.Unix(0, pre.timestamp)),
+	)
+}
+
+type productCancelEvent struct {
+	id          uuid.UUID
+	productName string
+	orderId     string
+	timestamp   int64
+}
+
+func NewProductCancelEvent(productName string, orderId string) Event {
+	return productCancelEvent{
+		id:          uuid.New(),
+		productName: productName,
+		orderId:     orderId,
+		timestamp:   time.Now().UnixNano(),
+	}
+}
+
+// Apply removes the order from the book, or a stop order from the stop book, and returns it as the only demand
+// or supply, with the hidden reserve of an iceberg order counted in its quantity.
+func (pce productCancelEvent) Apply(state *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
+	return withdraw(state, pce.orderId)
+}
+
+func withdraw(state *current_state.CurrentState, orderId string) (error, []*order.Order, []*order.Order) {
+	demands, supplies := state.OrderBook.Get()
+
+	for _, d := range demands {
+		if d.Id == orderId {
+			cancelled := *d
+			cancelled.Qty = d.Qty.Add(d.Hidden)
+			withdrawn := *d
+			withdrawn.Qty = decimal.Zero
+			_ = state.OrderBook.Update([]*order.Order{&withdrawn}, nil)
+			return nil, []*order.Order{&cancelled}, nil
+		}
+	}
+
+	for _, s := range supplies {
+		if s.Id == orderId {
+			cancelled := *s
+			cancelled.Qty = s.Qty.Add(s.Hidden)
+			withdrawn := *s
+			withdrawn.Qty = decimal.Zero
+			_ = state.OrderBook.Update(nil, []*order.Order{&withdrawn})
+			return nil, nil, []*order.Order{&cancelled}
+		}
+	}
+
+	if state.Stops != nil {
+		if stop, ok := state.Stops.Remove(orderId); ok {
+			cancelled := *stop
+			if stop.OrderType == constants.SupplyOrderType {
+				return nil, nil, []*order.Order{&cancelled}
+			}
+			return nil, []*order.Order{&cancelled}, nil
+		}
+	}
+
+	return errors.New(constants.OrderNotFoundErrorMessage), nil, nil
+}
+
+func (pce productCancelEvent) Type() string {
+	return constants.CancelEventType
+}
+
+func (pce productCancelEvent) Product() string {
+	return pce.productName
+}
+
+func (pce productCancelEvent) OrderId() string {
+	return pce.orderId
+}
+
+func (pce productCancelEvent) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", pce.Type()),
+		slog.String("id", pce.id.String()),
+		slog.String("product", pce.productName),
+		slog.String("order_id", pce.orderId),
+		slog.Time("at", time.Unix(0, pce.timestamp)),
+	)
+}
+
+type productExpireEvent struct {
+	id          uuid.UUID
+	productName string
+	orderId     string
+	timestamp   int64
+}
+
+// NewProductExpireEvent withdraws what is left of an order whose sweep halted the product.
+func NewProductExpireEvent(productName string, orderId string) Event {
+	return productExpireEvent{
+		id:          uuid.New(),
+		productName: productName,
+		orderId:     orderId,
+		timestamp:   time.Now().UnixNano(),
+	}
+}
+
+// Apply removes the order from the book like a cancel does.
+func (pee productExpireEvent) Apply(state *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
+	return withdraw(state, pee.orderId)
+}
+
+func (pee productExpireEvent) Type() string {
+	return constants.ExpireEventType
+}
+
+func (pee productExpireEvent) Product() string {
+	return pee.productName
+}
+
+func (pee productExpireEvent) OrderId() string {
+	return pee.orderId
+}
+
+func (pee productExpireEvent) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", pee.Type()),
+		slog.String("id", pee.id.String()),
+		slog.String("product", pee.productName),
+		slog.String("order_id", pee.orderId),
+		slog.Time("at", time.Unix(0, pee.timestamp)),
+	)
+}
+
+type productRejectEvent struct {
+	id          uuid.UUID
+	productName string
+	orderType   string
+	details     orderDetails
+	price       float64
+	qty         float64
+	reason      string
+	timestamp   int64
+}
+
+// NewProductRejectEvent records an order the product refused, with the reason it was refused.
+func NewProductRejectEvent(productName, orderType string, price, quantity float64, reason string, opts ...OrderOption) Event {
+	id := uuid.New()
+	return productRejectEvent{
+		id:          id,
+		productName: productName,
+		orderType:   orderType,
+		details:     newOrderDetails(id.String(), opts),
+		price:       price,
+		qty:         quantity,
+		reason:      reason,
+		timestamp:   time.Now().UnixNano(),
+	}
+}
+
+// Apply leaves the state alone, the order never reached the book.
+func (pre productRejectEvent) Apply(_ *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
+	return nil, nil, nil
+}
+
+func (pre productRejectEvent) Type() string {
+	return constants.RejectEventType
+}
+
+func (pre productRejectEvent) Product() string {
+	return pre.productName
+}
+
+func (pre productRejectEvent) Order() order.Order {
+	return order.Order{
+		Id:          pre.details.orderId,
+		Participant: pre.details.participant,
+		Price:       decimal.NewFromFloat(pre.price),
+		Qty:         decimal.NewFromFloat(pre.qty),
+		OrderType:   pre.orderType,
+		Timestamp:   pre.timestamp,
+		Peak:        peakOf(pre.details),
+		MinQty:      minQtyOf(pre.details),
+		AllOrNone:   pre.details.allOrNone,
+	}
+}
+
+func (pre productRejectEvent) Reason() string {
+	return pre.reason
+}
+
+func (pre productRejectEvent) Origin() string {
+	return pre.details.origin
+}
+
+func (pre productRejectEvent) LogValue() slog.Value {
+	attrs := orderAttrs(pre.Type(), pre.id, pre.productName, pre.details, pre.price, pre.qty, pre.timestamp)
+	return slog.GroupValue(append(attrs, slog.String("side", pre.orderType), slog.String("reason", pre.reason))...)
+}
+
+type productStopEvent struct {
+	id          uuid.UUID
+	productName string
+	orderType   string
+	details     orderDetails
+	stopPrice   float64
+	price       float64
+	qty         float64
+	timestamp   int64
 }
 
-func NewTradeEvent(supplyEvent *order.Order, demandEvent *order.Order) Event {
-	return tradeEvent{
-		id:     uuid.New(),
-		supply: supplyEvent,
-		demand: demandEvent,
+// NewProductStopEvent places a stop order of orderType in the stop book until the last trade price reaches
+// stopPrice. Once triggered it becomes a limit order at price, or a market order if price is zero.
+func NewProductStopEvent(productName, orderType string, stopPrice, price, quantity float64, opts ...OrderOption) Event {
+	id := uuid.New()
+	return productStopEvent{
+		id:          id,
+		productName: productName,
+		orderType:   orderType,
+		details:     newOrderDetails(id.String(), opts),
+		stopPrice:   stopPrice,
+		price:       price,
+		qty:         quantity,
+		timestamp:   time.Now().UnixNano(),
 	}
 }
 
-func (te tradeEvent) Apply(_ *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
+// Apply refuses a stop order that the last trade price already reached, it would be triggered right away.
+func (pse productStopEvent) Apply(state *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
+	stop := pse.Order()
+	if !state.LastPrice.IsZero() && stop_book.Reached(&stop, state.LastPrice) {
+		return errors.New(constants.StopPriceCrossedErrorMessage), nil, nil
+	}
+
+	if state.Stops == nil {
+		state.Stops = stop_book.ProvideStopBook()
+	}
+	state.Stops.Add(&stop)
 	return nil, nil, nil
 }
 
-func (te tradeEvent) Display() {
-	log.Printf("Trade occured with supply id: %v and demand id: %v", te.supply.Id, te.demand.Id)
+func (pse productStopEvent) Type() string {
+	return constants.StopEventType
+}
+
+func (pse productStopEvent) Product() string {
+	return pse.productName
+}
+
+func (pse productStopEvent) Order() order.Order {
+	return order.Order{
+		Id:          pse.details.orderId,
+		Participant: pse.details.participant,
+		Price:       decimal.NewFromFloat(pse.price),
+		Qty:         decimal.NewFromFloat(pse.qty),
+		OrderType:   pse.orderType,
+		Timestamp:   pse.timestamp,
+		Peak:        peakOf(pse.details),
+		StopPrice:   decimal.NewFromFloat(pse.stopPrice),
+		MinQty:      minQtyOf(pse.details),
+		AllOrNone:   pse.details.allOrNone,
+	}
+}
+
+func (pse productStopEvent) Origin() string {
+	return pse.details.origin
+}
+
+func (pse productStopEvent) IdempotencyKey() string {
+	return pse.details.key
 }
 
-func matchOrder(orderbook order_book.OrderBook, o *order.Order) ([]*order.Order, []*order.Order) {
+func (pse productStopEvent) LogValue() slog.Value {
+	attrs := orderAttrs(pse.Type(), pse.id, pse.productName, pse.details, pse.price, pse.qty, pse.timestamp)
+	return slog.GroupValue(append(attrs, slog.String("side", pse.orderType), slog.String("stop_price", formatFloat(pse.stopPrice)))...)
+}
+
+type productTriggerEvent struct {
+	id          uuid.UUID
+	productName string
+	stop        order.Order
+	lastPrice   decimal.Decimal
+	timestamp   int64
+}
+
+// NewProductTriggerEvent converts a stop order that lastPrice reached and places it in the book.
+func NewProductTriggerEvent(productName string, stop order.Order, lastPrice decimal.Decimal) Event {
+	return productTriggerEvent{
+		id:          uuid.New(),
+		productName: productName,
+		stop:        stop,
+		lastPrice:   lastPrice,
+		timestamp:   time.Now().UnixNano(),
+	}
+}
+
+// Apply takes the stop order out of the stop book and matches it like an incoming order. A market order
+// matches whatever the book holds, a supply at the price of each demand it fills. Its remainder is left
+// resting for the product to cancel once its trades are recorded, or to expire if it halted the product.
+func (tre productTriggerEvent) Apply(state *current_state.CurrentState) (error, []*order.Order, []*order.Order) {
+	if state.CircuitBreaker != nil && state.CircuitBreaker.IsHalted() {
+		return errors.New(constants.ProductHaltedErrorMessage), nil, nil
+	}
+	if state.Stops == nil {
+		return errors.New(constants.OrderNotFoundErrorMessage), nil, nil
+	}
+	if _, ok := state.Stops.Remove(tre.stop.Id); !ok {
+		return errors.New(constants.OrderNotFoundErrorMessage), nil, nil
+	}
+
+	triggered := tre.Order()
+	market := triggered.Price.IsZero()
+	// a market demand spends no more than the cash reserved for it at its stop price
+	var budget decimal.Decimal
+	if market && triggered.OrderType == constants.DemandOrderType {
+		budget = tre.stop.StopPrice.Mul(tre.stop.Qty)
+	}
+
+	if triggered.OrderType == constants.SupplyOrderType {
+		_ = state.OrderBook.Update(nil, []*order.Order{&triggered})
+	} else {
+		_ = state.OrderBook.Update([]*order.Order{&triggered}, nil)
+	}
+	d, s, halted := matchOrder(state, &triggered, market, budget)
+	if !halted && !market {
+		hideReserve(state.OrderBook, &triggered)
+	}
+
+	if len(d) == 0 && len(s) == 0 {
+		return errors.New(constants.OrderMismatchErrorMessage), nil, nil
+	}
+
+	return nil, d, s
+}
+
+func (tre productTriggerEvent) Type() string {
+	return constants.TriggerEventType
+}
+
+func (tre productTriggerEvent) Product() string {
+	return tre.productName
+}
+
+// Order returns the order the stop order became, which arrives in the book when it was triggered.
+func (tre productTriggerEvent) Order() order.Order {
+	triggered := tre.stop
+	triggered.StopPrice = decimal.Decimal{}
+	triggered.Timestamp = tre.timestamp
+	return triggered
+}
+
+func (tre productTriggerEvent) LastPrice() decimal.Decimal {
+	return tre.lastPrice
+}
+
+func (tre productTriggerEvent) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", tre.Type()),
+		slog.String("id", tre.id.String()),
+		slog.String("product", tre.productName),
+		slog.String("order_id", tre.stop.Id),
+		slog.String("side", tre.stop.OrderType),
+		slog.String("stop_price", tre.stop.StopPrice.String()),
+		slog.String("price", tre.stop.Price.String()),
+		slog.String("qty", tre.stop.Qty.String()),
+		slog.String("last_price", tre.lastPrice.String()),
+		slog.Time("at", time.Unix(0, tre.timestamp)),
+	)
+}
+
+// matchOrder matches o against the book. A market order matches any price, a market supply trades at the
+// price of each demand it fills. Counterparties that cannot fill the minimum of either order are skipped,
+// they keep their place in the book for the orders they can fill. A demand with a budget stops before the first
+// fill that would take its spending past it, a zero budget leaves it unbounded. An order with a minimum fill
+// only sweeps the book if the sweep fills at least that much of it.
+func matchOrder(state *current_state.CurrentState, o *order.Order, market bool, budget decimal.Decimal) ([]*order.Order, []*order.Order, bool) {
+	orderbook := state.OrderBook
+	breaker := state.CircuitBreaker
 	zero := decimal.NewFromInt(0)
 	currentOrder := *o
+	bounded := budget.IsPositive()
 
 	matchDemands := make([]*order.Order, 0)
 	matchSupplies := make([]*order.Order, 0)
 
+	if least := minFill(&currentOrder); least.IsPositive() && !fillable(state, &currentOrder, least, market, budget) {
+		return matchDemands, matchSupplies, false
+	}
+
 	var matchSupply, matchDemand *order.Order
+	halted := false
 
 	if strings.ToUpper(strings.TrimSpace(currentOrder.OrderType)) == constants.SupplyOrderType {
 		isFullFillPossible := true
@@ -129,7 +738,7 @@ func matchOrder(orderbook order_book.OrderBook, o *order.Order) ([]*order.Order,
 			var maxDemand *order.Order
 			demands, _ := orderbook.Get()
 			for _, d := range demands {
-				if d.Price.GreaterThanOrEqual(currentOrder.Price) {
+				if (market || d.Price.GreaterThanOrEqual(currentOrder.Price)) && canFill(&currentOrder, d) {
 					if maxDemand == nil {
 						maxDemand = d
 						continue
@@ -141,12 +750,24 @@ func matchOrder(orderbook order_book.OrderBook, o *order.Order) ([]*order.Order,
 				}
 			}
 
+			if maxDemand != nil && market {
+				currentOrder.Price = maxDemand.Price
+			}
+
+			if maxDemand != nil && breaker != nil && !breaker.Allow(currentOrder.Price, currentOrder.Timestamp) {
+				halted = true
+				break
+			}
+
 			if maxDemand != nil {
 				isFullFillPossible, matchDemand, matchSupply = fulfillOrder(orderbook, maxDemand, &currentOrder, zero)
 				if isFullFillPossible {
 					matchDemands = append(matchDemands, matchDemand)
 					matchSupplies = append(matchSupplies, matchSupply)
 					currentOrder.Qty = currentOrder.Qty.Sub(matchDemand.Qty)
+					if breaker != nil {
+						breaker.Record(currentOrder.Price, currentOrder.Timestamp)
+					}
 				}
 			} else {
 				isFullFillPossible = false
@@ -160,7 +781,7 @@ func matchOrder(orderbook order_book.OrderBook, o *order.Order) ([]*order.Order,
 			_, supplies := orderbook.Get()
 			var minSupply *order.Order
 			for _, s := range supplies {
-				if s.Price.LessThanOrEqual(currentOrder.Price) {
+				if (market || s.Price.LessThanOrEqual(currentOrder.Price)) && canFill(&currentOrder, s) {
 					if minSupply == nil {
 						minSupply = s
 						continue
@@ -172,12 +793,27 @@ func matchOrder(orderbook order_book.OrderBook, o *order.Order) ([]*order.Order,
 				}
 			}
 
+			if minSupply != nil && bounded && minSupply.Price.Mul(min(minSupply.Qty, currentOrder.Qty)).GreaterThan(budget) {
+				break
+			}
+
+			if minSupply != nil && breaker != nil && !breaker.Allow(minSupply.Price, currentOrder.Timestamp) {
+				halted = true
+				break
+			}
+
 			if minSupply != nil {
 				isFullFillPossible, matchDemand, matchSupply = fulfillOrder(orderbook, &currentOrder, minSupply, zero)
 				if isFullFillPossible {
 					matchDemands = append(matchDemands, matchDemand)
 					matchSupplies = append(matchSupplies, matchSupply)
 					currentOrder.Qty = currentOrder.Qty.Sub(matchSupply.Qty)
+					if bounded {
+						budget = budget.Sub(matchSupply.Price.Mul(matchSupply.Qty))
+					}
+					if breaker != nil {
+						breaker.Record(matchSupply.Price, currentOrder.Timestamp)
+					}
 				}
 			} else {
 				isFullFillPossible = false
@@ -185,7 +821,7 @@ func matchOrder(orderbook order_book.OrderBook, o *order.Order) ([]*order.Order,
 		}
 	}
 
-	return matchDemands, matchSupplies
+	return matchDemands, matchSupplies, halted
 }
 
 func fulfillOrder(orderbook order_book.OrderBook, demand *order.Order, supply *order.Order, zero decimal.Decimal) (bool, *order.Order, *order.Order) {
@@ -199,7 +835,7 @@ func fulfillOrder(orderbook order_book.OrderBook, demand *order.Order, supply *o
 	if updatedSupplyQty.IsNegative() || updatedSupplyQty.IsZero() {
 		updatedSupplyQty = zero
 	}
-	newSupply := &order.Order{Id: s.Id, Price: s.Price, Qty: updatedSupplyQty, OrderType: constants.SupplyOrderType, Timestamp: s.Timestamp}
+	newSupply := &order.Order{Id: s.Id, Participant: s.Participant, Price: s.Price, Qty: updatedSupplyQty, OrderType: constants.SupplyOrderType, Timestamp: s.Timestamp, Peak: s.Peak, Hidden: s.Hidden, MinQty: s.MinQty, AllOrNone: s.AllOrNone}
 
 	updatedDemandQty := decimal.NewFromFloat(dq - sq)
 	if updatedDemandQty.IsNegative() || updatedDemandQty.IsZero() {
@@ -208,7 +844,15 @@ func fulfillOrder(orderbook order_book.OrderBook, demand *order.Order, supply *o
 
 	s.Qty = zero
 	d.Qty = zero
-	newDemand := &order.Order{Id: d.Id, Price: d.Price, Qty: updatedDemandQty, OrderType: constants.DemandOrderType, Timestamp: d.Timestamp}
+	newDemand := &order.Order{Id: d.Id, Participant: d.Participant, Price: d.Price, Qty: updatedDemandQty, OrderType: constants.DemandOrderType, Timestamp: d.Timestamp, Peak: d.Peak, Hidden: d.Hidden, MinQty: d.MinQty, AllOrNone: d.AllOrNone}
+
+	// the later of the two orders is the incoming one, a slice replenished by it queues behind the book
+	at := s.Timestamp
+	if d.Timestamp > at {
+		at = d.Timestamp
+	}
+	replenish(newSupply, at)
+	replenish(newDemand, at)
 
 	_ = orderbook.Update([]*order.Order{&d}, []*order.Order{&s})
 	_ = orderbook.Update([]*order.Order{newDemand}, []*order.Order{newSupply})
@@ -216,24 +860,148 @@ func fulfillOrder(orderbook order_book.OrderBook, demand *order.Order, supply *o
 	fullFilledQty := min(supply.Qty, demand.Qty)
 
 	matchDemand := &order.Order{
-		Id:        d.Id,
-		Price:     d.Price,
-		Qty:       fullFilledQty,
-		OrderType: constants.DemandOrderType,
-		Timestamp: d.Timestamp,
+		Id:          d.Id,
+		Participant: d.Participant,
+		Price:       d.Price,
+		Qty:         fullFilledQty,
+		OrderType:   constants.DemandOrderType,
+		Timestamp:   d.Timestamp,
 	}
 
 	matchSupply := &order.Order{
-		Id:        s.Id,
-		Price:     s.Price,
-		Qty:       fullFilledQty,
-		OrderType: constants.SupplyOrderType,
-		Timestamp: s.Timestamp,
+		Id:          s.Id,
+		Participant: s.Participant,
+		Price:       s.Price,
+		Qty:         fullFilledQty,
+		OrderType:   constants.SupplyOrderType,
+		Timestamp:   s.Timestamp,
 	}
 
 	return true, matchDemand, matchSupply
 }
 
+// canFill tells whether a fill between the incoming order o and the resting counter, as large as the smaller of
+// the two, satisfies the minimum fill of counter. The minimum fill of o is up to fillable, as it is reached
+// across every counterparty of its sweep.
+func canFill(o, counter *order.Order) bool {
+	return min(o.Qty, counter.Qty).GreaterThanOrEqual(minFill(counter))
+}
+
+// fillable tells whether the sweep of the incoming order o fills at least least of it. It takes the
+// counterparties in the order the sweep would, each with its hidden reserve, until the circuit breaker or the
+// budget would stop it.
+func fillable(state *current_state.CurrentState, o *order.Order, least decimal.Decimal, market bool, budget decimal.Decimal) bool {
+	demands, supplies := state.OrderBook.Get()
+	counters := supplies
+	if o.OrderType == constants.SupplyOrderType {
+		counters = demands
+	}
+
+	left := make([]*order.Order, 0, len(counters))
+	for _, c := range counters {
+		available := *c
+		available.Qty, available.Hidden = c.Qty.Add(c.Hidden), decimal.Zero
+		left = append(left, &available)
+	}
+
+	breaker := state.CircuitBreaker
+	if breaker != nil {
+		breaker = breaker.Clone()
+	}
+	bounded := budget.IsPositive()
+
+	remaining := *o
+	for o.Qty.Sub(remaining.Qty).LessThan(least) {
+		best := -1
+		for i, c := range left {
+			var crosses, better bool
+			if o.OrderType == constants.SupplyOrderType {
+				crosses = market || c.Price.GreaterThanOrEqual(remaining.Price)
+				better = best < 0 || c.Price.GreaterThan(left[best].Price)
+			} else {
+				crosses = market || c.Price.LessThanOrEqual(remaining.Price)
+				better = best < 0 || c.Price.LessThan(left[best].Price)
+			}
+			if crosses && better && canFill(&remaining, c) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return false
+		}
+
+		c := left[best]
+		fill := min(remaining.Qty, c.Qty)
+		// the sweep checks a limit supply against the breaker at its own price
+		price := c.Price
+		if o.OrderType == constants.SupplyOrderType && !market {
+			price = remaining.Price
+		}
+		if breaker != nil && !breaker.Allow(price, remaining.Timestamp) {
+			return false
+		}
+		if bounded {
+			if c.Price.Mul(fill).GreaterThan(budget) {
+				return false
+			}
+			budget = budget.Sub(c.Price.Mul(fill))
+		}
+		if breaker != nil {
+			breaker.Record(price, remaining.Timestamp)
+		}
+
+		remaining.Qty = remaining.Qty.Sub(fill)
+		left = append(left[:best], left[best+1:]...)
+	}
+	return true
+}
+
+// minFill is the least quantity o may fill at once, the whole of it if it is all-or-none.
+func minFill(o *order.Order) decimal.Decimal {
+	if o.AllOrNone {
+		return o.Qty
+	}
+	return min(o.MinQty, o.Qty)
+}
+
+// hideReserve shows only the peak of an iceberg order left resting once it matched what it could, and keeps
+// the rest of it as its hidden reserve.
+func hideReserve(orderbook order_book.OrderBook, o *order.Order) {
+	if !o.Peak.IsPositive() {
+		return
+	}
+
+	demands, supplies := orderbook.Get()
+	for _, resting := range append(demands, supplies...) {
+		if resting.Id != o.Id || resting.OrderType != o.OrderType || !resting.Qty.GreaterThan(o.Peak) {
+			continue
+		}
+
+		withdrawn, shown := *resting, *resting
+		withdrawn.Qty = decimal.Zero
+		shown.Qty, shown.Hidden = o.Peak, resting.Qty.Sub(o.Peak)
+		if o.OrderType == constants.SupplyOrderType {
+			_ = orderbook.Update(nil, []*order.Order{&withdrawn})
+			_ = orderbook.Update(nil, []*order.Order{&shown})
+		} else {
+			_ = orderbook.Update([]*order.Order{&withdrawn}, nil)
+			_ = orderbook.Update([]*order.Order{&shown}, nil)
+		}
+		return
+	}
+}
+
+// replenish cuts the next slice of an iceberg order whose shown slice filled from its hidden reserve. The
+// slice takes the time at, so it loses the time priority of the previous one.
+func replenish(o *order.Order, at int64) {
+	if !o.Qty.IsZero() || !o.Hidden.IsPositive() {
+		return
+	}
+
+	o.Qty = min(o.Peak, o.Hidden)
+	o.Hidden = o.Hidden.Sub(o.Qty)
+	o.Timestamp = at
+}
 
 func max(v1, v2 decimal.Decimal) decimal.Decimal {
 	if v1.GreaterThan(v2) {
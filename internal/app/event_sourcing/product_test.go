@@ -146,6 +146,157 @@ func (suite *productEventsSuite) TestProductDemandEvent_ShouldRemoveFromExisting
 	AssertEqualOrders(&suite.Suite, newSupplies, expectedSupplies)
 }
 
+func (suite *productEventsSuite) TestIcebergOrder_ShowsOnlyItsPeakOnceItRests() {
+	pse := event_sourcing.NewProductSupplyEvent("product-1", 150, 25, event_sourcing.WithOrderId("s1"), event_sourcing.WithPeak(10))
+
+	err, matchDemands, matchSupplies := pse.Apply(suite.currentState)
+	suite.Require().NoError(err)
+	suite.Require().Len(matchSupplies, 1, "an incoming iceberg order matches with its whole quantity")
+	suite.Assert().True(decimal.NewFromInt(11).Equal(matchSupplies[0].Qty))
+	suite.Assert().True(decimal.NewFromInt(200).Equal(matchDemands[0].Price))
+
+	_, supplies := suite.currentState.OrderBook.Get()
+	iceberg := suite.find(supplies, "s1")
+	suite.Assert().True(decimal.NewFromInt(10).Equal(iceberg.Qty))
+	suite.Assert().True(decimal.NewFromInt(4).Equal(iceberg.Hidden))
+}
+
+func (suite *productEventsSuite) TestIcebergOrder_HiddenQuantityIsMatchedSliceBySlice() {
+	_, _, _ = event_sourcing.NewProductSupplyEvent("product-1", 300, 25, event_sourcing.WithOrderId("s1"), event_sourcing.WithPeak(10)).Apply(suite.currentState)
+
+	err, _, matchSupplies := event_sourcing.NewProductDemandEvent("product-1", 300, 30).Apply(suite.currentState)
+	suite.Require().NoError(err)
+
+	var matched []string
+	for _, s := range matchSupplies {
+		matched = append(matched, s.Price.String()+"x"+s.Qty.String())
+	}
+	suite.Assert().Equal([]string{"100x7", "200x3", "300x10", "300x10"}, matched)
+
+	_, supplies := suite.currentState.OrderBook.Get()
+	suite.Require().Len(supplies, 1)
+	suite.Assert().True(decimal.NewFromInt(5).Equal(supplies[0].Qty), "the last slice is what the reserve had left")
+	suite.Assert().True(supplies[0].Hidden.IsZero())
+}
+
+func (suite *productEventsSuite) TestIcebergOrder_ReplenishedSliceLosesTimePriority() {
+	state := &current_state.CurrentState{
+		OrderBook: order_book.ProvideOrderBook(comparator.ProvideDemandComparator(), comparator.ProvideSupplyComparator()),
+	}
+	_, _, _ = event_sourcing.NewProductSupplyEvent("product-1", 250, 20, event_sourcing.WithOrderId("iceberg"), event_sourcing.WithPeak(5)).Apply(state)
+	_, _, _ = event_sourcing.NewProductSupplyEvent("product-1", 250, 5, event_sourcing.WithOrderId("plain")).Apply(state)
+
+	var matched []string
+	for i := 0; i < 3; i++ {
+		err, _, matchSupplies := event_sourcing.NewProductDemandEvent("product-1", 250, 5).Apply(state)
+		suite.Require().NoError(err)
+		suite.Require().Len(matchSupplies, 1)
+		matched = append(matched, matchSupplies[0].Id)
+	}
+	suite.Assert().Equal([]string{"iceberg", "plain", "iceberg"}, matched)
+
+	err, _, _ := event_sourcing.NewProductCancelEvent("product-1", "iceberg").Apply(state)
+	suite.Require().NoError(err)
+	_, supplies := state.OrderBook.Get()
+	suite.Assert().Empty(supplies, "cancelling withdraws the hidden reserve too")
+}
+
+func (suite *productEventsSuite) TestAllOrNoneOrder_IsBypassedUntilItCanFillAtOnce() {
+	state := &current_state.CurrentState{
+		OrderBook: order_book.ProvideOrderBook(comparator.ProvideDemandComparator(), comparator.ProvideSupplyComparator()),
+	}
+	_, _, _ = event_sourcing.NewProductDemandEvent("product-1", 110, 10, event_sourcing.WithOrderId("aon"), event_sourcing.WithAllOrNone()).Apply(state)
+	_, _, _ = event_sourcing.NewProductDemandEvent("product-1", 110, 3, event_sourcing.WithOrderId("d2")).Apply(state)
+
+	err, matchDemands, _ := event_sourcing.NewProductSupplyEvent("product-1", 110, 4, event_sourcing.WithOrderId("s1")).Apply(state)
+	suite.Require().NoError(err)
+	suite.Require().Len(matchDemands, 1)
+	suite.Assert().Equal("d2", matchDemands[0].Id, "4kg cannot fill the all-or-none demand")
+
+	demands, supplies := state.OrderBook.Get()
+	suite.Require().Len(demands, 1)
+	suite.Assert().Equal("aon", demands[0].Id)
+	suite.Assert().True(decimal.NewFromInt(10).Equal(demands[0].Qty))
+	suite.Require().Len(supplies, 1)
+
+	err, matchDemands, matchSupplies := event_sourcing.NewProductSupplyEvent("product-1", 110, 12, event_sourcing.WithOrderId("s2")).Apply(state)
+	suite.Require().NoError(err)
+	suite.Require().Len(matchDemands, 1)
+	suite.Assert().Equal("aon", matchDemands[0].Id)
+	suite.Assert().True(decimal.NewFromInt(10).Equal(matchSupplies[0].Qty))
+
+	demands, supplies = state.OrderBook.Get()
+	suite.Assert().Empty(demands)
+	suite.Assert().Len(supplies, 2)
+}
+
+func (suite *productEventsSuite) TestAllOrNoneOrder_FillsFromEveryCounterpartyItTakesAtOnce() {
+	err, _, matchSupplies := event_sourcing.NewProductDemandEvent("product-1", 300, 8, event_sourcing.WithOrderId("aon-1"), event_sourcing.WithAllOrNone()).Apply(suite.currentState)
+	suite.Require().NoError(err, "the supplies of 7 and 3 fill it together")
+	suite.Require().Len(matchSupplies, 2)
+	suite.Assert().True(decimal.NewFromInt(7).Equal(matchSupplies[0].Qty))
+	suite.Assert().True(decimal.NewFromInt(1).Equal(matchSupplies[1].Qty))
+
+	err, _, _ = event_sourcing.NewProductDemandEvent("product-1", 300, 5, event_sourcing.WithOrderId("aon-2"), event_sourcing.WithAllOrNone()).Apply(suite.currentState)
+	suite.Require().EqualError(err, constants.OrderMismatchErrorMessage, "only 2 are left")
+
+	demands, supplies := suite.currentState.OrderBook.Get()
+	suite.Assert().Len(demands, 3)
+	suite.Require().Len(supplies, 1)
+	suite.Assert().True(decimal.NewFromInt(2).Equal(supplies[0].Qty), "a refused all-or-none order takes nothing")
+}
+
+func (suite *productEventsSuite) TestMinQty_IsReachedAcrossTheSlicesOfAnIcebergCounterparty() {
+	state := &current_state.CurrentState{
+		OrderBook: order_book.ProvideOrderBook(comparator.ProvideDemandComparator(), comparator.ProvideSupplyComparator()),
+	}
+	_, _, _ = event_sourcing.NewProductSupplyEvent("product-1", 110, 10, event_sourcing.WithOrderId("iceberg"), event_sourcing.WithPeak(2)).Apply(state)
+
+	err, _, matchSupplies := event_sourcing.NewProductDemandEvent("product-1", 110, 6, event_sourcing.WithMinQty(5)).Apply(state)
+	suite.Require().NoError(err, "the iceberg shows 2 but holds 10")
+	suite.Assert().Len(matchSupplies, 3)
+
+	err, _, matchSupplies = event_sourcing.NewProductDemandEvent("product-1", 110, 4, event_sourcing.WithAllOrNone()).Apply(state)
+	suite.Require().NoError(err)
+	suite.Assert().Len(matchSupplies, 2)
+}
+
+func (suite *productEventsSuite) TestMinQty_SkipsCounterpartiesBelowItWithoutLosingPriority() {
+	state := &current_state.CurrentState{
+		OrderBook: order_book.ProvideOrderBook(comparator.ProvideDemandComparator(), comparator.ProvideSupplyComparator()),
+	}
+	_, _, _ = event_sourcing.NewProductSupplyEvent("product-1", 110, 11, event_sourcing.WithOrderId("s1"), event_sourcing.WithMinQty(5)).Apply(state)
+	_, _, _ = event_sourcing.NewProductSupplyEvent("product-1", 110, 20, event_sourcing.WithOrderId("s2")).Apply(state)
+
+	var matched []string
+	for _, qty := range []float64{2, 6, 8} {
+		err, _, matchSupplies := event_sourcing.NewProductDemandEvent("product-1", 110, qty).Apply(state)
+		suite.Require().NoError(err)
+		for _, s := range matchSupplies {
+			matched = append(matched, s.Id+"x"+s.Qty.String())
+		}
+	}
+	suite.Assert().Equal([]string{"s2x2", "s1x6", "s1x5", "s2x3"}, matched, "the 5kg left of s1 may fill less than its minimum")
+
+	err, _, _ := event_sourcing.NewProductDemandEvent("product-1", 110, 20, event_sourcing.WithMinQty(16)).Apply(state)
+	suite.Assert().EqualError(err, constants.OrderMismatchErrorMessage, "s2 has 15kg left")
+
+	_, _, _ = event_sourcing.NewProductSupplyEvent("product-1", 110, 3, event_sourcing.WithOrderId("s3")).Apply(state)
+	err, _, matchSupplies := event_sourcing.NewProductDemandEvent("product-1", 110, 20, event_sourcing.WithMinQty(16)).Apply(state)
+	suite.Require().NoError(err, "s2 and s3 fill 18kg together")
+	suite.Assert().Len(matchSupplies, 2)
+}
+
+func (suite *productEventsSuite) find(orders []*order.Order, id string) *order.Order {
+	for _, o := range orders {
+		if o.Id == id {
+			return o
+		}
+	}
+	suite.FailNow("order not found", id)
+	return nil
+}
+
 func AssertEqualOrders(suite *suite.Suite, expected []*order.Order, actual []*order.Order) {
 	suite.Assert().Equal(len(expected), len(actual))
 	for i, q := range actual {
@@ -0,0 +1,122 @@
+package event_sourcing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Record is an event as persisted by the event store. Version is the 1 based position of the event in its
+// stream, Sequence its 1 based position across all streams.
+//
+// Records are chained twice: Hash covers the record and the Hash of the record before it in the global log,
+// StreamHash the record and the StreamHash of the record before it in the same stream. Editing, dropping or
+// reordering a persisted record breaks both chains from that record on.
+type Record struct {
+	Stream   string
+	Version  int
+	Sequence uint64
+	Time     time.Time
+	Event    Event
+
+	PrevHash       string
+	Hash           string
+	StreamPrevHash string
+	StreamHash     string
+}
+
+type recordData struct {
+	Stream   string          `json:"stream"`
+	Version  int             `json:"version"`
+	Sequence uint64          `json:"sequence"`
+	Time     int64           `json:"time"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// Canonical returns the bytes the record hashes are computed over.
+func (r Record) Canonical() ([]byte, error) {
+	ev, err := Marshal(r.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(recordData{Stream: r.Stream, Version: r.Version, Sequence: r.Sequence, Time: r.Time.UnixNano(), Event: ev})
+}
+
+// Seal links the record to the heads of the global and stream chains and computes its hashes.
+func (r *Record) Seal(prevHash, streamPrevHash string) error {
+	canonical, err := r.Canonical()
+	if err != nil {
+		return err
+	}
+
+	r.PrevHash, r.StreamPrevHash = prevHash, streamPrevHash
+	r.Hash = chainHash(prevHash, canonical)
+	r.StreamHash = chainHash(streamPrevHash, canonical)
+	return nil
+}
+
+func chainHash(prev string, canonical []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// BrokenLinkError reports the first record that does not continue the chain before it.
+type BrokenLinkError struct {
+	Sequence uint64
+	Stream   string
+	Version  int
+	Reason   string
+}
+
+func (e *BrokenLinkError) Error() string {
+	return fmt.Sprintf("broken link at sequence %d (stream %s, version %d): %s", e.Sequence, e.Stream, e.Version, e.Reason)
+}
+
+// VerifyChain walks records in global order and returns a *BrokenLinkError for the first record whose
+// position or hashes do not follow from the records before it.
+func VerifyChain(records []Record) error {
+	var head string
+	streamHeads := make(map[string]string)
+	streamVersions := make(map[string]int)
+
+	for i, r := range records {
+		broken := func(reason string, args ...interface{}) error {
+			return &BrokenLinkError{Sequence: r.Sequence, Stream: r.Stream, Version: r.Version, Reason: fmt.Sprintf(reason, args...)}
+		}
+
+		if r.Sequence != uint64(i+1) {
+			return broken("expected sequence %d", i+1)
+		}
+		if r.Version != streamVersions[r.Stream]+1 {
+			return broken("expected stream version %d", streamVersions[r.Stream]+1)
+		}
+		if r.PrevHash != head {
+			return broken("previous hash %q does not match %q", r.PrevHash, head)
+		}
+		if r.StreamPrevHash != streamHeads[r.Stream] {
+			return broken("previous stream hash %q does not match %q", r.StreamPrevHash, streamHeads[r.Stream])
+		}
+
+		canonical, err := r.Canonical()
+		if err != nil {
+			return broken("cannot encode event: %v", err)
+		}
+		if r.Hash != chainHash(head, canonical) {
+			return broken("hash does not match content")
+		}
+		if r.StreamHash != chainHash(streamHeads[r.Stream], canonical) {
+			return broken("stream hash does not match content")
+		}
+
+		head = r.Hash
+		streamHeads[r.Stream] = r.StreamHash
+		streamVersions[r.Stream] = r.Version
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package event_sourcing_test
+
+import (
+	"errors"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func sampleEvents() []event_sourcing.Event {
+	return []event_sourcing.Event{
+		event_sourcing.NewProductSupplyEvent("tomato", 24.5, 100, event_sourcing.WithOrderId("s1"), event_sourcing.WithParticipant("grower-1"), event_sourcing.WithMinQty(10)),
+		event_sourcing.NewProductDemandEvent("tomato", 22, 110, event_sourcing.WithOrderId("d1"), event_sourcing.WithOrigin("orders.txt:2"), event_sourcing.WithIdempotencyKey("k1")),
+		event_sourcing.NewTradeEvent("tomato",
+			&order.Order{Id: "s1", Participant: "grower-1", Price: decimal.NewFromFloat(24.5), Qty: decimal.NewFromFloat(90), Timestamp: 10},
+			&order.Order{Id: "d1", Price: decimal.NewFromFloat(25), Qty: decimal.NewFromFloat(90), Timestamp: 20}),
+		event_sourcing.NewTradeEvent("tomato",
+			&order.Order{Id: "s2", Price: decimal.NewFromFloat(24), Qty: decimal.NewFromFloat(10), Timestamp: 15},
+			&order.Order{Id: "d1", Price: decimal.NewFromFloat(25), Qty: decimal.NewFromFloat(10), Timestamp: 20},
+			event_sourcing.WithFees(
+				event_sourcing.Fee{Liquidity: constants.MakerLiquidity, Amount: decimal.NewFromFloat(0.24)},
+				event_sourcing.Fee{Liquidity: constants.TakerLiquidity, Amount: decimal.NewFromFloat(0.48)},
+			)),
+		event_sourcing.NewProductHaltEvent("tomato", decimal.NewFromFloat(20), decimal.NewFromFloat(24.5), 30),
+		event_sourcing.NewProductResumeEvent("tomato", true),
+		event_sourcing.NewProductCancelEvent("tomato", "s1"),
+		event_sourcing.NewProductExpireEvent("tomato", "d1"),
+		event_sourcing.NewProductRejectEvent("tomato", constants.DemandOrderType, 20, 0.5, "quantity 0.5 violates lot size 1", event_sourcing.WithOrderId("d2"), event_sourcing.WithParticipant("buyer-1")),
+		event_sourcing.NewProductStopEvent("tomato", constants.DemandOrderType, 26, 0, 40, event_sourcing.WithOrderId("d3"), event_sourcing.WithParticipant("buyer-1"), event_sourcing.WithAllOrNone()),
+		event_sourcing.NewProductTriggerEvent("tomato",
+			order.Order{Id: "d4", Participant: "buyer-1", Price: decimal.NewFromFloat(27.5), Qty: decimal.NewFromFloat(40), OrderType: constants.DemandOrderType, StopPrice: decimal.NewFromFloat(26), MinQty: decimal.NewFromFloat(5)},
+			decimal.NewFromFloat(26.25)),
+	}
+}
+
+func TestCodec_RoundTripsEveryEventType(t *testing.T) {
+	for _, ev := range sampleEvents() {
+		encoded, err := event_sourcing.Marshal(ev)
+		require.NoError(t, err)
+
+		decoded, err := event_sourcing.Unmarshal(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, ev.Type(), decoded.Type())
+		assert.Equal(t, ev.LogValue().String(), decoded.LogValue().String(), ev.Type())
+
+		reencoded, err := event_sourcing.Marshal(decoded)
+		require.NoError(t, err)
+		assert.Equal(t, string(encoded), string(reencoded), ev.Type())
+	}
+
+	_, err := event_sourcing.Unmarshal([]byte(`{"type":"unknown","id":"` + "8b9e6ae0-00e2-4590-9839-4135b497e872" + `"}`))
+	assert.Error(t, err)
+}
+
+func sealedChain(t *testing.T) []event_sourcing.Record {
+	records := make([]event_sourcing.Record, 0)
+	heads := make(map[string]string)
+	versions := make(map[string]int)
+	var head string
+
+	for i, ev := range sampleEvents() {
+		stream := []string{"tomato", "potato"}[i%2]
+		versions[stream]++
+
+		r := event_sourcing.Record{Stream: stream, Version: versions[stream], Sequence: uint64(i + 1), Time: time.Unix(0, int64(i)), Event: ev}
+		require.NoError(t, r.Seal(head, heads[stream]))
+		head, heads[stream] = r.Hash, r.StreamHash
+		records = append(records, r)
+	}
+
+	return records
+}
+
+func TestVerifyChain_AcceptsSealedRecords(t *testing.T) {
+	records := sealedChain(t)
+
+	require.NoError(t, event_sourcing.VerifyChain(records))
+	assert.Equal(t, records[1].Hash, records[2].PrevHash)
+	assert.Equal(t, records[0].StreamHash, records[2].StreamPrevHash)
+}
+
+func TestVerifyChain_ReportsFirstBrokenLink(t *testing.T) {
+	tests := map[string]struct {
+		tamper   func(records []event_sourcing.Record) []event_sourcing.Record
+		sequence uint64
+	}{
+		"edited event": {
+			tamper: func(records []event_sourcing.Record) []event_sourcing.Record {
+				records[2].Event = event_sourcing.NewProductCancelEvent("tomato", "s9")
+				return records
+			},
+			sequence: 3,
+		},
+		"edited time": {
+			tamper: func(records []event_sourcing.Record) []event_sourcing.Record {
+				records[3].Time = records[3].Time.Add(time.Hour)
+				return records
+			},
+			sequence: 4,
+		},
+		"deleted record": {
+			tamper: func(records []event_sourcing.Record) []event_sourcing.Record {
+				return append(records[:1], records[2:]...)
+			},
+			sequence: 3,
+		},
+		"rehashed record": {
+			tamper: func(records []event_sourcing.Record) []event_sourcing.Record {
+				records[1].Event = event_sourcing.NewProductCancelEvent("tomato", "s9")
+				_ = records[1].Seal(records[1].PrevHash, records[1].StreamPrevHash)
+				return records
+			},
+			sequence: 3,
+		},
+	}
+
+	for name, test := range tests {
+		err := event_sourcing.VerifyChain(test.tamper(sealedChain(t)))
+
+		var broken *event_sourcing.BrokenLinkError
+		require.True(t, errors.As(err, &broken), name)
+		assert.Equal(t, test.sequence, broken.Sequence, name)
+	}
+}
@@ -0,0 +1,210 @@
+package fees
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/shopspring/decimal"
+	"sync"
+	"time"
+)
+
+const (
+	VolumesName = "fee_volumes"
+
+	// Precision is the number of decimal places fees are rounded to.
+	Precision = 2
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// Tier discounts the rate based fee of participants who traded at least Volume, in notional, over the
+// rolling window.
+type Tier struct {
+	Volume          decimal.Decimal `yaml:"volume"`
+	DiscountPercent decimal.Decimal `yaml:"discount_percent"`
+}
+
+// Schedule prices one side of a trade. Rates are fractions of the traded notional, Fixed is added to every
+// trade after the volume discount and Minimum is the least a trade is charged.
+type Schedule struct {
+	MakerRate decimal.Decimal `yaml:"maker_rate"`
+	TakerRate decimal.Decimal `yaml:"taker_rate"`
+	Fixed     decimal.Decimal `yaml:"fixed"`
+	Minimum   decimal.Decimal `yaml:"minimum"`
+	Tiers     []Tier          `yaml:"tiers"`
+}
+
+// Charge returns the fee for one side of a trade with the given notional, for a participant who traded
+// volume over the rolling window before it.
+func (s Schedule) Charge(liquidity string, notional, volume decimal.Decimal) decimal.Decimal {
+	rate := s.MakerRate
+	if liquidity == constants.TakerLiquidity {
+		rate = s.TakerRate
+	}
+
+	fee := notional.Mul(rate)
+	if discount := s.Discount(volume); discount.IsPositive() {
+		fee = fee.Mul(hundred.Sub(discount)).Div(hundred)
+	}
+
+	fee = fee.Add(s.Fixed)
+	if fee.LessThan(s.Minimum) {
+		fee = s.Minimum
+	}
+	return fee.Round(Precision)
+}
+
+// Most returns the most the given number of fills with a total notional can be charged, at the higher rate and
+// without discount. Each fill may be charged the fixed fee or the minimum, and rounded up by half a cent.
+func (s Schedule) Most(notional decimal.Decimal, fills int64) decimal.Decimal {
+	perFill := decimal.Max(s.Fixed, s.Minimum).Add(decimal.New(5, -(Precision + 1)))
+	fee := notional.Mul(decimal.Max(s.MakerRate, s.TakerRate)).Add(perFill.Mul(decimal.NewFromInt(fills)))
+	return fee.RoundUp(Precision)
+}
+
+// Discount returns the discount percent of the highest tier volume reaches.
+func (s Schedule) Discount(volume decimal.Decimal) decimal.Decimal {
+	discount, reached := decimal.Zero, decimal.Zero
+	for _, t := range s.Tiers {
+		if volume.GreaterThanOrEqual(t.Volume) && t.Volume.GreaterThanOrEqual(reached) {
+			discount, reached = t.DiscountPercent, t.Volume
+		}
+	}
+	return discount
+}
+
+type fill struct {
+	at       time.Time
+	notional decimal.Decimal
+}
+
+type Option func(e *Engine)
+
+func WithDefaultSchedule(schedule Schedule) Option {
+	return func(e *Engine) {
+		e.defaultSchedule = &schedule
+	}
+}
+
+func WithSchedule(product string, schedule Schedule) Option {
+	return func(e *Engine) {
+		e.schedules[product] = schedule
+	}
+}
+
+// WithWindow sets how far back the traded volume of a participant counts towards their tier.
+func WithWindow(window time.Duration) Option {
+	return func(e *Engine) {
+		e.window = window
+	}
+}
+
+// Engine charges fees on trades. It follows the trade events of the store to know how much every
+// participant traded over the rolling window.
+type Engine struct {
+	mtx             sync.RWMutex
+	schedules       map[string]Schedule
+	defaultSchedule *Schedule
+	window          time.Duration
+	fills           map[string][]fill
+}
+
+func NewEngine(opts ...Option) *Engine {
+	e := &Engine{schedules: make(map[string]Schedule), window: 30 * 24 * time.Hour}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.Reset()
+	return e
+}
+
+func (e *Engine) Name() string {
+	return VolumesName
+}
+
+func (e *Engine) Handlers() map[string]projection.Handler {
+	return map[string]projection.Handler{
+		constants.TradeEventType: e.add,
+	}
+}
+
+func (e *Engine) Reset() {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	e.fills = make(map[string][]fill)
+}
+
+func (e *Engine) Schedule(product string) (Schedule, bool) {
+	if s, ok := e.schedules[product]; ok {
+		return s, true
+	}
+	if e.defaultSchedule != nil {
+		return *e.defaultSchedule, true
+	}
+	return Schedule{}, false
+}
+
+// Volume returns the notional a participant traded in the window ending at at.
+func (e *Engine) Volume(participant string, at time.Time) decimal.Decimal {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	volume := decimal.Zero
+	for _, f := range e.fills[participant] {
+		if f.at.After(at.Add(-e.window)) && !f.at.After(at) {
+			volume = volume.Add(f.notional)
+		}
+	}
+	return volume
+}
+
+// Charge prices both sides of a match. The side of aggressor, the order type of the order that crossed
+// the spread, takes liquidity and the resting side makes it. Without an aggressor the later of the two
+// orders is taken to be it.
+func (e *Engine) Charge(product string, supply, demand *order.Order, aggressor string) (event_sourcing.Fee, event_sourcing.Fee) {
+	supplyLiquidity, demandLiquidity := constants.MakerLiquidity, constants.TakerLiquidity
+	if aggressor == constants.SupplyOrderType || (aggressor == "" && supply.Timestamp > demand.Timestamp) {
+		supplyLiquidity, demandLiquidity = constants.TakerLiquidity, constants.MakerLiquidity
+	}
+
+	schedule, ok := e.Schedule(product)
+	if !ok {
+		return event_sourcing.Fee{Liquidity: supplyLiquidity}, event_sourcing.Fee{Liquidity: demandLiquidity}
+	}
+
+	at := time.Unix(0, supply.Timestamp)
+	if demand.Timestamp > supply.Timestamp {
+		at = time.Unix(0, demand.Timestamp)
+	}
+	notional := supply.Price.Mul(supply.Qty)
+
+	return event_sourcing.Fee{Liquidity: supplyLiquidity, Amount: schedule.Charge(supplyLiquidity, notional, e.Volume(supply.Participant, at))},
+		event_sourcing.Fee{Liquidity: demandLiquidity, Amount: schedule.Charge(demandLiquidity, notional, e.Volume(demand.Participant, at))}
+}
+
+func (e *Engine) add(record event_sourcing.Record) {
+	te, ok := record.Event.(event_sourcing.TradeEvent)
+	if !ok {
+		return
+	}
+	t := te.Trade()
+	notional := t.Price.Mul(t.Qty)
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	for _, participant := range []string{t.SupplyParticipant, t.DemandParticipant} {
+		fills := e.fills[participant]
+
+		// drop what fell out of the window, fills arrive in trade time order
+		for len(fills) > 0 && !fills[0].at.After(t.Timestamp.Add(-e.window)) {
+			fills = fills[1:]
+		}
+		e.fills[participant] = append(fills, fill{at: t.Timestamp, notional: notional})
+	}
+}
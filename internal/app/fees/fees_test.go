@@ -0,0 +1,146 @@
+package fees_test
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fees"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/product"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/repository"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type feesSuite struct {
+	suite.Suite
+	schedule   fees.Schedule
+	engine     *fees.Engine
+	statements *fees.Statements
+	repo       *repository.LedgerRepository
+	tomato     *product.Product
+}
+
+func TestFeesSuite(t *testing.T) {
+	suite.Run(t, new(feesSuite))
+}
+
+func (suite *feesSuite) SetupTest() {
+	suite.schedule = fees.Schedule{
+		MakerRate: decimal.RequireFromString("0.001"),
+		TakerRate: decimal.RequireFromString("0.002"),
+		Fixed:     decimal.RequireFromString("0.1"),
+		Minimum:   decimal.RequireFromString("0.5"),
+		Tiers: []fees.Tier{
+			{Volume: decimal.NewFromInt(1000), DiscountPercent: decimal.NewFromInt(10)},
+			{Volume: decimal.NewFromInt(5000), DiscountPercent: decimal.NewFromInt(50)},
+		},
+	}
+	suite.engine = fees.NewEngine(fees.WithSchedule("tomato", suite.schedule), fees.WithWindow(time.Hour))
+	suite.statements = fees.NewStatements()
+
+	suite.repo = repository.NewWarehouseRepository(repository.WithDefaultProductOptions(product.WithFees(suite.engine)))
+	manager := projection.NewManager(suite.repo)
+	suite.Require().NoError(manager.Register(suite.engine))
+	suite.Require().NoError(manager.Register(suite.statements))
+	suite.tomato = suite.repo.Get("tomato-stream", "tomato")
+}
+
+func (suite *feesSuite) place(supply bool, id, participant string, price, qty float64) []event_sourcing.Trade {
+	opts := []event_sourcing.OrderOption{event_sourcing.WithOrderId(id), event_sourcing.WithParticipant(participant)}
+
+	place := suite.tomato.DemandProduct
+	if supply {
+		place = suite.tomato.SupplyProduct
+	}
+
+	err, demands, supplies := place(price, qty, opts...)
+	suite.Require().NoError(err)
+	for i := range supplies {
+		suite.Require().NoError(suite.tomato.TradeProduct(supplies[i], demands[i]))
+	}
+	suite.Require().NoError(suite.repo.Save(suite.tomato))
+
+	trades := make([]event_sourcing.Trade, 0)
+	for _, ev := range suite.tomato.GetEvents()[len(suite.tomato.GetEvents())-len(supplies):] {
+		trades = append(trades, ev.(event_sourcing.TradeEvent).Trade())
+	}
+	return trades
+}
+
+func (suite *feesSuite) TestChargesMakerAndTakerRatesWithFixedAndMinimumFees() {
+	notional := decimal.NewFromInt(1000)
+
+	suite.Assert().Equal("1.1", suite.schedule.Charge(constants.MakerLiquidity, notional, decimal.Zero).String())
+	suite.Assert().Equal("2.1", suite.schedule.Charge(constants.TakerLiquidity, notional, decimal.Zero).String())
+	suite.Assert().Equal("0.5", suite.schedule.Charge(constants.MakerLiquidity, decimal.NewFromInt(10), decimal.Zero).String())
+}
+
+func (suite *feesSuite) TestDiscountsTheRateOfTheHighestTierReached() {
+	notional := decimal.NewFromInt(1000)
+
+	suite.Assert().True(suite.schedule.Discount(decimal.NewFromInt(999)).IsZero())
+	suite.Assert().Equal("10", suite.schedule.Discount(decimal.NewFromInt(1000)).String())
+	suite.Assert().Equal("50", suite.schedule.Discount(decimal.NewFromInt(7500)).String())
+	suite.Assert().Equal("1.9", suite.schedule.Charge(constants.TakerLiquidity, notional, decimal.NewFromInt(1000)).String())
+	suite.Assert().Equal("1.1", suite.schedule.Charge(constants.TakerLiquidity, notional, decimal.NewFromInt(5000)).String())
+}
+
+func (suite *feesSuite) TestRecordsFeesOnTradesWithTheAggressorTaking() {
+	suite.place(true, "s1", "grower-1", 20, 100)
+	trades := suite.place(false, "d1", "buyer-1", 22, 50)
+	suite.Require().Len(trades, 1)
+
+	suite.Assert().Equal(constants.MakerLiquidity, trades[0].SupplyFee.Liquidity)
+	suite.Assert().Equal("1.1", trades[0].SupplyFee.Amount.String())
+	suite.Assert().Equal(constants.TakerLiquidity, trades[0].DemandFee.Liquidity)
+	suite.Assert().Equal("2.1", trades[0].DemandFee.Amount.String())
+
+	suite.place(false, "d2", "buyer-2", 19, 10)
+	trades = suite.place(true, "s2", "grower-1", 19, 10)
+	suite.Require().Len(trades, 1)
+	suite.Assert().Equal(constants.TakerLiquidity, trades[0].SupplyFee.Liquidity)
+	suite.Assert().Equal("1190", suite.engine.Volume("grower-1", trades[0].Timestamp).String())
+	suite.Assert().Equal(constants.MakerLiquidity, trades[0].DemandFee.Liquidity)
+}
+
+func (suite *feesSuite) TestVolumeRollsOverTheWindow() {
+	at := time.Date(2023, 3, 1, 9, 0, 0, 0, time.UTC)
+	trade := func(minutes int, qty int64) {
+		ts := at.Add(time.Duration(minutes) * time.Minute).UnixNano()
+		supply := &order.Order{Id: "s", Participant: "grower-1", Price: decimal.NewFromInt(10), Qty: decimal.NewFromInt(qty), Timestamp: ts}
+		demand := &order.Order{Id: "d", Participant: "buyer-1", Price: decimal.NewFromInt(10), Qty: decimal.NewFromInt(qty), Timestamp: ts}
+		suite.Require().NoError(suite.tomato.TradeProduct(supply, demand))
+		suite.Require().NoError(suite.repo.Save(suite.tomato))
+	}
+
+	trade(0, 1)
+	trade(30, 2)
+	suite.Assert().Equal("30", suite.engine.Volume("grower-1", at.Add(45*time.Minute)).String())
+
+	trade(80, 3)
+	suite.Assert().Equal("50", suite.engine.Volume("grower-1", at.Add(80*time.Minute)).String())
+	suite.Assert().Equal("30", suite.engine.Volume("buyer-1", at.Add(139*time.Minute)).String())
+	suite.Assert().True(suite.engine.Volume("buyer-1", at.Add(140*time.Minute)).IsZero())
+}
+
+func (suite *feesSuite) TestBuildsMonthlyStatements() {
+	suite.place(true, "s1", "grower-1", 20, 100)
+	trades := suite.place(false, "d1", "buyer-1", 22, 50)
+	suite.place(false, "d2", "buyer-1", 22, 50)
+
+	st := suite.statements.Statement("grower-1", trades[0].Timestamp)
+	suite.Assert().Equal(trades[0].Timestamp.Year(), st.Month.Year())
+	suite.Require().Len(st.Lines, 2)
+	suite.Assert().Equal("2000", st.Notional.String())
+	suite.Assert().Equal("2.1", st.MakerFees.String(), "the second trade is discounted by the first tier")
+	suite.Assert().True(st.TakerFees.IsZero())
+
+	buyer := suite.statements.Statement("buyer-1", trades[0].Timestamp)
+	suite.Assert().Equal("4", buyer.Total().String())
+	suite.Assert().Equal("d1", buyer.Lines[0].OrderId)
+
+	suite.Assert().Empty(suite.statements.Statement("grower-1", trades[0].Timestamp.AddDate(0, -1, 0)).Lines)
+}
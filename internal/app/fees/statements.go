@@ -0,0 +1,140 @@
+package fees
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/shopspring/decimal"
+	"sync"
+	"time"
+)
+
+const StatementsName = "fee_statements"
+
+type StatementLine struct {
+	TradeId   string
+	Product   string
+	OrderId   string
+	Liquidity string
+	Notional  decimal.Decimal
+	Fee       decimal.Decimal
+	At        time.Time
+}
+
+// Statement lists the fees a participant was charged in a calendar month, in UTC.
+type Statement struct {
+	Participant string
+	Month       time.Time
+	Notional    decimal.Decimal
+	MakerFees   decimal.Decimal
+	TakerFees   decimal.Decimal
+	Lines       []StatementLine
+}
+
+func (s Statement) Total() decimal.Decimal {
+	return s.MakerFees.Add(s.TakerFees)
+}
+
+type statementKey struct {
+	participant string
+	month       time.Time
+}
+
+type Statements struct {
+	mtx        sync.RWMutex
+	statements map[statementKey]*Statement
+}
+
+func NewStatements() *Statements {
+	s := &Statements{}
+	s.Reset()
+	return s
+}
+
+func (s *Statements) Name() string {
+	return StatementsName
+}
+
+func (s *Statements) Handlers() map[string]projection.Handler {
+	return map[string]projection.Handler{
+		constants.TradeEventType: s.add,
+	}
+}
+
+func (s *Statements) Reset() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.statements = make(map[statementKey]*Statement)
+}
+
+// Statement returns the statement of a participant for the month holding month. Months without trades
+// return an empty statement.
+func (s *Statements) Statement(participant string, month time.Time) Statement {
+	key := statementKey{participant: participant, month: startOfMonth(month)}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	st, ok := s.statements[key]
+	if !ok {
+		return Statement{Participant: participant, Month: key.month, Lines: make([]StatementLine, 0)}
+	}
+
+	result := *st
+	result.Lines = append([]StatementLine(nil), st.Lines...)
+	return result
+}
+
+func (s *Statements) add(record event_sourcing.Record) {
+	te, ok := record.Event.(event_sourcing.TradeEvent)
+	if !ok {
+		return
+	}
+	t := te.Trade()
+	notional := t.Price.Mul(t.Qty)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, side := range []struct {
+		participant string
+		orderId     string
+		fee         event_sourcing.Fee
+	}{
+		{t.SupplyParticipant, t.SupplyOrderId, t.SupplyFee},
+		{t.DemandParticipant, t.DemandOrderId, t.DemandFee},
+	} {
+		if side.fee.Liquidity == "" {
+			continue
+		}
+
+		key := statementKey{participant: side.participant, month: startOfMonth(t.Timestamp)}
+		st, ok := s.statements[key]
+		if !ok {
+			st = &Statement{Participant: side.participant, Month: key.month}
+			s.statements[key] = st
+		}
+
+		st.Notional = st.Notional.Add(notional)
+		if side.fee.Liquidity == constants.MakerLiquidity {
+			st.MakerFees = st.MakerFees.Add(side.fee.Amount)
+		} else {
+			st.TakerFees = st.TakerFees.Add(side.fee.Amount)
+		}
+		st.Lines = append(st.Lines, StatementLine{
+			TradeId:   t.Id,
+			Product:   t.Product,
+			OrderId:   side.orderId,
+			Liquidity: side.fee.Liquidity,
+			Notional:  notional,
+			Fee:       side.fee.Amount,
+			At:        t.Timestamp,
+		})
+	}
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,672 @@
+package fix
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/shopspring/decimal"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHeartbeat = 30 * time.Second
+
+	timeFormat = "20060102-15:04:05.000"
+)
+
+const (
+	SideBuy  = "1"
+	SideSell = "2"
+
+	OrdTypeLimit = "2"
+
+	// ExecInstAllOrNone is the ExecInst flag of an all-or-none order.
+	ExecInstAllOrNone = "G"
+
+	ExecNew      = "0"
+	ExecCanceled = "4"
+	ExecReplaced = "5"
+	ExecRejected = "8"
+	ExecTrade    = "F"
+
+	StatusNew             = "0"
+	StatusPartiallyFilled = "1"
+	StatusFilled          = "2"
+	StatusCanceled        = "4"
+	StatusRejected        = "8"
+
+	cxlRejTooLate      = "0"
+	cxlRejUnknownOrder = "1"
+	cxlRejOther        = "99"
+
+	cxlRejToCancel  = "1"
+	cxlRejToReplace = "2"
+)
+
+// Ledger is what the gateway maps FIX orders onto.
+type Ledger interface {
+	Place(cmd app.OrderCommand) (string, []app.Trade, error)
+	Cancel(productName, participant, orderId string) error
+	Amend(productName, participant, orderId string, price, qty float64) ([]app.Trade, error)
+	Order(orderId string) (projection.OrderState, bool)
+	Subscribe(fn func(records []event_sourcing.Record))
+}
+
+type Option func(g *Gateway)
+
+// WithHeartbeat sets the heartbeat interval of sessions whose Logon does not ask for one.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(g *Gateway) {
+		g.heartbeat = interval
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(g *Gateway) {
+		g.logger = logger
+	}
+}
+
+// WithClock replaces time.Now for the SendingTime and TransactTime of outgoing messages.
+func WithClock(now func() time.Time) Option {
+	return func(g *Gateway) {
+		g.now = now
+	}
+}
+
+type conn struct {
+	id        string
+	net       net.Conn
+	session   Session
+	heartbeat time.Duration
+}
+
+// Gateway accepts FIX 4.4 sessions over TCP: Logon, Heartbeat, TestRequest, ResendRequest and Logout at the
+// session level, NewOrderSingle, OrderCancelRequest and OrderCancelReplaceRequest for limit orders, which
+// are answered with ExecutionReports and OrderCancelRejects. The SenderCompID of a session is the
+// participant of its orders. Sequence numbers and open orders are kept in the store, so that sessions
+// resume where they stopped; messages are not kept, a ResendRequest is answered with a gap fill.
+// Executions of an order are reported when they happen, whichever channel the counter order came through,
+// to its session if it is logged on.
+type Gateway struct {
+	mtx       sync.Mutex
+	ledger    Ledger
+	store     *Store
+	compID    string
+	heartbeat time.Duration
+	logger    *slog.Logger
+	now       func() time.Time
+	conns     map[string]*conn
+
+	// pending holds the trades appended to the ledger and not reported yet, guarded by pendingMtx rather than
+	// mtx as they are appended while a message holding mtx is placing an order.
+	pendingMtx sync.Mutex
+	pending    []event_sourcing.Trade
+	stopped    bool
+	traded     chan struct{}
+}
+
+// New returns a gateway whose sessions must target compID.
+func New(ledger Ledger, store *Store, compID string, opts ...Option) *Gateway {
+	g := &Gateway{
+		ledger:    ledger,
+		store:     store,
+		compID:    compID,
+		heartbeat: defaultHeartbeat,
+		logger:    slog.Default(),
+		now:       time.Now,
+		conns:     make(map[string]*conn),
+		traded:    make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	ledger.Subscribe(g.follow)
+	return g
+}
+
+// Serve accepts sessions on listener until ctx is done, and reports the executions of other channels
+// meanwhile.
+func (g *Gateway) Serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+	go g.deliver(ctx)
+
+	for {
+		nc, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go g.Handle(nc)
+	}
+}
+
+// Handle runs the session of a connection until it logs out or the connection fails.
+func (g *Gateway) Handle(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+
+	c, err := g.logon(nc, r)
+	if err != nil {
+		g.logger.Warn("fix logon failed", slog.String("remote", nc.RemoteAddr().String()), slog.String("error", err.Error()))
+		return
+	}
+	defer g.logoff(c)
+
+	done := make(chan struct{})
+	defer close(done)
+	go g.heartbeats(c, done)
+
+	for {
+		_ = nc.SetReadDeadline(time.Now().Add(2 * c.heartbeat))
+		m, err := Read(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				g.logger.Warn("fix session dropped", slog.String("session", c.id), slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		if !g.handle(c, m) {
+			return
+		}
+	}
+}
+
+func (g *Gateway) logon(nc net.Conn, r *bufio.Reader) (*conn, error) {
+	_ = nc.SetReadDeadline(time.Now().Add(g.heartbeat))
+	m, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if m.Type() != MsgLogon {
+		return nil, fmt.Errorf("expected Logon, got MsgType %s", m.Type())
+	}
+
+	id, _ := m.Get(TagSenderCompID)
+	if target, _ := m.Get(TagTargetCompID); id == "" || target != g.compID {
+		return nil, fmt.Errorf("unknown session %s->%s", id, target)
+	}
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	if _, ok := g.conns[id]; ok {
+		return nil, fmt.Errorf("session %s is already logged on", id)
+	}
+
+	c := &conn{id: id, net: nc, session: g.store.Session(id), heartbeat: g.heartbeat}
+	if secs := m.Int(TagHeartBtInt); secs > 0 {
+		c.heartbeat = time.Duration(secs) * time.Second
+	}
+
+	reset, _ := m.Get(TagResetSeqNumFlag)
+	if reset == "Y" {
+		c.session = Session{InSeq: 1, OutSeq: 1}
+	}
+
+	seq := m.Int(TagMsgSeqNum)
+	if seq < c.session.InSeq {
+		err = fmt.Errorf("MsgSeqNum too low, expected %d but received %d", c.session.InSeq, seq)
+		_ = g.send(c, NewMessage(MsgLogout).Set(TagText, err.Error()))
+		return nil, err
+	}
+	gap := seq > c.session.InSeq
+	expected := c.session.InSeq
+	c.session.InSeq = seq + 1
+
+	reply := NewMessage(MsgLogon).Set(TagEncryptMethod, "0").Set(TagHeartBtInt, strconv.Itoa(int(c.heartbeat/time.Second)))
+	if reset == "Y" {
+		reply.Set(TagResetSeqNumFlag, "Y")
+	}
+	if err = g.send(c, reply); err != nil {
+		return nil, err
+	}
+	if gap {
+		if err = g.send(c, NewMessage(MsgResendRequest).Set(TagBeginSeqNo, strconv.Itoa(expected)).Set(TagEndSeqNo, "0")); err != nil {
+			return nil, err
+		}
+	}
+
+	g.conns[id] = c
+	g.logger.Info("fix session logged on", slog.String("session", id), slog.Int("in_seq", c.session.InSeq), slog.Int("out_seq", c.session.OutSeq))
+	return c, nil
+}
+
+func (g *Gateway) logoff(c *conn) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	delete(g.conns, c.id)
+	g.logger.Info("fix session logged off", slog.String("session", c.id))
+}
+
+func (g *Gateway) heartbeats(c *conn, done chan struct{}) {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			g.mtx.Lock()
+			_ = g.send(c, NewMessage(MsgHeartbeat))
+			g.mtx.Unlock()
+		}
+	}
+}
+
+// handle processes a message of a logged on session and returns false once the session is over.
+func (g *Gateway) handle(c *conn, m *Message) bool {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	seq := m.Int(TagMsgSeqNum)
+	if seq < c.session.InSeq {
+		if dup, _ := m.Get(TagPossDupFlag); dup == "Y" {
+			return true
+		}
+		_ = g.send(c, NewMessage(MsgLogout).Set(TagText, fmt.Sprintf("MsgSeqNum too low, expected %d but received %d", c.session.InSeq, seq)))
+		return false
+	}
+	if seq > c.session.InSeq {
+		_ = g.send(c, NewMessage(MsgResendRequest).Set(TagBeginSeqNo, strconv.Itoa(c.session.InSeq)).Set(TagEndSeqNo, "0"))
+	}
+	c.session.InSeq = seq + 1
+	if err := g.store.PutSession(c.id, c.session); err != nil {
+		g.logger.Error("fix session not saved", slog.String("session", c.id), slog.String("error", err.Error()))
+	}
+
+	switch m.Type() {
+	case MsgHeartbeat:
+	case MsgTestRequest:
+		id, _ := m.Get(TagTestReqID)
+		_ = g.send(c, NewMessage(MsgHeartbeat).Set(TagTestReqID, id))
+	case MsgResendRequest:
+		g.gapFill(c, m.Int(TagBeginSeqNo))
+	case MsgLogout:
+		_ = g.send(c, NewMessage(MsgLogout))
+		return false
+	case MsgNewOrderSingle:
+		g.newOrder(c, m)
+	case MsgOrderCancelRequest:
+		g.cancel(c, m)
+	case MsgOrderCancelReplaceRequest:
+		g.replace(c, m)
+	default:
+		_ = g.send(c, NewMessage(MsgReject).Set(TagRefSeqNum, strconv.Itoa(seq)).Set(TagText, fmt.Sprintf("unsupported MsgType %s", m.Type())))
+	}
+	return true
+}
+
+func (g *Gateway) newOrder(c *conn, m *Message) {
+	o := Order{Session: c.id, OrderId: "NONE"}
+	o.ClOrdID, _ = m.Get(TagClOrdID)
+	o.Symbol, _ = m.Get(TagSymbol)
+	o.Side, _ = m.Get(TagSide)
+
+	if _, ok := g.store.OrderByClOrdID(c.id, o.ClOrdID); ok {
+		if dup, _ := m.Get(TagPossDupFlag); dup != "Y" {
+			g.reject(c, o, fmt.Errorf("duplicate ClOrdID %s", o.ClOrdID))
+		}
+		return
+	}
+
+	side, price, qty, err := parseOrder(m)
+	if err != nil {
+		g.reject(c, o, err)
+		return
+	}
+	o.Price, o.Qty = decimal.NewFromFloat(price), decimal.NewFromFloat(qty)
+
+	// DisplayQty makes an iceberg order
+	var peak float64
+	if v, ok := m.Get(TagDisplayQty); ok {
+		if peak, err = strconv.ParseFloat(v, 64); err != nil || peak <= 0 {
+			g.reject(c, o, fmt.Errorf("invalid DisplayQty %q", v))
+			return
+		}
+	}
+
+	var minQty float64
+	if v, ok := m.Get(TagMinQty); ok {
+		if minQty, err = strconv.ParseFloat(v, 64); err != nil || minQty <= 0 {
+			g.reject(c, o, fmt.Errorf("invalid MinQty %q", v))
+			return
+		}
+	}
+	// ExecInst holds space separated flags
+	execInst, _ := m.Get(TagExecInst)
+
+	id, _, err := g.ledger.Place(app.OrderCommand{
+		Participant:    c.id,
+		Product:        o.Symbol,
+		Side:           side,
+		Price:          price,
+		Qty:            qty,
+		IdempotencyKey: o.ClOrdID,
+		Peak:           peak,
+		MinQty:         minQty,
+		AllOrNone:      slices.Contains(strings.Fields(execInst), ExecInstAllOrNone),
+	})
+	if err != nil {
+		g.reject(c, o, err)
+		return
+	}
+
+	// the instrument may have rounded the price and quantity
+	o.OrderId = id
+	if placed, ok := g.ledger.Order(id); ok {
+		o.Price, o.Qty = placed.Price, placed.Qty
+	}
+	g.putOrder(o)
+	_ = g.send(c, g.report(o, ExecNew, StatusNew))
+	g.fill()
+}
+
+func (g *Gateway) cancel(c *conn, m *Message) {
+	clOrdID, _ := m.Get(TagClOrdID)
+	origClOrdID, _ := m.Get(TagOrigClOrdID)
+	g.fill()
+
+	o, ok := g.store.OrderByClOrdID(c.id, origClOrdID)
+	if !ok {
+		g.cancelReject(c, clOrdID, origClOrdID, "NONE", cxlRejToCancel, cxlRejUnknownOrder, "unknown order")
+		return
+	}
+
+	if err := g.ledger.Cancel(o.Symbol, c.id, o.OrderId); err != nil {
+		reason := cxlRejOther
+		if err.Error() == constants.OrderNotFoundErrorMessage {
+			reason = cxlRejTooLate
+		}
+		g.cancelReject(c, clOrdID, origClOrdID, o.OrderId, cxlRejToCancel, reason, err.Error())
+		return
+	}
+
+	o.ClOrdID = clOrdID
+	if err := g.store.DeleteOrder(o.OrderId); err != nil {
+		g.logger.Error("fix order not saved", slog.String("order_id", o.OrderId), slog.String("error", err.Error()))
+	}
+	_ = g.send(c, g.report(o, ExecCanceled, StatusCanceled).Set(TagOrigClOrdID, origClOrdID))
+}
+
+// replace amends the price and total quantity of an order. The quantity left to fill must stay positive, the
+// filled quantity is read from the ledger as other channels may have filled the order since it was reported.
+func (g *Gateway) replace(c *conn, m *Message) {
+	clOrdID, _ := m.Get(TagClOrdID)
+	origClOrdID, _ := m.Get(TagOrigClOrdID)
+	g.fill()
+
+	o, ok := g.store.OrderByClOrdID(c.id, origClOrdID)
+	if !ok {
+		g.cancelReject(c, clOrdID, origClOrdID, "NONE", cxlRejToReplace, cxlRejUnknownOrder, "unknown order")
+		return
+	}
+	filled := o.CumQty
+	if placed, ok := g.ledger.Order(o.OrderId); ok && placed.Open() {
+		// the ledger order holds what is left of the quantity last placed, fills not reported yet included
+		filled = o.Qty.Sub(placed.LeavesQty)
+	}
+
+	_, price, qty, err := parseOrder(m)
+	if err == nil && !decimal.NewFromFloat(qty).GreaterThan(filled) {
+		err = fmt.Errorf("OrderQty must be above the filled quantity %s", filled.String())
+	}
+	if err != nil {
+		g.cancelReject(c, clOrdID, origClOrdID, o.OrderId, cxlRejToReplace, cxlRejOther, err.Error())
+		return
+	}
+
+	leaves, _ := decimal.NewFromFloat(qty).Sub(filled).Float64()
+	_, err = g.ledger.Amend(o.Symbol, c.id, o.OrderId, price, leaves)
+	if err != nil && err.Error() == constants.OrderNotFoundErrorMessage {
+		g.cancelReject(c, clOrdID, origClOrdID, o.OrderId, cxlRejToReplace, cxlRejTooLate, err.Error())
+		return
+	}
+	if placed, ok := g.ledger.Order(o.OrderId); err != nil && ok && placed.Open() {
+		// The replacement was refused before the order was cancelled.
+		g.cancelReject(c, clOrdID, origClOrdID, o.OrderId, cxlRejToReplace, cxlRejOther, err.Error())
+		return
+	}
+	if err != nil {
+		// The order was cancelled but its replacement was rejected.
+		o.ClOrdID = clOrdID
+		if err := g.store.DeleteOrder(o.OrderId); err != nil {
+			g.logger.Error("fix order not saved", slog.String("order_id", o.OrderId), slog.String("error", err.Error()))
+		}
+		_ = g.send(c, g.report(o, ExecCanceled, StatusCanceled).Set(TagOrigClOrdID, origClOrdID).Set(TagText, err.Error()))
+		return
+	}
+
+	o.ClOrdID, o.Price, o.Qty = clOrdID, decimal.NewFromFloat(price), decimal.NewFromFloat(qty)
+	if replaced, ok := g.ledger.Order(o.OrderId); ok {
+		o.Price, o.Qty = replaced.Price, filled.Add(replaced.Qty)
+	}
+	g.putOrder(o)
+	_ = g.send(c, g.report(o, ExecReplaced, status(o)).Set(TagOrigClOrdID, origClOrdID))
+	g.fill()
+}
+
+// follow queues the trades of the records appended to the ledger, to be reported by fill.
+func (g *Gateway) follow(records []event_sourcing.Record) {
+	var trades []event_sourcing.Trade
+	for _, record := range records {
+		if te, ok := record.Event.(event_sourcing.TradeEvent); ok {
+			trades = append(trades, te.Trade())
+		}
+	}
+	if len(trades) == 0 {
+		return
+	}
+
+	g.pendingMtx.Lock()
+	defer g.pendingMtx.Unlock()
+
+	if g.stopped {
+		return
+	}
+	g.pending = append(g.pending, trades...)
+	select {
+	case g.traded <- struct{}{}:
+	default:
+	}
+}
+
+// deliver reports the trades of other channels as they are queued, until ctx is done.
+func (g *Gateway) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			g.pendingMtx.Lock()
+			g.stopped, g.pending = true, nil
+			g.pendingMtx.Unlock()
+			return
+		case <-g.traded:
+			g.mtx.Lock()
+			g.fill()
+			g.mtx.Unlock()
+		}
+	}
+}
+
+// fill reports the queued trades to both orders, if they were entered through the gateway.
+func (g *Gateway) fill() {
+	g.pendingMtx.Lock()
+	trades := g.pending
+	g.pending = nil
+	g.pendingMtx.Unlock()
+
+	for _, t := range trades {
+		for _, matched := range []string{t.DemandOrderId, t.SupplyOrderId} {
+			o, ok := g.store.Order(matched)
+			if !ok {
+				continue
+			}
+
+			o.CumQty = o.CumQty.Add(t.Qty)
+			o.Notional = o.Notional.Add(t.Qty.Mul(t.Price))
+			if o.LeavesQty().IsZero() {
+				if err := g.store.DeleteOrder(o.OrderId); err != nil {
+					g.logger.Error("fix order not saved", slog.String("order_id", o.OrderId), slog.String("error", err.Error()))
+				}
+			} else {
+				g.putOrder(o)
+			}
+
+			if c, ok := g.conns[o.Session]; ok {
+				_ = g.send(c, g.report(o, ExecTrade, status(o)).Set(TagLastQty, t.Qty.String()).Set(TagLastPx, t.Price.String()))
+			}
+		}
+	}
+}
+
+func (g *Gateway) reject(c *conn, o Order, err error) {
+	_ = g.send(c, g.report(o, ExecRejected, StatusRejected).Set(TagOrdRejReason, "99").Set(TagText, err.Error()))
+}
+
+func (g *Gateway) cancelReject(c *conn, clOrdID, origClOrdID, orderId, responseTo, reason, text string) {
+	ordStatus := StatusRejected
+	if o, ok := g.store.Order(orderId); ok {
+		ordStatus = status(o)
+	}
+
+	_ = g.send(c, NewMessage(MsgOrderCancelReject).
+		Set(TagOrderID, orderId).
+		Set(TagClOrdID, clOrdID).
+		Set(TagOrigClOrdID, origClOrdID).
+		Set(TagOrdStatus, ordStatus).
+		Set(TagCxlRejResponseTo, responseTo).
+		Set(TagCxlRejReason, reason).
+		Set(TagText, text))
+}
+
+// gapFill answers a ResendRequest: messages are not kept, so the counterparty is told to skip to the next
+// sequence number.
+func (g *Gateway) gapFill(c *conn, begin int) {
+	next := c.session.OutSeq
+	c.session.OutSeq = begin
+	_ = g.send(c, NewMessage(MsgSequenceReset).Set(TagPossDupFlag, "Y").Set(TagGapFillFlag, "Y").Set(TagNewSeqNo, strconv.Itoa(next)))
+	c.session.OutSeq = next
+	if err := g.store.PutSession(c.id, c.session); err != nil {
+		g.logger.Error("fix session not saved", slog.String("session", c.id), slog.String("error", err.Error()))
+	}
+}
+
+func (g *Gateway) report(o Order, execType, ordStatus string) *Message {
+	leaves := o.LeavesQty()
+	if ordStatus == StatusCanceled || ordStatus == StatusRejected {
+		leaves = decimal.Zero
+	}
+
+	return NewMessage(MsgExecutionReport).
+		Set(TagOrderID, o.OrderId).
+		Set(TagClOrdID, o.ClOrdID).
+		Set(TagExecID, uuid.New().String()).
+		Set(TagExecType, execType).
+		Set(TagOrdStatus, ordStatus).
+		Set(TagSymbol, o.Symbol).
+		Set(TagSide, o.Side).
+		Set(TagOrderQty, o.Qty.String()).
+		Set(TagPrice, o.Price.String()).
+		Set(TagLeavesQty, leaves.String()).
+		Set(TagCumQty, o.CumQty.String()).
+		Set(TagAvgPx, o.AvgPx().String()).
+		Set(TagTransactTime, g.now().UTC().Format(timeFormat))
+}
+
+// send stamps the header of a message with the next outgoing sequence number and writes it.
+func (g *Gateway) send(c *conn, m *Message) error {
+	out := NewMessage(m.Type()).
+		Set(TagSenderCompID, g.compID).
+		Set(TagTargetCompID, c.id).
+		Set(TagMsgSeqNum, strconv.Itoa(c.session.OutSeq)).
+		Set(TagSendingTime, g.now().UTC().Format(timeFormat))
+	out.Fields = append(out.Fields, m.Fields[1:]...)
+
+	c.session.OutSeq++
+	if err := g.store.PutSession(c.id, c.session); err != nil {
+		g.logger.Error("fix session not saved", slog.String("session", c.id), slog.String("error", err.Error()))
+	}
+
+	_ = c.net.SetWriteDeadline(time.Now().Add(c.heartbeat))
+	if _, err := c.net.Write(out.Encode()); err != nil {
+		g.logger.Warn("fix message not sent", slog.String("session", c.id), slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+func (g *Gateway) putOrder(o Order) {
+	if err := g.store.PutOrder(o); err != nil {
+		g.logger.Error("fix order not saved", slog.String("order_id", o.OrderId), slog.String("error", err.Error()))
+	}
+}
+
+// parseOrder reads the side, price and quantity of a limit order.
+func parseOrder(m *Message) (string, float64, float64, error) {
+	if id, _ := m.Get(TagClOrdID); id == "" {
+		return "", 0, 0, errors.New("ClOrdID is required")
+	}
+	if symbol, _ := m.Get(TagSymbol); symbol == "" {
+		return "", 0, 0, errors.New("Symbol is required")
+	}
+
+	var side string
+	switch v, _ := m.Get(TagSide); v {
+	case SideBuy:
+		side = constants.DemandOrderType
+	case SideSell:
+		side = constants.SupplyOrderType
+	default:
+		return "", 0, 0, fmt.Errorf("unsupported Side %q", v)
+	}
+
+	if ordType, _ := m.Get(TagOrdType); ordType != OrdTypeLimit {
+		return "", 0, 0, fmt.Errorf("unsupported OrdType %q, only limit orders are accepted", ordType)
+	}
+
+	v, _ := m.Get(TagPrice)
+	price, err := strconv.ParseFloat(v, 64)
+	if err != nil || price <= 0 {
+		return "", 0, 0, fmt.Errorf("invalid Price %q", v)
+	}
+
+	v, _ = m.Get(TagOrderQty)
+	qty, err := strconv.ParseFloat(v, 64)
+	if err != nil || qty <= 0 {
+		return "", 0, 0, fmt.Errorf("invalid OrderQty %q", v)
+	}
+
+	return side, price, qty, nil
+}
+
+func status(o Order) string {
+	switch {
+	case o.LeavesQty().IsZero():
+		return StatusFilled
+	case o.CumQty.IsPositive():
+		return StatusPartiallyFilled
+	default:
+		return StatusNew
+	}
+}
@@ -0,0 +1,282 @@
+package fix_test
+
+import (
+	"bufio"
+	"context"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/config"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fix"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/instrument"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type gatewaySuite struct {
+	suite.Suite
+	store  string
+	ledger *app.App
+	addr   string
+	stop   context.CancelFunc
+}
+
+func TestGatewaySuite(t *testing.T) {
+	suite.Run(t, new(gatewaySuite))
+}
+
+func (suite *gatewaySuite) SetupTest() {
+	cfg, err := config.Load("../../../configs", "")
+	suite.Require().NoError(err)
+
+	suite.ledger, err = app.New(cfg, logging.Discard())
+	suite.Require().NoError(err)
+
+	suite.store = filepath.Join(suite.T().TempDir(), "fix-sessions.json")
+	suite.start()
+}
+
+func (suite *gatewaySuite) TearDownTest() {
+	suite.stop()
+}
+
+// start serves a gateway loaded from the store, as a restarted process would.
+func (suite *gatewaySuite) start() {
+	store, err := fix.LoadStore(suite.store)
+	suite.Require().NoError(err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	suite.addr = listener.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	suite.stop = cancel
+
+	gateway := fix.New(suite.ledger, store, "LEDGER", fix.WithHeartbeat(time.Hour), fix.WithLogger(logging.Discard()))
+	go func() { _ = gateway.Serve(ctx, listener) }()
+}
+
+type client struct {
+	suite *gatewaySuite
+	id    string
+	conn  net.Conn
+	r     *bufio.Reader
+	seq   int
+}
+
+func (suite *gatewaySuite) dial(id string, seq int) *client {
+	conn, err := net.Dial("tcp", suite.addr)
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() { _ = conn.Close() })
+
+	return &client{suite: suite, id: id, conn: conn, r: bufio.NewReader(conn), seq: seq}
+}
+
+// logon dials the gateway and logs on, expecting the Logon reply.
+func (suite *gatewaySuite) logon(id string) *client {
+	c := suite.dial(id, 1)
+	c.send(fix.MsgLogon, fix.Field{Tag: fix.TagEncryptMethod, Value: "0"}, fix.Field{Tag: fix.TagHeartBtInt, Value: "30"})
+	c.expect(fix.MsgLogon)
+	return c
+}
+
+func (c *client) send(msgType string, fields ...fix.Field) {
+	m := fix.NewMessage(msgType).
+		Set(fix.TagSenderCompID, c.id).
+		Set(fix.TagTargetCompID, "LEDGER").
+		Set(fix.TagMsgSeqNum, strconv.Itoa(c.seq)).
+		Set(fix.TagSendingTime, time.Now().UTC().Format("20060102-15:04:05.000"))
+	m.Fields = append(m.Fields, fields...)
+	c.seq++
+
+	_, err := c.conn.Write(m.Encode())
+	c.suite.Require().NoError(err)
+}
+
+func (c *client) expect(msgType string) *fix.Message {
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	m, err := fix.Read(c.r)
+	c.suite.Require().NoError(err)
+	c.suite.Require().Equal(msgType, m.Type(), m.String())
+	return m
+}
+
+func (c *client) order(msgType, clOrdID, side, price, qty string, extra ...fix.Field) {
+	fields := []fix.Field{
+		{Tag: fix.TagClOrdID, Value: clOrdID},
+		{Tag: fix.TagSymbol, Value: "tomato"},
+		{Tag: fix.TagSide, Value: side},
+		{Tag: fix.TagOrdType, Value: fix.OrdTypeLimit},
+		{Tag: fix.TagPrice, Value: price},
+		{Tag: fix.TagOrderQty, Value: qty},
+	}
+	c.send(msgType, append(fields, extra...)...)
+}
+
+func (suite *gatewaySuite) assertReport(m *fix.Message, execType, ordStatus, cumQty, leavesQty string) {
+	suite.Require().Equal(fix.MsgExecutionReport, m.Type(), m.String())
+	for tag, want := range map[fix.Tag]string{fix.TagExecType: execType, fix.TagOrdStatus: ordStatus, fix.TagCumQty: cumQty, fix.TagLeavesQty: leavesQty} {
+		got, _ := m.Get(tag)
+		suite.Assert().Equal(want, got, "tag %d of %s", tag, m.String())
+	}
+}
+
+func (suite *gatewaySuite) TestReportsAcksAndFillsToBothSessions() {
+	seller := suite.logon("grower-1")
+	buyer := suite.logon("buyer-1")
+
+	seller.order(fix.MsgNewOrderSingle, "s-1", fix.SideSell, "24", "100")
+	ack := seller.expect(fix.MsgExecutionReport)
+	suite.assertReport(ack, fix.ExecNew, fix.StatusNew, "0", "100")
+	orderId, _ := ack.Get(fix.TagOrderID)
+
+	buyer.order(fix.MsgNewOrderSingle, "b-1", fix.SideBuy, "25", "30")
+	suite.assertReport(buyer.expect(fix.MsgExecutionReport), fix.ExecNew, fix.StatusNew, "0", "30")
+
+	fill := buyer.expect(fix.MsgExecutionReport)
+	suite.assertReport(fill, fix.ExecTrade, fix.StatusFilled, "30", "0")
+	lastPx, _ := fill.Get(fix.TagLastPx)
+	suite.Assert().Equal("24", lastPx)
+
+	passive := seller.expect(fix.MsgExecutionReport)
+	suite.assertReport(passive, fix.ExecTrade, fix.StatusPartiallyFilled, "30", "70")
+	passiveId, _ := passive.Get(fix.TagOrderID)
+	suite.Assert().Equal(orderId, passiveId)
+
+	_, supplies, err := suite.ledger.Book("tomato")
+	suite.Require().NoError(err)
+	suite.Require().Len(supplies, 1)
+	suite.Assert().Equal("grower-1", supplies[0].Participant)
+	suite.Assert().Equal("70", supplies[0].Qty.String())
+}
+
+func (suite *gatewaySuite) TestReplacesAndCancelsOrders() {
+	seller := suite.logon("grower-1")
+	buyer := suite.logon("buyer-1")
+
+	buyer.order(fix.MsgNewOrderSingle, "b-1", fix.SideBuy, "22", "40")
+	buyer.expect(fix.MsgExecutionReport)
+	seller.order(fix.MsgNewOrderSingle, "s-1", fix.SideSell, "24", "100")
+	seller.expect(fix.MsgExecutionReport)
+
+	seller.order(fix.MsgOrderCancelReplaceRequest, "s-2", fix.SideSell, "22", "60", fix.Field{Tag: fix.TagOrigClOrdID, Value: "s-1"})
+	replaced := seller.expect(fix.MsgExecutionReport)
+	suite.assertReport(replaced, fix.ExecReplaced, fix.StatusNew, "0", "60")
+	orig, _ := replaced.Get(fix.TagOrigClOrdID)
+	suite.Assert().Equal("s-1", orig)
+	suite.assertReport(seller.expect(fix.MsgExecutionReport), fix.ExecTrade, fix.StatusPartiallyFilled, "40", "20")
+	suite.assertReport(buyer.expect(fix.MsgExecutionReport), fix.ExecTrade, fix.StatusFilled, "40", "0")
+
+	seller.send(fix.MsgOrderCancelRequest, fix.Field{Tag: fix.TagClOrdID, Value: "s-3"}, fix.Field{Tag: fix.TagOrigClOrdID, Value: "s-1"}, fix.Field{Tag: fix.TagSymbol, Value: "tomato"}, fix.Field{Tag: fix.TagSide, Value: fix.SideSell})
+	rejected := seller.expect(fix.MsgOrderCancelReject)
+	reason, _ := rejected.Get(fix.TagCxlRejReason)
+	suite.Assert().Equal("1", reason, "the order is now known as s-2")
+
+	seller.send(fix.MsgOrderCancelRequest, fix.Field{Tag: fix.TagClOrdID, Value: "s-3"}, fix.Field{Tag: fix.TagOrigClOrdID, Value: "s-2"}, fix.Field{Tag: fix.TagSymbol, Value: "tomato"}, fix.Field{Tag: fix.TagSide, Value: fix.SideSell})
+	suite.assertReport(seller.expect(fix.MsgExecutionReport), fix.ExecCanceled, fix.StatusCanceled, "40", "0")
+
+	_, supplies, err := suite.ledger.Book("tomato")
+	suite.Require().NoError(err)
+	suite.Assert().Empty(supplies)
+}
+
+func (suite *gatewaySuite) TestReportsFillsOfOtherChannelsAndReplacesWhatIsLeft() {
+	seller := suite.logon("grower-1")
+	seller.order(fix.MsgNewOrderSingle, "s-1", fix.SideSell, "24", "100")
+	seller.expect(fix.MsgExecutionReport)
+
+	_, err := suite.ledger.Submit(app.OrderCommand{Participant: "buyer-1", Product: "tomato", Side: "DEMAND", Price: 25, Qty: 30})
+	suite.Require().NoError(err)
+
+	seller.order(fix.MsgOrderCancelReplaceRequest, "s-2", fix.SideSell, "24", "50", fix.Field{Tag: fix.TagOrigClOrdID, Value: "s-1"})
+	fill := seller.expect(fix.MsgExecutionReport)
+	suite.assertReport(fill, fix.ExecTrade, fix.StatusPartiallyFilled, "30", "70")
+	lastQty, _ := fill.Get(fix.TagLastQty)
+	suite.Assert().Equal("30", lastQty)
+	suite.assertReport(seller.expect(fix.MsgExecutionReport), fix.ExecReplaced, fix.StatusPartiallyFilled, "30", "20")
+
+	_, supplies, err := suite.ledger.Book("tomato")
+	suite.Require().NoError(err)
+	suite.Require().Len(supplies, 1)
+	suite.Assert().Equal("20", supplies[0].Qty.String())
+
+	_, err = suite.ledger.Submit(app.OrderCommand{Participant: "buyer-1", Product: "tomato", Side: "DEMAND", Price: 24, Qty: 20})
+	suite.Require().NoError(err)
+	suite.assertReport(seller.expect(fix.MsgExecutionReport), fix.ExecTrade, fix.StatusFilled, "50", "0")
+}
+
+func (suite *gatewaySuite) TestReportsTheOrderAsTheInstrumentRoundedIt() {
+	cfg, err := config.Load("../../../configs", "")
+	suite.Require().NoError(err)
+	cfg.Products[0].Instrument.Rounding = instrument.RoundPolicy
+
+	suite.stop()
+	suite.ledger, err = app.New(cfg, logging.Discard())
+	suite.Require().NoError(err)
+	suite.start()
+
+	c := suite.logon("grower-1")
+	c.order(fix.MsgNewOrderSingle, "s-1", fix.SideSell, "24.004", "100.6")
+	ack := c.expect(fix.MsgExecutionReport)
+	suite.assertReport(ack, fix.ExecNew, fix.StatusNew, "0", "100")
+	price, _ := ack.Get(fix.TagPrice)
+	suite.Assert().Equal("24.01", price)
+
+	c.order(fix.MsgOrderCancelReplaceRequest, "s-2", fix.SideSell, "23.996", "80.2", fix.Field{Tag: fix.TagOrigClOrdID, Value: "s-1"})
+	replaced := c.expect(fix.MsgExecutionReport)
+	suite.assertReport(replaced, fix.ExecReplaced, fix.StatusNew, "0", "80")
+	price, _ = replaced.Get(fix.TagPrice)
+	suite.Assert().Equal("24", price)
+}
+
+func (suite *gatewaySuite) TestRejectsInvalidMessages() {
+	c := suite.logon("grower-1")
+
+	c.order(fix.MsgNewOrderSingle, "s-1", "7", "24", "100")
+	rejected := c.expect(fix.MsgExecutionReport)
+	suite.assertReport(rejected, fix.ExecRejected, fix.StatusRejected, "0", "0")
+	text, _ := rejected.Get(fix.TagText)
+	suite.Assert().Equal(`unsupported Side "7"`, text)
+
+	c.send("AE")
+	c.expect(fix.MsgReject)
+
+	c.send(fix.MsgTestRequest, fix.Field{Tag: fix.TagTestReqID, Value: "ping"})
+	heartbeat := c.expect(fix.MsgHeartbeat)
+	id, _ := heartbeat.Get(fix.TagTestReqID)
+	suite.Assert().Equal("ping", id)
+}
+
+func (suite *gatewaySuite) TestResumesSequenceNumbersAfterRestart() {
+	c := suite.logon("grower-1")
+	c.order(fix.MsgNewOrderSingle, "s-1", fix.SideSell, "24", "100")
+	c.expect(fix.MsgExecutionReport)
+	c.send(fix.MsgLogout)
+	suite.Assert().Equal(3, c.expect(fix.MsgLogout).Int(fix.TagMsgSeqNum))
+	suite.waitClosed(c)
+
+	suite.stop()
+	suite.start()
+
+	stale := suite.dial("grower-1", 2)
+	stale.send(fix.MsgLogon, fix.Field{Tag: fix.TagHeartBtInt, Value: "30"})
+	suite.Assert().Equal(4, stale.expect(fix.MsgLogout).Int(fix.TagMsgSeqNum))
+	suite.waitClosed(stale)
+
+	resumed := suite.dial("grower-1", 4)
+	resumed.send(fix.MsgLogon, fix.Field{Tag: fix.TagHeartBtInt, Value: "30"})
+	suite.Assert().Equal(5, resumed.expect(fix.MsgLogon).Int(fix.TagMsgSeqNum))
+
+	resumed.send(fix.MsgOrderCancelRequest, fix.Field{Tag: fix.TagClOrdID, Value: "s-2"}, fix.Field{Tag: fix.TagOrigClOrdID, Value: "s-1"}, fix.Field{Tag: fix.TagSymbol, Value: "tomato"}, fix.Field{Tag: fix.TagSide, Value: fix.SideSell})
+	suite.assertReport(resumed.expect(fix.MsgExecutionReport), fix.ExecCanceled, fix.StatusCanceled, "0", "0")
+}
+
+func (suite *gatewaySuite) waitClosed(c *client) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err := c.r.ReadByte()
+	suite.Require().ErrorIs(err, io.EOF)
+}
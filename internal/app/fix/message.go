@@ -0,0 +1,252 @@
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	BeginString = "FIX.4.4"
+
+	soh = '\x01'
+)
+
+type Tag int
+
+const (
+	TagAvgPx            Tag = 6
+	TagBeginSeqNo       Tag = 7
+	TagBeginString      Tag = 8
+	TagBodyLength       Tag = 9
+	TagCheckSum         Tag = 10
+	TagClOrdID          Tag = 11
+	TagCumQty           Tag = 14
+	TagEndSeqNo         Tag = 16
+	TagExecID           Tag = 17
+	TagExecInst         Tag = 18
+	TagLastPx           Tag = 31
+	TagLastQty          Tag = 32
+	TagMsgSeqNum        Tag = 34
+	TagMsgType          Tag = 35
+	TagNewSeqNo         Tag = 36
+	TagOrderID          Tag = 37
+	TagOrderQty         Tag = 38
+	TagOrdStatus        Tag = 39
+	TagOrdType          Tag = 40
+	TagOrigClOrdID      Tag = 41
+	TagPossDupFlag      Tag = 43
+	TagPrice            Tag = 44
+	TagRefSeqNum        Tag = 45
+	TagSenderCompID     Tag = 49
+	TagSendingTime      Tag = 52
+	TagSide             Tag = 54
+	TagSymbol           Tag = 55
+	TagTargetCompID     Tag = 56
+	TagText             Tag = 58
+	TagTransactTime     Tag = 60
+	TagEncryptMethod    Tag = 98
+	TagCxlRejReason     Tag = 102
+	TagOrdRejReason     Tag = 103
+	TagHeartBtInt       Tag = 108
+	TagMinQty           Tag = 110
+	TagTestReqID        Tag = 112
+	TagGapFillFlag      Tag = 123
+	TagResetSeqNumFlag  Tag = 141
+	TagExecType         Tag = 150
+	TagLeavesQty        Tag = 151
+	TagCxlRejResponseTo Tag = 434
+	TagDisplayQty       Tag = 1138
+)
+
+const (
+	MsgHeartbeat                 = "0"
+	MsgTestRequest               = "1"
+	MsgResendRequest             = "2"
+	MsgReject                    = "3"
+	MsgSequenceReset             = "4"
+	MsgLogout                    = "5"
+	MsgExecutionReport           = "8"
+	MsgOrderCancelReject         = "9"
+	MsgLogon                     = "A"
+	MsgNewOrderSingle            = "D"
+	MsgOrderCancelRequest        = "F"
+	MsgOrderCancelReplaceRequest = "G"
+)
+
+type Field struct {
+	Tag   Tag
+	Value string
+}
+
+// Message is a FIX message without its framing: BeginString, BodyLength and CheckSum are added by Encode
+// and checked by Read. Fields keep the order they were set or read in.
+type Message struct {
+	Fields []Field
+}
+
+// NewMessage starts a message of the given type.
+func NewMessage(msgType string) *Message {
+	return &Message{Fields: []Field{{Tag: TagMsgType, Value: msgType}}}
+}
+
+func (m *Message) Type() string {
+	v, _ := m.Get(TagMsgType)
+	return v
+}
+
+func (m *Message) Get(tag Tag) (string, bool) {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// Int returns the value of an integer field, 0 if the field is missing or not an integer.
+func (m *Message) Int(tag Tag) int {
+	v, _ := m.Get(tag)
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+// Set replaces the value of a field, or appends the field if the message does not have it.
+func (m *Message) Set(tag Tag, value string) *Message {
+	for i, f := range m.Fields {
+		if f.Tag == tag {
+			m.Fields[i].Value = value
+			return m
+		}
+	}
+	m.Fields = append(m.Fields, Field{Tag: tag, Value: value})
+	return m
+}
+
+// Encode frames the message. MsgType goes first in the body as FIX requires, the other fields follow in
+// their order.
+func (m *Message) Encode() []byte {
+	var body bytes.Buffer
+	writeField(&body, TagMsgType, m.Type())
+	for _, f := range m.Fields {
+		if f.Tag != TagMsgType && f.Tag != TagBeginString && f.Tag != TagBodyLength && f.Tag != TagCheckSum {
+			writeField(&body, f.Tag, f.Value)
+		}
+	}
+
+	var out bytes.Buffer
+	writeField(&out, TagBeginString, BeginString)
+	writeField(&out, TagBodyLength, strconv.Itoa(body.Len()))
+	out.Write(body.Bytes())
+	writeField(&out, TagCheckSum, fmt.Sprintf("%03d", checksum(out.Bytes())))
+	return out.Bytes()
+}
+
+// String renders the message with | in place of SOH, for logs.
+func (m *Message) String() string {
+	return strings.ReplaceAll(string(m.Encode()), string(soh), "|")
+}
+
+// Read reads the next message, checking its BeginString, BodyLength and CheckSum. It returns io.EOF when
+// the input ends between messages.
+func Read(r *bufio.Reader) (*Message, error) {
+	begin, err := readField(r)
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if begin.Tag != TagBeginString || begin.Value != BeginString {
+		return nil, fmt.Errorf("expected BeginString %s, got %d=%s", BeginString, begin.Tag, begin.Value)
+	}
+
+	length, err := readField(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	n, err := strconv.Atoi(length.Value)
+	if length.Tag != TagBodyLength || err != nil || n < 0 {
+		return nil, fmt.Errorf("expected BodyLength, got %d=%s", length.Tag, length.Value)
+	}
+
+	body := make([]byte, n)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	trailer, err := readField(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if trailer.Tag != TagCheckSum {
+		return nil, fmt.Errorf("expected CheckSum after %d bytes of body, got %d=%s", n, trailer.Tag, trailer.Value)
+	}
+
+	var framed bytes.Buffer
+	writeField(&framed, TagBeginString, begin.Value)
+	writeField(&framed, TagBodyLength, length.Value)
+	framed.Write(body)
+	if want := fmt.Sprintf("%03d", checksum(framed.Bytes())); trailer.Value != want {
+		return nil, fmt.Errorf("invalid CheckSum %s, expected %s", trailer.Value, want)
+	}
+
+	m := &Message{}
+	for _, raw := range bytes.Split(bytes.TrimSuffix(body, []byte{soh}), []byte{soh}) {
+		f, err := parseField(string(raw))
+		if err != nil {
+			return nil, err
+		}
+		m.Fields = append(m.Fields, f)
+	}
+	if m.Type() == "" {
+		return nil, errors.New("missing MsgType")
+	}
+	return m, nil
+}
+
+func readField(r *bufio.Reader) (Field, error) {
+	raw, err := r.ReadString(soh)
+	if errors.Is(err, io.EOF) && raw != "" {
+		return Field{}, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return Field{}, err
+	}
+	return parseField(strings.TrimSuffix(raw, string(soh)))
+}
+
+func parseField(raw string) (Field, error) {
+	tag, value, ok := strings.Cut(raw, "=")
+	n, err := strconv.Atoi(tag)
+	if !ok || err != nil || n <= 0 {
+		return Field{}, fmt.Errorf("malformed field %q", raw)
+	}
+	return Field{Tag: Tag(n), Value: value}, nil
+}
+
+func writeField(w *bytes.Buffer, tag Tag, value string) {
+	w.WriteString(strconv.Itoa(int(tag)))
+	w.WriteByte('=')
+	w.WriteString(value)
+	w.WriteByte(soh)
+}
+
+func checksum(b []byte) int {
+	sum := 0
+	for _, c := range b {
+		sum += int(c)
+	}
+	return sum % 256
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
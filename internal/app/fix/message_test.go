@@ -0,0 +1,41 @@
+package fix_test
+
+import (
+	"bufio"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fix"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMessage_EncodesAndReadsFraming(t *testing.T) {
+	m := fix.NewMessage(fix.MsgNewOrderSingle).Set(fix.TagClOrdID, "b-1").Set(fix.TagSymbol, "tomato")
+	assert.Equal(t, "8=FIX.4.4|9=22|35=D|11=b-1|55=tomato|10=130|", m.String())
+
+	r := bufio.NewReader(strings.NewReader(string(m.Encode()) + string(m.Encode())))
+	for i := 0; i < 2; i++ {
+		read, err := fix.Read(r)
+		require.NoError(t, err)
+		assert.Equal(t, fix.MsgNewOrderSingle, read.Type())
+		symbol, _ := read.Get(fix.TagSymbol)
+		assert.Equal(t, "tomato", symbol)
+	}
+
+	_, err := fix.Read(r)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestMessage_RejectsCorruptedMessages(t *testing.T) {
+	encoded := string(fix.NewMessage(fix.MsgHeartbeat).Encode())
+
+	for name, raw := range map[string]string{
+		"checksum":     strings.Replace(encoded, "35=0", "35=1", 1),
+		"begin string": strings.Replace(encoded, "FIX.4.4", "FIX.4.2", 1),
+		"truncated":    encoded[:len(encoded)-4],
+	} {
+		_, err := fix.Read(bufio.NewReader(strings.NewReader(raw)))
+		assert.Error(t, err, name)
+	}
+}
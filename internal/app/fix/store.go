@@ -0,0 +1,165 @@
+package fix
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/shopspring/decimal"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Session is what is kept of a FIX session between connections: the sequence number expected of the next
+// message from the counterparty and the one of the next message sent to it.
+type Session struct {
+	InSeq  int `json:"in_seq"`
+	OutSeq int `json:"out_seq"`
+}
+
+// Order is an open order entered through the gateway, kept to report its executions. ClOrdID is the id
+// the session last used for it, Qty its total quantity and CumQty and Notional what was filled of it.
+type Order struct {
+	Session  string          `json:"session"`
+	ClOrdID  string          `json:"cl_ord_id"`
+	OrderId  string          `json:"order_id"`
+	Symbol   string          `json:"symbol"`
+	Side     string          `json:"side"`
+	Price    decimal.Decimal `json:"price"`
+	Qty      decimal.Decimal `json:"qty"`
+	CumQty   decimal.Decimal `json:"cum_qty"`
+	Notional decimal.Decimal `json:"notional"`
+}
+
+func (o Order) LeavesQty() decimal.Decimal {
+	return decimal.Max(o.Qty.Sub(o.CumQty), decimal.Zero)
+}
+
+// AvgPx returns the average price of the fills of the order, 0 if it has none.
+func (o Order) AvgPx() decimal.Decimal {
+	if o.CumQty.IsZero() {
+		return decimal.Zero
+	}
+	return o.Notional.Div(o.CumQty)
+}
+
+type storeData struct {
+	Sessions map[string]Session `json:"sessions"`
+	Orders   map[string]Order   `json:"orders"`
+}
+
+// Store keeps the sessions and open orders of the gateway in a local JSON file, replaced atomically on
+// every save, so that sessions resume their sequence numbers after a restart.
+type Store struct {
+	mtx  sync.Mutex
+	path string
+	data storeData
+}
+
+// LoadStore reads the store saved at path, an empty one if the file does not exist yet.
+func LoadStore(path string) (*Store, error) {
+	s := &Store{path: path, data: storeData{Sessions: make(map[string]Session), Orders: make(map[string]Order)}}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(raw, &s.data); err != nil {
+		return nil, err
+	}
+	if s.data.Sessions == nil {
+		s.data.Sessions = make(map[string]Session)
+	}
+	if s.data.Orders == nil {
+		s.data.Orders = make(map[string]Order)
+	}
+	return s, nil
+}
+
+// Session returns the session of a counterparty, starting both sequences at 1 for a new one.
+func (s *Store) Session(id string) Session {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	session, ok := s.data.Sessions[id]
+	if !ok {
+		return Session{InSeq: 1, OutSeq: 1}
+	}
+	return session
+}
+
+func (s *Store) PutSession(id string, session Session) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.data.Sessions[id] = session
+	return s.save()
+}
+
+func (s *Store) Order(orderId string) (Order, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	o, ok := s.data.Orders[orderId]
+	return o, ok
+}
+
+// OrderByClOrdID returns the open order a session last referred to as clOrdID.
+func (s *Store) OrderByClOrdID(session, clOrdID string) (Order, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, o := range s.data.Orders {
+		if o.Session == session && o.ClOrdID == clOrdID {
+			return o, true
+		}
+	}
+	return Order{}, false
+}
+
+func (s *Store) PutOrder(o Order) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.data.Orders[o.OrderId] = o
+	return s.save()
+}
+
+// DeleteOrder forgets an order once it was filled or cancelled.
+func (s *Store) DeleteOrder(orderId string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.data.Orders, orderId)
+	return s.save()
+}
+
+func (s *Store) save() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,168 @@
+package ingestion
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	IdField          = "id"
+	ParticipantField = "participant"
+	ProductField     = "product"
+	SideField        = "side"
+	PriceField       = "price"
+	QtyField         = "qty"
+	PeakField        = "peak"
+	StopPriceField   = "stop_price"
+	MinQtyField      = "min_qty"
+	AllOrNoneField   = "all_or_none"
+)
+
+var Fields = []string{IdField, ParticipantField, ProductField, SideField, PriceField, QtyField, PeakField, StopPriceField, MinQtyField, AllOrNoneField}
+
+// csvHeader is where the order fields are in the records of a CSV input. Records are read one per line, so
+// quoted values cannot span lines.
+type csvHeader struct {
+	index map[string]int
+}
+
+// newCSVHeader reads the header of a CSV input. columns maps order fields to the header of the column
+// holding them, unmapped fields are looked up by their own name. Product, price and qty are required, as is
+// either the id or the side.
+func newCSVHeader(line string, columns map[string]string) (*csvHeader, error) {
+	if err := ValidateColumns(columns); err != nil {
+		return nil, fmt.Errorf("csv: %w", err)
+	}
+
+	names, err := readRecord(line)
+	if err != nil {
+		return nil, fmt.Errorf("csv: header: %w", err)
+	}
+
+	positions := make(map[string]int, len(names))
+	for i, name := range names {
+		positions[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	h := &csvHeader{index: make(map[string]int, len(Fields))}
+	for _, field := range Fields {
+		name := field
+		if mapped, ok := columns[field]; ok {
+			name = mapped
+		}
+		if i, ok := positions[strings.ToLower(name)]; ok {
+			h.index[field] = i
+		}
+	}
+
+	for _, field := range []string{ProductField, PriceField, QtyField} {
+		if _, ok := h.index[field]; !ok {
+			return nil, fmt.Errorf("csv: header has no %s column", field)
+		}
+	}
+	if _, ok := h.index[IdField]; !ok {
+		if _, ok = h.index[SideField]; !ok {
+			return nil, errors.New("csv: header has neither an id nor a side column")
+		}
+	}
+
+	return h, nil
+}
+
+func (h *csvHeader) parse(line string) (OrderCommand, error) {
+	record, err := readRecord(line)
+	if err != nil {
+		return OrderCommand{}, err
+	}
+
+	value := func(field string) string {
+		if i, ok := h.index[field]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	cmd := OrderCommand{
+		Id:          value(IdField),
+		Participant: value(ParticipantField),
+		Product:     value(ProductField),
+		Side:        value(SideField),
+	}
+
+	if v := value(StopPriceField); v != "" {
+		if cmd.StopPrice, err = parseAmount(v); err != nil {
+			return OrderCommand{}, fmt.Errorf("invalid stop price %q", v)
+		}
+	}
+
+	// a stop order without a price becomes a market order
+	if v := value(PriceField); v != "" || cmd.StopPrice == 0 {
+		if cmd.Price, err = parseAmount(v); err != nil {
+			return OrderCommand{}, fmt.Errorf("invalid price %q", v)
+		}
+	}
+
+	qty, err := parseAmount(value(QtyField))
+	if err != nil {
+		return OrderCommand{}, fmt.Errorf("invalid quantity %q", value(QtyField))
+	}
+	cmd.Qty = qty
+
+	if v := value(PeakField); v != "" {
+		if cmd.Peak, err = parseAmount(v); err != nil {
+			return OrderCommand{}, fmt.Errorf("invalid peak %q", v)
+		}
+	}
+
+	if v := value(MinQtyField); v != "" {
+		if cmd.MinQty, err = parseAmount(v); err != nil {
+			return OrderCommand{}, fmt.Errorf("invalid min qty %q", v)
+		}
+	}
+
+	if v := value(AllOrNoneField); v != "" {
+		if cmd.AllOrNone, err = strconv.ParseBool(v); err != nil {
+			return OrderCommand{}, fmt.Errorf("invalid all or none %q", v)
+		}
+	}
+
+	return normalize(cmd)
+}
+
+func readRecord(line string) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	record, err := reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, parseErr.Err
+	}
+	return record, err
+}
+
+// ValidateColumns checks that a column mapping only maps order fields, to non empty headers.
+func ValidateColumns(columns map[string]string) error {
+	for field, header := range columns {
+		if !isField(field) {
+			return fmt.Errorf("unknown order field %q in column mapping", field)
+		}
+		if strings.TrimSpace(header) == "" {
+			return fmt.Errorf("column of %s must not be empty", field)
+		}
+	}
+	return nil
+}
+
+func isField(name string) bool {
+	for _, f := range Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Format string
+
+const (
+	Text  Format = "text"
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+)
+
+var Formats = []Format{Text, CSV, JSONL}
+
+// OrderCommand is an order as read from any of the input formats. Origin is set by callers that record
+// where orders were read from, see Origin. IdempotencyKey is set by clients that may retry a submission.
+// Peak makes an iceberg order that shows only that much of Qty in the book. StopPrice makes a stop order that
+// waits until the last trade price reaches it, then becomes a limit order at Price, or a market order if Price
+// is zero. MinQty is the least quantity of the order that may fill at once, and AllOrNone only fills the
+// whole order at once.
+type OrderCommand struct {
+	Id             string
+	Participant    string
+	Product        string
+	Side           string
+	Price          float64
+	Qty            float64
+	Origin         string
+	IdempotencyKey string
+	Peak           float64
+	StopPrice      float64
+	MinQty         float64
+	AllOrNone      bool
+}
+
+// Line is an order read from the input. Number is the line of the input it was read from, starting at 1, and
+// Err is set instead of Command if the line is not a valid order. Reading resumes from Position once the
+// order was processed.
+type Line struct {
+	Number   int
+	Command  OrderCommand
+	Err      error
+	Position file_ops.Position
+}
+
+// Source is where orders are read from, one line at a time.
+type Source interface {
+	Next(ctx context.Context) (file_ops.Line, error)
+}
+
+// Seeker is a Source that can resume reading at an earlier position.
+type Seeker interface {
+	Source
+	Seek(position file_ops.Position) error
+}
+
+type Parser interface {
+	// Next returns the next order of the input, or io.EOF once it was read entirely. Invalid orders are
+	// returned as a Line with Err set so that the rest of the input can still be read, the error is only set
+	// when the input itself cannot be read.
+	Next(ctx context.Context) (Line, error)
+}
+
+type Option func(o *options)
+
+type options struct {
+	format  Format
+	columns map[string]string
+	resume  file_ops.Position
+}
+
+// WithFormat reads the input as format instead of detecting it.
+func WithFormat(format Format) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithColumns maps order fields to the CSV header naming them, for instance "qty" to "Quantity". Fields that
+// are not mapped are read from the column named after them.
+func WithColumns(columns map[string]string) Option {
+	return func(o *options) {
+		o.columns = columns
+	}
+}
+
+// WithResume skips the input up to a position returned with an earlier Line, by seeking to its offset
+// when the source is a Seeker and the offset is known and otherwise by reading past its line. The header of
+// a CSV input is still read first.
+func WithResume(position file_ops.Position) Option {
+	return func(o *options) {
+		o.resume = position
+	}
+}
+
+type parser struct {
+	source  Source
+	options options
+	parse   func(text string) (OrderCommand, error)
+	pending *file_ops.Line
+}
+
+// NewParser returns a parser of the lines of r.
+func NewParser(r io.Reader, opts ...Option) Parser {
+	return NewSourceParser(file_ops.NewLineReader(r), opts...)
+}
+
+// NewSourceParser returns a parser of the lines of source in the format it was given, or in the one
+// detected from its first line.
+func NewSourceParser(source Source, opts ...Option) Parser {
+	p := &parser{source: source}
+	for _, opt := range opts {
+		opt(&p.options)
+	}
+	return p
+}
+
+func (p *parser) Next(ctx context.Context) (Line, error) {
+	if p.parse == nil {
+		if err := p.start(ctx); err != nil {
+			return Line{}, err
+		}
+	}
+
+	for {
+		var line file_ops.Line
+		if p.pending != nil {
+			line, p.pending = *p.pending, nil
+		} else {
+			var err error
+			if line, err = p.source.Next(ctx); err != nil {
+				return Line{}, err
+			}
+		}
+
+		if strings.TrimSpace(line.Text) == "" || line.Number <= p.options.resume.Line {
+			continue
+		}
+
+		cmd, err := p.parse(line.Text)
+		return Line{Number: line.Number, Command: cmd, Err: err, Position: line.Position()}, nil
+	}
+}
+
+// start reads the first line to detect the format and, for CSV, the header, then skips to the resume
+// position. An input without any line yet is read as empty.
+func (p *parser) start(ctx context.Context) error {
+	format := p.options.format
+
+	var first *file_ops.Line
+	if format == "" || format == CSV {
+		line, err := p.firstLine(ctx)
+		if err != nil {
+			return err
+		}
+		first = &line
+	}
+	if format == "" {
+		format = Detect(first.Text)
+	}
+
+	switch format {
+	case Text:
+		p.parse, p.pending = ParseLine, first
+	case JSONL:
+		p.parse, p.pending = parseJSONLine, first
+	case CSV:
+		header, err := newCSVHeader(first.Text, p.options.columns)
+		if err != nil {
+			return err
+		}
+		p.parse = header.parse
+	default:
+		return fmt.Errorf("unknown input format %q", format)
+	}
+
+	return p.resume()
+}
+
+func (p *parser) firstLine(ctx context.Context) (file_ops.Line, error) {
+	for {
+		line, err := p.source.Next(ctx)
+		if err != nil {
+			return file_ops.Line{}, err
+		}
+		if strings.TrimSpace(line.Text) != "" {
+			return line, nil
+		}
+	}
+}
+
+func (p *parser) resume() error {
+	resume := p.options.resume
+	if p.pending != nil && p.pending.Number <= resume.Line {
+		p.pending = nil
+	}
+	if resume.Offset == 0 || p.pending != nil {
+		return nil
+	}
+
+	seeker, ok := p.source.(Seeker)
+	if !ok {
+		if resume.Line == 0 {
+			return fmt.Errorf("cannot resume at offset %d, the input does not support seeking", resume.Offset)
+		}
+		return nil
+	}
+	return seeker.Seek(resume)
+}
+
+// Detect guesses the format of an input from its first line: JSON lines start with an object, CSV has
+// commas and anything else is taken as text.
+func Detect(line string) Format {
+	line = strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(line, "{"):
+		return JSONL
+	case strings.Contains(line, ","):
+		return CSV
+	default:
+		return Text
+	}
+}
+
+// FormatOf returns the format a file name's extension stands for.
+func FormatOf(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt":
+		return Text, true
+	case ".csv":
+		return CSV, true
+	case ".jsonl", ".ndjson":
+		return JSONL, true
+	default:
+		return "", false
+	}
+}
+
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(s) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown input format %q", s)
+}
+
+// normalize fills in what an order leaves implicit the same way for every format: the side comes from the
+// id when it is not given and the participant defaults to the id.
+func normalize(cmd OrderCommand) (OrderCommand, error) {
+	side, err := parseSide(cmd.Side, cmd.Id)
+	if err != nil {
+		return OrderCommand{}, err
+	}
+	cmd.Side = side
+
+	if cmd.Participant == "" {
+		cmd.Participant = cmd.Id
+	}
+	if cmd.Product == "" {
+		return OrderCommand{}, errors.New("product is required")
+	}
+
+	return cmd, nil
+}
+
+func parseSide(side, id string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(side)) {
+	case "s", "supply":
+		return constants.SupplyOrderType, nil
+	case "d", "demand":
+		return constants.DemandOrderType, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unknown order side %q", side)
+	}
+
+	if id == "" {
+		return "", errors.New("order id or side is required")
+	}
+	switch strings.ToLower(id[:1]) {
+	case "s":
+		return constants.SupplyOrderType, nil
+	case "d":
+		return constants.DemandOrderType, nil
+	default:
+		return "", fmt.Errorf("order id %s must start with s or d", id)
+	}
+}
+
+// parseAmount parses a price such as 24/kg or a quantity such as 100kg, ignoring the unit.
+func parseAmount(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "/"); i >= 0 {
+		s = s[:i]
+	}
+	return strconv.ParseFloat(strings.TrimRightFunc(s, isUnit), 64)
+}
+
+func isUnit(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
@@ -0,0 +1,158 @@
+package ingestion_test
+
+import (
+	"context"
+	"errors"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/ingestion"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, input string, opts ...ingestion.Option) []ingestion.Line {
+	parser := ingestion.NewParser(strings.NewReader(input), opts...)
+
+	var lines []ingestion.Line
+	for {
+		line, err := parser.Next(context.Background())
+		if errors.Is(err, io.EOF) {
+			return lines
+		}
+		require.NoError(t, err)
+		lines = append(lines, line)
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	cmd, err := ingestion.ParseLine("s1 09:45 tomato 24/kg 100kg")
+	require.NoError(t, err)
+	assert.Equal(t, ingestion.OrderCommand{Id: "s1", Participant: "s1", Product: "tomato", Side: "SUPPLY", Price: 24, Qty: 100}, cmd)
+
+	_, err = ingestion.ParseLine("s1 09:45 tomato 24/kg")
+	assert.Error(t, err)
+
+	_, err = ingestion.ParseLine("d1 09:45 tomato abc/kg 10kg")
+	assert.Error(t, err)
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, ingestion.Text, ingestion.Detect("s1 09:45 tomato 24/kg 100kg"))
+	assert.Equal(t, ingestion.CSV, ingestion.Detect("id,time,product,price,qty"))
+	assert.Equal(t, ingestion.JSONL, ingestion.Detect(`  {"id":"s1"}`))
+
+	format, ok := ingestion.FormatOf("orders/2023-03-01.NDJSON")
+	assert.True(t, ok)
+	assert.Equal(t, ingestion.JSONL, format)
+}
+
+func TestParsersProduceTheSameCommands(t *testing.T) {
+	text := "s1 09:45 tomato 24/kg 100kg\n\nd1 09:47 tomato 22/kg 110kg\n"
+	csv := "id,time,product,price,qty\ns1,09:45,tomato,24/kg,100kg\nd1,09:47,tomato,22,110\n"
+	jsonl := `{"id":"s1","time":"09:45","product":"tomato","price":"24/kg","qty":100}` + "\n" +
+		`{"id":"d1","time":"09:47","product":"tomato","price":22,"qty":"110kg"}` + "\n"
+
+	expected := []ingestion.OrderCommand{
+		{Id: "s1", Participant: "s1", Product: "tomato", Side: "SUPPLY", Price: 24, Qty: 100},
+		{Id: "d1", Participant: "d1", Product: "tomato", Side: "DEMAND", Price: 22, Qty: 110},
+	}
+
+	for _, input := range []string{text, csv, jsonl} {
+		lines := readAll(t, input)
+		require.Len(t, lines, 2, input)
+		for i, line := range lines {
+			require.NoError(t, line.Err)
+			assert.Equal(t, expected[i], line.Command)
+		}
+	}
+}
+
+func TestCSVParser_MapsHeaders(t *testing.T) {
+	input := "OrderRef,Grower,Item,Direction,UnitPrice,Quantity\nA-1,grower-1,tomato,Supply,24,100\nA-2,buyer-1,tomato,d,22,10\n"
+
+	lines := readAll(t, input, ingestion.WithColumns(map[string]string{
+		ingestion.IdField:          "OrderRef",
+		ingestion.ParticipantField: "Grower",
+		ingestion.ProductField:     "Item",
+		ingestion.SideField:        "Direction",
+		ingestion.PriceField:       "UnitPrice",
+		ingestion.QtyField:         "Quantity",
+	}))
+
+	require.Len(t, lines, 2)
+	assert.Equal(t, ingestion.OrderCommand{Id: "A-1", Participant: "grower-1", Product: "tomato", Side: "SUPPLY", Price: 24, Qty: 100}, lines[0].Command)
+	assert.Equal(t, "DEMAND", lines[1].Command.Side)
+	assert.Equal(t, 3, lines[1].Number)
+
+	parser := ingestion.NewParser(strings.NewReader("id,product,qty\n"), ingestion.WithFormat(ingestion.CSV))
+	_, err := parser.Next(context.Background())
+	assert.EqualError(t, err, "csv: header has no price column")
+}
+
+func TestParsersReportInvalidLinesAndCarryOn(t *testing.T) {
+	for _, scenario := range []struct {
+		input   string
+		invalid []int
+	}{
+		{"s1 09:45 tomato 24/kg 100kg\nx1 09:46 tomato 20/kg 90kg\nd1 09:47 tomato 22/kg\nd2 09:48 tomato 21/kg 10kg\n", []int{2, 3}},
+		{"id,product,price,qty\ns1,tomato,24,100\ns2,tomato,abc,90\nd1,tom\"ato,22,110\nd2,tomato,21,10\n", []int{3, 4}},
+		{"{\"id\":\"s1\",\"product\":\"tomato\",\"price\":24,\"qty\":100}\n{\"id\":\"s2\",\n{\"id\":\"d1\",\"price\":22,\"qty\":110}\n{\"id\":\"d2\",\"product\":\"tomato\",\"price\":21,\"qty\":10}\n", []int{2, 3}},
+	} {
+		lines := readAll(t, scenario.input)
+
+		var invalid []int
+		for _, line := range lines {
+			if line.Err != nil {
+				invalid = append(invalid, line.Number)
+			}
+		}
+		assert.Equal(t, scenario.invalid, invalid, scenario.input)
+		assert.Equal(t, "d2", lines[len(lines)-1].Command.Id, scenario.input)
+	}
+}
+
+func TestParsersResumeAfterAPosition(t *testing.T) {
+	dir := t.TempDir()
+	for _, scenario := range []struct{ name, input string }{
+		{"orders.txt", "s1 09:45 tomato 24/kg 100kg\r\n\ns2 09:46 tomato 20/kg 90kg\r\nd1 09:47 tomato 22/kg 110kg\r\n"},
+		{"orders.csv", "id,product,price,qty\ns1,tomato,24,100\n\ns2,tomato,20,90\nd1,tomato,22,110\n"},
+	} {
+		path := filepath.Join(dir, scenario.name)
+		require.NoError(t, os.WriteFile(path, []byte(scenario.input), 0o600))
+
+		all := readAll(t, scenario.input)
+		require.Len(t, all, 3)
+
+		for _, resume := range []file_ops.Position{all[1].Position, {Line: all[1].Number}} {
+			source, err := file_ops.Open(path)
+			require.NoError(t, err)
+
+			parser := ingestion.NewSourceParser(source, ingestion.WithResume(resume))
+			line, err := parser.Next(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "d1", line.Command.Id, scenario.name)
+			assert.Equal(t, all[2].Number, line.Number, scenario.name)
+			assert.Equal(t, all[2].Position, line.Position, scenario.name)
+
+			_, err = parser.Next(context.Background())
+			assert.ErrorIs(t, err, io.EOF)
+			require.NoError(t, source.Close())
+		}
+	}
+}
+
+func TestParsersStopWhenTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	parser := ingestion.NewParser(strings.NewReader("s1 09:45 tomato 24/kg 100kg\ns2 09:46 tomato 20/kg 90kg\n"))
+
+	_, err := parser.Next(ctx)
+	require.NoError(t, err)
+
+	cancel()
+	_, err = parser.Next(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
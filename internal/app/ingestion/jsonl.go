@@ -0,0 +1,77 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type orderLine struct {
+	Id          string          `json:"id"`
+	Participant string          `json:"participant"`
+	Product     string          `json:"product"`
+	Side        string          `json:"side"`
+	Price       json.RawMessage `json:"price"`
+	Qty         json.RawMessage `json:"qty"`
+	Peak        json.RawMessage `json:"peak"`
+	StopPrice   json.RawMessage `json:"stop_price"`
+	MinQty      json.RawMessage `json:"min_qty"`
+	AllOrNone   bool            `json:"all_or_none"`
+}
+
+// parseJSONLine parses an order such as {"id":"s1","product":"tomato","price":"24/kg","qty":100}. Price,
+// qty, the peak of an iceberg order, the stop price of a stop order and the minimum fill are numbers, or strings
+// with a unit. A stop order without a price becomes a market order.
+func parseJSONLine(line string) (OrderCommand, error) {
+	var ol orderLine
+	if err := json.Unmarshal([]byte(line), &ol); err != nil {
+		return OrderCommand{}, fmt.Errorf("invalid json: %w", err)
+	}
+
+	cmd := OrderCommand{Id: ol.Id, Participant: ol.Participant, Product: ol.Product, Side: ol.Side, AllOrNone: ol.AllOrNone}
+
+	var err error
+	if len(ol.StopPrice) > 0 {
+		if cmd.StopPrice, err = jsonAmount(ol.StopPrice); err != nil {
+			return OrderCommand{}, fmt.Errorf("invalid stop price %s", string(ol.StopPrice))
+		}
+	}
+
+	if len(ol.Price) > 0 || cmd.StopPrice == 0 {
+		if cmd.Price, err = jsonAmount(ol.Price); err != nil {
+			return OrderCommand{}, fmt.Errorf("invalid price %s", string(ol.Price))
+		}
+	}
+
+	qty, err := jsonAmount(ol.Qty)
+	if err != nil {
+		return OrderCommand{}, fmt.Errorf("invalid quantity %s", string(ol.Qty))
+	}
+	cmd.Qty = qty
+
+	if len(ol.Peak) > 0 {
+		if cmd.Peak, err = jsonAmount(ol.Peak); err != nil {
+			return OrderCommand{}, fmt.Errorf("invalid peak %s", string(ol.Peak))
+		}
+	}
+
+	if len(ol.MinQty) > 0 {
+		if cmd.MinQty, err = jsonAmount(ol.MinQty); err != nil {
+			return OrderCommand{}, fmt.Errorf("invalid min qty %s", string(ol.MinQty))
+		}
+	}
+
+	return normalize(cmd)
+}
+
+func jsonAmount(raw json.RawMessage) (float64, error) {
+	var n float64
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, err
+	}
+	return parseAmount(s)
+}
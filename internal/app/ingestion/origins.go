@@ -0,0 +1,87 @@
+package ingestion
+
+import (
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const OriginsName = "ingested_origins"
+
+// Origin names the line of a source an order was read from, to be recorded with event_sourcing.WithOrigin.
+func Origin(source string, line int) string {
+	return fmt.Sprintf("%s:%d", source, line)
+}
+
+// Origins tracks the last line of every source that placed or had rejected an order. As origins are recorded
+// in the same events as the orders, a source that is read again after a crash can skip the lines that already
+// took effect, whatever its own checkpoint says.
+type Origins struct {
+	mtx   sync.RWMutex
+	lines map[string]int
+}
+
+// originEvent is an event_sourcing.OrderEvent or event_sourcing.RejectEvent.
+type originEvent interface {
+	Origin() string
+}
+
+func NewOrigins() *Origins {
+	return &Origins{lines: make(map[string]int)}
+}
+
+func (o *Origins) Name() string {
+	return OriginsName
+}
+
+func (o *Origins) Handlers() map[string]projection.Handler {
+	return map[string]projection.Handler{
+		constants.SupplyEventType: o.add,
+		constants.DemandEventType: o.add,
+		constants.StopEventType:   o.add,
+		constants.RejectEventType: o.add,
+	}
+}
+
+func (o *Origins) Reset() {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.lines = make(map[string]int)
+}
+
+// Line returns the last line of source that placed or had rejected an order, 0 if none did.
+func (o *Origins) Line(source string) int {
+	o.mtx.RLock()
+	defer o.mtx.RUnlock()
+
+	return o.lines[source]
+}
+
+func (o *Origins) add(record event_sourcing.Record) {
+	ev, ok := record.Event.(originEvent)
+	if !ok || ev.Origin() == "" {
+		return
+	}
+
+	i := strings.LastIndex(ev.Origin(), ":")
+	if i < 0 {
+		return
+	}
+	line, err := strconv.Atoi(ev.Origin()[i+1:])
+	if err != nil {
+		return
+	}
+	source := ev.Origin()[:i]
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if line > o.lines[source] {
+		o.lines[source] = line
+	}
+}
@@ -0,0 +1,31 @@
+package ingestion
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseLine parses an order line such as "s1 09:45 tomato 24/kg 100kg". Ids starting with s are supplies, d demands.
+// The time of day is not read, orders are sequenced as they arrive.
+func ParseLine(line string) (OrderCommand, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 {
+		return OrderCommand{}, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	cmd := OrderCommand{Id: fields[0], Product: fields[2]}
+
+	price, err := parseAmount(fields[3])
+	if err != nil {
+		return OrderCommand{}, fmt.Errorf("invalid price %s", fields[3])
+	}
+	cmd.Price = price
+
+	qty, err := parseAmount(fields[4])
+	if err != nil {
+		return OrderCommand{}, fmt.Errorf("invalid quantity %s", fields[4])
+	}
+	cmd.Qty = qty
+
+	return normalize(cmd)
+}
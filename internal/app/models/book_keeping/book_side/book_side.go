@@ -9,4 +9,5 @@ type BookSide interface {
 	UpdateOrders(side []*order.Order) error
 	GetOrders() []*order.Order
 	SetComparator(comparator comparator.Comparator)
+	Clone() BookSide
 }
@@ -33,6 +33,20 @@ func (a *orderBookSide) SetComparator(comparator comparator2.Comparator) {
 	a.comparator = comparator
 }
 
+// Clone returns a copy of the side that shares no orders with it.
+func (a *orderBookSide) Clone() BookSide {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+
+	orders := make([]*order.Order, 0, len(a.orders))
+	for _, o := range a.orders {
+		copied := *o
+		orders = append(orders, &copied)
+	}
+
+	return &orderBookSide{orders: orders, comparator: a.comparator}
+}
+
 func (a *orderBookSide) UpdateOrders(newOrders []*order.Order) error {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
@@ -0,0 +1,127 @@
+package circuit_breaker
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// Config describes the volatility guard of a product. An execution that would move the price by more
+// than MaxMovePercent from the reference price observed within Window halts the product for CoolOff.
+type Config struct {
+	MaxMovePercent decimal.Decimal
+	Window         time.Duration
+	CoolOff        time.Duration
+}
+
+// Trip describes the execution that breached the guard.
+type Trip struct {
+	ReferencePrice decimal.Decimal
+	Price          decimal.Decimal
+	Timestamp      int64
+}
+
+type execution struct {
+	price     decimal.Decimal
+	timestamp int64
+}
+
+type CircuitBreaker struct {
+	config     Config
+	executions []execution
+	lastPrice  *decimal.Decimal
+	pending    *Trip
+	halted     bool
+	haltedAt   int64
+}
+
+func ProvideCircuitBreaker(config Config) *CircuitBreaker {
+	return &CircuitBreaker{config: config, executions: make([]execution, 0)}
+}
+
+func (cb *CircuitBreaker) Clone() *CircuitBreaker {
+	clone := *cb
+	clone.executions = append(make([]execution, 0, len(cb.executions)), cb.executions...)
+	if cb.pending != nil {
+		pending := *cb.pending
+		clone.pending = &pending
+	}
+	return &clone
+}
+
+// Allow reports whether an execution at price and timestamp stays within the configured band.
+// A rejected execution is remembered as a pending trip until Halt is called.
+func (cb *CircuitBreaker) Allow(price decimal.Decimal, timestamp int64) bool {
+	cb.prune(timestamp)
+
+	reference, ok := cb.reference()
+	if !ok || reference.IsZero() {
+		return true
+	}
+
+	move := price.Sub(reference).Abs().Div(reference).Mul(decimal.NewFromInt(100))
+	if move.LessThanOrEqual(cb.config.MaxMovePercent) {
+		return true
+	}
+
+	cb.pending = &Trip{ReferencePrice: reference, Price: price, Timestamp: timestamp}
+	return false
+}
+
+// Record registers an execution that went through.
+func (cb *CircuitBreaker) Record(price decimal.Decimal, timestamp int64) {
+	cb.executions = append(cb.executions, execution{price: price, timestamp: timestamp})
+	cb.lastPrice = &price
+}
+
+// Tripped returns the pending trip raised by the latest rejected execution, if any.
+func (cb *CircuitBreaker) Tripped() (Trip, bool) {
+	if cb.pending == nil {
+		return Trip{}, false
+	}
+	return *cb.pending, true
+}
+
+func (cb *CircuitBreaker) Halt(timestamp int64) {
+	cb.pending = nil
+	cb.halted = true
+	cb.haltedAt = timestamp
+}
+
+// Resume lifts the halt and forgets the executions seen so far, so the next execution sets a new reference.
+func (cb *CircuitBreaker) Resume() {
+	cb.pending = nil
+	cb.halted = false
+	cb.haltedAt = 0
+	cb.executions = make([]execution, 0)
+	cb.lastPrice = nil
+}
+
+func (cb *CircuitBreaker) IsHalted() bool {
+	return cb.halted
+}
+
+func (cb *CircuitBreaker) CoolOffElapsed(timestamp int64) bool {
+	return cb.halted && timestamp-cb.haltedAt >= cb.config.CoolOff.Nanoseconds()
+}
+
+func (cb *CircuitBreaker) reference() (decimal.Decimal, bool) {
+	if len(cb.executions) > 0 {
+		return cb.executions[0].price, true
+	}
+
+	if cb.lastPrice != nil {
+		return *cb.lastPrice, true
+	}
+
+	return decimal.Decimal{}, false
+}
+
+func (cb *CircuitBreaker) prune(timestamp int64) {
+	from := timestamp - cb.config.Window.Nanoseconds()
+
+	i := 0
+	for i < len(cb.executions) && cb.executions[i].timestamp < from {
+		i++
+	}
+	cb.executions = cb.executions[i:]
+}
@@ -0,0 +1,84 @@
+package circuit_breaker_test
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/circuit_breaker"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type circuitBreakerSuite struct {
+	suite.Suite
+	breaker *circuit_breaker.CircuitBreaker
+	timeNow time.Time
+}
+
+func TestCircuitBreakerSuite(t *testing.T) {
+	suite.Run(t, new(circuitBreakerSuite))
+}
+
+func (suite *circuitBreakerSuite) SetupTest() {
+	suite.breaker = circuit_breaker.ProvideCircuitBreaker(circuit_breaker.Config{
+		MaxMovePercent: decimal.NewFromFloat(10),
+		Window:         time.Minute,
+		CoolOff:        5 * time.Minute,
+	})
+	suite.timeNow = time.Now()
+}
+
+func (suite *circuitBreakerSuite) TestAllowsFirstExecution() {
+	suite.Require().True(suite.breaker.Allow(decimal.NewFromFloat(20), suite.timeNow.UnixNano()))
+
+	_, tripped := suite.breaker.Tripped()
+	suite.Require().False(tripped)
+}
+
+func (suite *circuitBreakerSuite) TestAllowsMoveWithinBand() {
+	suite.breaker.Record(decimal.NewFromFloat(20), suite.timeNow.UnixNano())
+
+	suite.Require().True(suite.breaker.Allow(decimal.NewFromFloat(22), suite.timeNow.Add(time.Second).UnixNano()))
+	suite.Require().True(suite.breaker.Allow(decimal.NewFromFloat(18), suite.timeNow.Add(time.Second).UnixNano()))
+}
+
+func (suite *circuitBreakerSuite) TestTripsOnMoveBeyondBand() {
+	suite.breaker.Record(decimal.NewFromFloat(20), suite.timeNow.UnixNano())
+	suite.breaker.Record(decimal.NewFromFloat(21), suite.timeNow.Add(time.Second).UnixNano())
+
+	ts := suite.timeNow.Add(2 * time.Second).UnixNano()
+	suite.Require().False(suite.breaker.Allow(decimal.NewFromFloat(23), ts))
+
+	trip, tripped := suite.breaker.Tripped()
+	suite.Require().True(tripped)
+	suite.Assert().True(decimal.NewFromFloat(20).Equal(trip.ReferencePrice))
+	suite.Assert().True(decimal.NewFromFloat(23).Equal(trip.Price))
+	suite.Assert().Equal(ts, trip.Timestamp)
+}
+
+func (suite *circuitBreakerSuite) TestReferenceSlidesWithWindow() {
+	suite.breaker.Record(decimal.NewFromFloat(20), suite.timeNow.UnixNano())
+	suite.breaker.Record(decimal.NewFromFloat(21.5), suite.timeNow.Add(50*time.Second).UnixNano())
+
+	suite.Require().True(suite.breaker.Allow(decimal.NewFromFloat(23), suite.timeNow.Add(90*time.Second).UnixNano()))
+}
+
+func (suite *circuitBreakerSuite) TestHaltAndCoolOff() {
+	haltedAt := suite.timeNow.UnixNano()
+	suite.breaker.Halt(haltedAt)
+
+	suite.Require().True(suite.breaker.IsHalted())
+	suite.Assert().False(suite.breaker.CoolOffElapsed(suite.timeNow.Add(time.Minute).UnixNano()))
+	suite.Assert().True(suite.breaker.CoolOffElapsed(suite.timeNow.Add(5 * time.Minute).UnixNano()))
+
+	suite.breaker.Resume()
+	suite.Require().False(suite.breaker.IsHalted())
+	suite.Assert().False(suite.breaker.CoolOffElapsed(suite.timeNow.Add(5 * time.Minute).UnixNano()))
+}
+
+func (suite *circuitBreakerSuite) TestResumeResetsReference() {
+	suite.breaker.Record(decimal.NewFromFloat(20), suite.timeNow.UnixNano())
+	suite.breaker.Halt(suite.timeNow.UnixNano())
+	suite.breaker.Resume()
+
+	suite.Require().True(suite.breaker.Allow(decimal.NewFromFloat(30), suite.timeNow.Add(time.Second).UnixNano()))
+}
@@ -1,7 +1,29 @@
 package current_state
 
-import "github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order_book"
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/circuit_breaker"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order_book"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/stop_book"
+	"github.com/shopspring/decimal"
+)
 
+// CurrentState is what the events of a product add up to. Stops holds the stop orders waiting for LastPrice,
+// the price of the last trade, to reach their stop price.
 type CurrentState struct {
-	OrderBook order_book.OrderBook
+	OrderBook      order_book.OrderBook
+	CircuitBreaker *circuit_breaker.CircuitBreaker
+	Stops          *stop_book.StopBook
+	LastPrice      decimal.Decimal
+}
+
+// Clone returns a deep copy of the state, so events applied to the copy leave the original untouched.
+func (cs *CurrentState) Clone() *CurrentState {
+	clone := &CurrentState{OrderBook: cs.OrderBook.Clone(), LastPrice: cs.LastPrice}
+	if cs.CircuitBreaker != nil {
+		clone.CircuitBreaker = cs.CircuitBreaker.Clone()
+	}
+	if cs.Stops != nil {
+		clone.Stops = cs.Stops.Clone()
+	}
+	return clone
 }
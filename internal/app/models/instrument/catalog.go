@@ -0,0 +1,41 @@
+package instrument
+
+import (
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"gopkg.in/yaml.v3"
+)
+
+type catalogFile struct {
+	Products []struct {
+		Name       string `yaml:"name"`
+		Instrument Spec   `yaml:"instrument"`
+	} `yaml:"products"`
+}
+
+func LoadCatalog(filepath string) (map[string]Spec, error) {
+	data, err := file_ops.Read(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	var file catalogFile
+	if err = yaml.Unmarshal([]byte(data), &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath, err)
+	}
+
+	catalog := make(map[string]Spec, len(file.Products))
+	for _, p := range file.Products {
+		switch p.Instrument.Rounding {
+		case "":
+			p.Instrument.Rounding = RejectPolicy
+		case RejectPolicy, RoundPolicy:
+		default:
+			return nil, fmt.Errorf("product %s: unknown rounding policy %q", p.Name, p.Instrument.Rounding)
+		}
+
+		catalog[p.Name] = p.Instrument
+	}
+
+	return catalog, nil
+}
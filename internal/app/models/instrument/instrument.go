@@ -0,0 +1,121 @@
+package instrument
+
+import (
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/shopspring/decimal"
+)
+
+type RoundingPolicy string
+
+const (
+	RejectPolicy RoundingPolicy = "reject"
+	RoundPolicy  RoundingPolicy = "round"
+)
+
+// Spec describes what a deliverable order for a product looks like. Zero values disable the related check.
+type Spec struct {
+	TickSize       decimal.Decimal `yaml:"tick_size"`
+	LotSize        decimal.Decimal `yaml:"lot_size"`
+	MinQty         decimal.Decimal `yaml:"min_qty"`
+	PricePrecision *int32          `yaml:"price_precision"`
+	QtyPrecision   *int32          `yaml:"qty_precision"`
+	Rounding       RoundingPolicy  `yaml:"rounding"`
+}
+
+type ViolationError struct {
+	Field string
+	Value decimal.Decimal
+	Rule  string
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("%s %v violates %s", e.Field, e.Value, e.Rule)
+}
+
+// Normalize validates price and quantity of an order against the spec. With the round policy, prices are
+// moved to the tick that is less favourable for the order placer and quantities are rounded down to the lot.
+// Lot returns the smallest quantity the product trades in, the lot size or else the last decimal its quantities
+// may have, and false if its quantities are not restricted.
+func (s Spec) Lot() (decimal.Decimal, bool) {
+	switch {
+	case s.LotSize.IsPositive():
+		return s.LotSize, true
+	case s.QtyPrecision != nil:
+		return decimal.New(1, -*s.QtyPrecision), true
+	default:
+		return decimal.Zero, false
+	}
+}
+
+func (s Spec) Normalize(orderType string, price, qty decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	if !price.IsPositive() {
+		return price, qty, &ViolationError{Field: "price", Value: price, Rule: "positive value"}
+	}
+
+	if !qty.IsPositive() {
+		return price, qty, &ViolationError{Field: "quantity", Value: qty, Rule: "positive value"}
+	}
+
+	normalizedPrice, err := s.normalizePrice(orderType, price)
+	if err != nil {
+		return price, qty, err
+	}
+
+	normalizedQty, err := s.normalizeQty(qty)
+	if err != nil {
+		return price, qty, err
+	}
+
+	if !normalizedQty.IsPositive() || normalizedQty.LessThan(s.MinQty) {
+		return price, qty, &ViolationError{Field: "quantity", Value: qty, Rule: fmt.Sprintf("minimum quantity %v", s.MinQty)}
+	}
+
+	return normalizedPrice, normalizedQty, nil
+}
+
+func (s Spec) normalizePrice(orderType string, price decimal.Decimal) (decimal.Decimal, error) {
+	roundUp := orderType == constants.SupplyOrderType
+
+	if s.PricePrecision != nil && !price.Equal(price.Truncate(*s.PricePrecision)) {
+		if s.Rounding != RoundPolicy {
+			return price, &ViolationError{Field: "price", Value: price, Rule: fmt.Sprintf("precision of %d decimals", *s.PricePrecision)}
+		}
+		price = roundTo(price, decimal.New(1, -*s.PricePrecision), roundUp)
+	}
+
+	if s.TickSize.IsPositive() && !price.Mod(s.TickSize).IsZero() {
+		if s.Rounding != RoundPolicy {
+			return price, &ViolationError{Field: "price", Value: price, Rule: fmt.Sprintf("tick size %v", s.TickSize)}
+		}
+		price = roundTo(price, s.TickSize, roundUp)
+	}
+
+	return price, nil
+}
+
+func (s Spec) normalizeQty(qty decimal.Decimal) (decimal.Decimal, error) {
+	if s.QtyPrecision != nil && !qty.Equal(qty.Truncate(*s.QtyPrecision)) {
+		if s.Rounding != RoundPolicy {
+			return qty, &ViolationError{Field: "quantity", Value: qty, Rule: fmt.Sprintf("precision of %d decimals", *s.QtyPrecision)}
+		}
+		qty = roundTo(qty, decimal.New(1, -*s.QtyPrecision), false)
+	}
+
+	if s.LotSize.IsPositive() && !qty.Mod(s.LotSize).IsZero() {
+		if s.Rounding != RoundPolicy {
+			return qty, &ViolationError{Field: "quantity", Value: qty, Rule: fmt.Sprintf("lot size %v", s.LotSize)}
+		}
+		qty = roundTo(qty, s.LotSize, false)
+	}
+
+	return qty, nil
+}
+
+func roundTo(value, step decimal.Decimal, up bool) decimal.Decimal {
+	steps := value.Div(step)
+	if up {
+		return steps.Ceil().Mul(step)
+	}
+	return steps.Floor().Mul(step)
+}
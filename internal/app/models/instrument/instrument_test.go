@@ -0,0 +1,128 @@
+package instrument_test
+
+import (
+	"errors"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/instrument"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type instrumentSuite struct {
+	suite.Suite
+	spec instrument.Spec
+}
+
+func TestInstrumentSuite(t *testing.T) {
+	suite.Run(t, new(instrumentSuite))
+}
+
+func (suite *instrumentSuite) SetupTest() {
+	pricePrecision, qtyPrecision := int32(2), int32(1)
+	suite.spec = instrument.Spec{
+		TickSize:       decimal.NewFromFloat(0.05),
+		LotSize:        decimal.NewFromFloat(0.5),
+		MinQty:         decimal.NewFromFloat(1),
+		PricePrecision: &pricePrecision,
+		QtyPrecision:   &qtyPrecision,
+		Rounding:       instrument.RejectPolicy,
+	}
+}
+
+func (suite *instrumentSuite) TestAcceptsValidOrder() {
+	price, qty, err := suite.spec.Normalize(constants.SupplyOrderType, decimal.NewFromFloat(20.05), decimal.NewFromFloat(1.5))
+	suite.Require().NoError(err)
+	suite.Assert().True(decimal.NewFromFloat(20.05).Equal(price))
+	suite.Assert().True(decimal.NewFromFloat(1.5).Equal(qty))
+}
+
+func (suite *instrumentSuite) TestRejectsPriceOffTick() {
+	_, _, err := suite.spec.Normalize(constants.SupplyOrderType, decimal.NewFromFloat(20.01), decimal.NewFromFloat(10))
+
+	var violation *instrument.ViolationError
+	suite.Require().True(errors.As(err, &violation))
+	suite.Assert().Equal("price", violation.Field)
+}
+
+func (suite *instrumentSuite) TestRejectsPriceBeyondPrecision() {
+	_, _, err := suite.spec.Normalize(constants.DemandOrderType, decimal.NewFromFloat(20.0001), decimal.NewFromFloat(10))
+
+	var violation *instrument.ViolationError
+	suite.Require().True(errors.As(err, &violation))
+	suite.Assert().Equal("price", violation.Field)
+}
+
+func (suite *instrumentSuite) TestRejectsQuantityOffLot() {
+	_, _, err := suite.spec.Normalize(constants.DemandOrderType, decimal.NewFromFloat(20), decimal.NewFromFloat(1.2))
+
+	var violation *instrument.ViolationError
+	suite.Require().True(errors.As(err, &violation))
+	suite.Assert().Equal("quantity", violation.Field)
+}
+
+func (suite *instrumentSuite) TestRejectsQuantityBelowMinimum() {
+	suite.spec.Rounding = instrument.RoundPolicy
+	_, _, err := suite.spec.Normalize(constants.DemandOrderType, decimal.NewFromFloat(20), decimal.NewFromFloat(0.0003))
+
+	var violation *instrument.ViolationError
+	suite.Require().True(errors.As(err, &violation))
+	suite.Assert().Equal("quantity", violation.Field)
+}
+
+func (suite *instrumentSuite) TestRoundsAgainstThePlacer() {
+	suite.spec.Rounding = instrument.RoundPolicy
+
+	price, qty, err := suite.spec.Normalize(constants.SupplyOrderType, decimal.NewFromFloat(20.0001), decimal.NewFromFloat(1.74))
+	suite.Require().NoError(err)
+	suite.Assert().True(decimal.NewFromFloat(20.05).Equal(price))
+	suite.Assert().True(decimal.NewFromFloat(1.5).Equal(qty))
+
+	price, qty, err = suite.spec.Normalize(constants.DemandOrderType, decimal.NewFromFloat(20.0001), decimal.NewFromFloat(1.74))
+	suite.Require().NoError(err)
+	suite.Assert().True(decimal.NewFromFloat(20).Equal(price))
+	suite.Assert().True(decimal.NewFromFloat(1.5).Equal(qty))
+}
+
+func (suite *instrumentSuite) TestLoadCatalog() {
+	path := filepath.Join(suite.T().TempDir(), "catalog.yaml")
+	content := `
+products:
+  - name: tomato
+    instrument:
+      tick_size: 0.5
+      lot_size: 10
+      min_qty: 20
+      rounding: round
+  - name: potato
+    instrument:
+      tick_size: 1
+`
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0o600))
+
+	catalog, err := instrument.LoadCatalog(path)
+	suite.Require().NoError(err)
+	suite.Require().Len(catalog, 2)
+	suite.Assert().True(decimal.NewFromFloat(0.5).Equal(catalog["tomato"].TickSize))
+	suite.Assert().True(decimal.NewFromFloat(10).Equal(catalog["tomato"].LotSize))
+	suite.Assert().Equal(instrument.RoundPolicy, catalog["tomato"].Rounding)
+	suite.Assert().Equal(instrument.RejectPolicy, catalog["potato"].Rounding)
+	suite.Assert().Nil(catalog["potato"].PricePrecision)
+}
+
+func (suite *instrumentSuite) TestLoadDefaultCatalog() {
+	catalog, err := instrument.LoadCatalog("../../../../configs/default.yaml")
+	suite.Require().NoError(err)
+	suite.Assert().Contains(catalog, "tomato")
+	suite.Assert().Contains(catalog, "potato")
+}
+
+func (suite *instrumentSuite) TestLoadCatalogRejectsUnknownPolicy() {
+	path := filepath.Join(suite.T().TempDir(), "catalog.yaml")
+	suite.Require().NoError(os.WriteFile(path, []byte("products:\n  - name: tomato\n    instrument:\n      rounding: sometimes\n"), 0o600))
+
+	_, err := instrument.LoadCatalog(path)
+	suite.Require().Error(err)
+}
@@ -5,9 +5,22 @@ import (
 )
 
 type Order struct {
-	Id        string
-	Price     shopspring.Decimal
-	Qty       shopspring.Decimal
-	OrderType string
-	Timestamp int64
+	Id          string
+	Participant string
+	Price       shopspring.Decimal
+	Qty         shopspring.Decimal
+	OrderType   string
+	Timestamp   int64
+	// Peak is the slice an iceberg order shows in the book, zero for an order shown in full. Hidden is the
+	// reserve of an iceberg order that is not shown yet.
+	Peak   shopspring.Decimal
+	Hidden shopspring.Decimal
+	// StopPrice holds a stop order back until the last trade price reaches it, zero for an order placed in the
+	// book right away. A stop order with a zero Price becomes a market order once triggered.
+	StopPrice shopspring.Decimal
+	// MinQty is the least quantity of the order that may fill at once, unless less of it is left: across as
+	// many orders as it takes when it arrives, in a single fill once it rests. An AllOrNone order fills its
+	// whole quantity at once.
+	MinQty    shopspring.Decimal
+	AllOrNone bool
 }
@@ -9,6 +9,7 @@ import (
 type OrderBook interface {
 	Get() (demands []*order.Order, supplies []*order.Order)
 	Update(demands []*order.Order, supplies []*order.Order) error
+	Clone() OrderBook
 }
 
 type orderBook struct {
@@ -32,6 +33,10 @@ func (m *orderBook) Get() (demands []*order.Order, supplies []*order.Order) {
 	return
 }
 
+func (m *orderBook) Clone() OrderBook {
+	return &orderBook{demands: m.demands.Clone(), supplies: m.supplies.Clone()}
+}
+
 func (m *orderBook) Update(incomingDemands []*order.Order, incomingSupplies []*order.Order) error {
 	err := m.demands.UpdateOrders(incomingDemands)
 	if err != nil {
@@ -1,12 +1,25 @@
 package product
 
 import (
+	"errors"
+	"fmt"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fees"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/book_keeping/comparator"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/circuit_breaker"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/current_state"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/instrument"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order_book"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/stop_book"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/settlement"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/telemetry"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"github.com/shopspring/decimal"
+	"log/slog"
+	"strings"
+	"time"
 )
 
 type Product struct {
@@ -14,41 +27,407 @@ type Product struct {
 	name         string
 	events       []event_sourcing.Event
 	currentState *current_state.CurrentState
+	instrument   *instrument.Spec
+	balances     *settlement.Balances
+	fees         *fees.Engine
+	aggressor    string
+	placements   map[placementKey]*placement
+	placing      *placement
+	replacing    string
+	logger       *slog.Logger
+	metrics      *telemetry.Metrics
 }
 
-func NewProduct(id string, name string) *Product {
-	return &Product{
+// DuplicateOrderError is returned for an order whose participant already placed an order with the same
+// idempotency key. Demands and Supplies are what the original order matched when it was placed.
+type DuplicateOrderError struct {
+	Key      string
+	OrderId  string
+	Demands  []*order.Order
+	Supplies []*order.Order
+}
+
+func (e *DuplicateOrderError) Error() string {
+	return fmt.Sprintf("idempotency key %q already placed order %s", e.Key, e.OrderId)
+}
+
+type placementKey struct {
+	participant string
+	key         string
+}
+
+// placement is an order placed with an idempotency key and the matches it made when it was placed.
+type placement struct {
+	order    order.Order
+	demands  []*order.Order
+	supplies []*order.Order
+}
+
+type Option func(p *Product)
+
+func WithCircuitBreaker(config circuit_breaker.Config) Option {
+	return func(p *Product) {
+		p.currentState.CircuitBreaker = circuit_breaker.ProvideCircuitBreaker(config)
+	}
+}
+
+func WithInstrumentSpec(spec instrument.Spec) Option {
+	return func(p *Product) {
+		p.instrument = &spec
+	}
+}
+
+// WithBalances rejects orders whose participant lacks the available inventory or cash to back them.
+func WithBalances(balances *settlement.Balances) Option {
+	return func(p *Product) {
+		p.balances = balances
+	}
+}
+
+// WithFees charges every trade of the product through the fee engine.
+func WithFees(engine *fees.Engine) Option {
+	return func(p *Product) {
+		p.fees = engine
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Product) {
+		p.logger = logger
+	}
+}
+
+func WithMetrics(metrics *telemetry.Metrics) Option {
+	return func(p *Product) {
+		p.metrics = metrics
+	}
+}
+
+func NewProduct(id string, name string, opts ...Option) *Product {
+	p := &Product{
 		Id:           id,
 		name:         name,
-		currentState: &current_state.CurrentState{OrderBook: order_book.ProvideOrderBook(comparator.ProvideDemandComparator(), comparator.ProvideSupplyComparator())},
+		logger:       logging.Discard(),
+		metrics:      telemetry.Discard(),
+		placements:   make(map[placementKey]*placement),
+		currentState: &current_state.CurrentState{OrderBook: order_book.ProvideOrderBook(comparator.ProvideDemandComparator(), comparator.ProvideSupplyComparator()), Stops: stop_book.ProvideStopBook()},
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
-func (p *Product) SupplyProduct(price, quantity float64) (error, []*order.Order, []*order.Order) {
-	ev := event_sourcing.NewProductSupplyEvent(p.name, price, quantity)
+func (p *Product) SupplyProduct(price, quantity float64, opts ...event_sourcing.OrderOption) (error, []*order.Order, []*order.Order) {
+	price, quantity, err := p.normalize(constants.SupplyOrderType, price, quantity)
+	if err != nil {
+		p.logger.Warn("order rejected", slog.String("product", p.name), slog.String("side", constants.SupplyOrderType), slog.Float64("price", price), slog.Float64("qty", quantity), slog.String("error", err.Error()))
+		p.metrics.Rejections.Inc(p.name, rejectionReason(err))
+		p.reject(constants.SupplyOrderType, price, quantity, err, opts...)
+		return err, nil, nil
+	}
 
-	err, matchDemand, matchSupply := p.AddEvent(ev)
+	ev := event_sourcing.NewProductSupplyEvent(p.name, price, quantity, opts...)
+	return p.placeOrder(ev)
+}
+
+func (p *Product) DemandProduct(price, quantity float64, opts ...event_sourcing.OrderOption) (error, []*order.Order, []*order.Order) {
+	price, quantity, err := p.normalize(constants.DemandOrderType, price, quantity)
 	if err != nil {
+		p.logger.Warn("order rejected", slog.String("product", p.name), slog.String("side", constants.DemandOrderType), slog.Float64("price", price), slog.Float64("qty", quantity), slog.String("error", err.Error()))
+		p.metrics.Rejections.Inc(p.name, rejectionReason(err))
+		p.reject(constants.DemandOrderType, price, quantity, err, opts...)
 		return err, nil, nil
 	}
 
-	return nil, matchDemand, matchSupply
+	ev := event_sourcing.NewProductDemandEvent(p.name, price, quantity, opts...)
+	return p.placeOrder(ev)
 }
 
-func (p *Product) DemandProduct(price, quantity float64) (error, []*order.Order, []*order.Order) {
-	ev := event_sourcing.NewProductDemandEvent(p.name, price, quantity)
+// StopProduct places a stop order of orderType that waits in the stop book until the last trade price reaches
+// stopPrice, rising to it for a demand and falling to it for a supply. Once triggered it becomes a limit order
+// at price, or a market order if price is zero. A stop price the last trade price already reached is refused.
+func (p *Product) StopProduct(orderType string, stopPrice, price, quantity float64, opts ...event_sourcing.OrderOption) (error, []*order.Order, []*order.Order) {
+	stopPrice, quantity, err := p.normalize(orderType, stopPrice, quantity)
+	if err == nil && price != 0 {
+		price, _, err = p.normalize(orderType, price, quantity)
+	}
+	if err != nil {
+		p.logger.Warn("order rejected", slog.String("product", p.name), slog.String("side", orderType), slog.Float64("stop_price", stopPrice), slog.Float64("price", price), slog.Float64("qty", quantity), slog.String("error", err.Error()))
+		p.metrics.Rejections.Inc(p.name, rejectionReason(err))
+		p.reject(orderType, price, quantity, err, opts...)
+		return err, nil, nil
+	}
 
-	err, matchDemand, matchSupply := p.AddEvent(ev)
+	ev := event_sourcing.NewProductStopEvent(p.name, orderType, stopPrice, price, quantity, opts...)
+	return p.placeOrder(ev)
+}
+
+// TriggerStops places the stop orders that the last trade price reached, one at a time in the order they were
+// placed, and records their trades, which move the last price again. The remainder of a triggered market order
+// is cancelled once it matched what it could. Stop orders wait while the product is halted. It returns what
+// the triggered orders matched.
+func (p *Product) TriggerStops() (error, []*order.Order, []*order.Order) {
+	matchDemands, matchSupplies := make([]*order.Order, 0), make([]*order.Order, 0)
+
+	for !p.IsHalted() {
+		stop, ok := p.currentState.Stops.Next(p.currentState.LastPrice)
+		if !ok {
+			break
+		}
+
+		p.aggressor = stop.OrderType
+		err, matchDemand, matchSupply := p.record(event_sourcing.NewProductTriggerEvent(p.name, *stop, p.currentState.LastPrice))
+		if err != nil {
+			return err, matchDemands, matchSupplies
+		}
+		if err = p.haltIfTripped(stop.Id); err != nil {
+			return err, matchDemands, matchSupplies
+		}
+
+		for i := 0; i < len(matchSupply); i++ {
+			if err = p.TradeProduct(matchSupply[i], matchDemand[i]); err != nil {
+				return err, matchDemands, matchSupplies
+			}
+		}
+		matchDemands = append(matchDemands, matchDemand...)
+		matchSupplies = append(matchSupplies, matchSupply...)
+
+		if _, ok := p.Resting(stop.Id); stop.Price.IsZero() && ok {
+			if err = p.CancelOrder(stop.Id); err != nil {
+				return err, matchDemands, matchSupplies
+			}
+		}
+	}
+
+	return nil, matchDemands, matchSupplies
+}
+
+// Resting returns the order with the given id resting in the book.
+func (p *Product) Resting(orderId string) (*order.Order, bool) {
+	demands, supplies := p.currentState.OrderBook.Get()
+	for _, o := range append(demands, supplies...) {
+		if o.Id == orderId {
+			return o, true
+		}
+	}
+	return nil, false
+}
+
+// AmendOrder replaces the resting order orderId with an order of the same side at price and quantity. The
+// replacement is checked before the order is cancelled, so an amend that is refused leaves the order resting
+// as it was and records nothing.
+func (p *Product) AmendOrder(orderId string, price, quantity float64, opts ...event_sourcing.OrderOption) (error, []*order.Order, []*order.Order) {
+	resting, ok := p.Resting(orderId)
+	if !ok {
+		return errors.New(constants.OrderNotFoundErrorMessage), nil, nil
+	}
+	if breaker := p.currentState.CircuitBreaker; p.IsHalted() && !breaker.CoolOffElapsed(time.Now().UnixNano()) {
+		return errors.New(constants.ProductHaltedErrorMessage), nil, nil
+	}
+
+	price, quantity, err := p.normalize(resting.OrderType, price, quantity)
 	if err != nil {
+		p.logger.Warn("amend rejected", slog.String("product", p.name), slog.String("order_id", orderId), slog.Float64("price", price), slog.Float64("qty", quantity), slog.String("error", err.Error()))
+		p.metrics.Rejections.Inc(p.name, rejectionReason(err))
 		return err, nil, nil
 	}
 
+	ev := event_sourcing.NewProductSupplyEvent(p.name, price, quantity, opts...)
+	if resting.OrderType == constants.DemandOrderType {
+		ev = event_sourcing.NewProductDemandEvent(p.name, price, quantity, opts...)
+	}
+
+	p.replacing = orderId
+	defer func() { p.replacing = "" }()
+	if err = p.checkBalance(ev); err != nil {
+		return err, nil, nil
+	}
+
+	if err = p.CancelOrder(orderId); err != nil {
+		return err, nil, nil
+	}
+	return p.placeOrder(ev)
+}
+
+func (p *Product) Resume() error {
+	breaker := p.currentState.CircuitBreaker
+	if breaker == nil || !breaker.IsHalted() {
+		return errors.New(constants.ProductNotHaltedErrorMessage)
+	}
+
+	err, _, _ := p.record(event_sourcing.NewProductResumeEvent(p.name, false))
+	return err
+}
+
+func (p *Product) CancelOrder(orderId string) error {
+	err, _, _ := p.record(event_sourcing.NewProductCancelEvent(p.name, orderId))
+	return err
+}
+
+func (p *Product) IsHalted() bool {
+	return p.currentState.CircuitBreaker != nil && p.currentState.CircuitBreaker.IsHalted()
+}
+
+func (p *Product) normalize(orderType string, price, quantity float64) (float64, float64, error) {
+	if p.instrument == nil {
+		return price, quantity, nil
+	}
+
+	normalizedPrice, normalizedQty, err := p.instrument.Normalize(orderType, decimal.NewFromFloat(price), decimal.NewFromFloat(quantity))
+	if err != nil {
+		return price, quantity, err
+	}
+
+	price, _ = normalizedPrice.Float64()
+	quantity, _ = normalizedQty.Float64()
+	return price, quantity, nil
+}
+
+func (p *Product) placeOrder(ev event_sourcing.Event) (error, []*order.Order, []*order.Order) {
+	var duplicate *DuplicateOrderError
+	if err := p.checkIdempotencyKey(ev); errors.As(err, &duplicate) {
+		return err, nil, nil
+	} else if err != nil {
+		p.rejectOrder(ev, err)
+		return err, nil, nil
+	}
+	if err := p.checkBalance(ev); err != nil {
+		p.rejectOrder(ev, err)
+		return err, nil, nil
+	}
+	if placed, ok := ev.(event_sourcing.OrderEvent); ok {
+		p.aggressor = placed.Order().OrderType
+	}
+
+	breaker := p.currentState.CircuitBreaker
+	if breaker != nil && breaker.CoolOffElapsed(time.Now().UnixNano()) {
+		err, _, _ := p.record(event_sourcing.NewProductResumeEvent(p.name, true))
+		if err != nil {
+			return err, nil, nil
+		}
+	}
+
+	err, matchDemand, matchSupply := p.record(ev)
+	if err != nil {
+		p.rejectOrder(ev, err)
+		return err, nil, nil
+	}
+
+	if placed, ok := ev.(event_sourcing.OrderEvent); ok {
+		if err = p.haltIfTripped(placed.Order().Id); err != nil {
+			return err, nil, nil
+		}
+	}
+
 	return nil, matchDemand, matchSupply
 }
 
+// haltIfTripped records the halt of the product if the order just matched tripped its circuit breaker, and
+// expires what is left of the order.
+func (p *Product) haltIfTripped(orderId string) error {
+	breaker := p.currentState.CircuitBreaker
+	if breaker == nil {
+		return nil
+	}
+
+	trip, ok := breaker.Tripped()
+	if !ok {
+		return nil
+	}
+
+	if err, _, _ := p.record(event_sourcing.NewProductHaltEvent(p.name, trip.ReferencePrice, trip.Price, trip.Timestamp)); err != nil {
+		return err
+	}
+	if _, ok = p.Resting(orderId); !ok {
+		return nil
+	}
+	err, _, _ := p.record(event_sourcing.NewProductExpireEvent(p.name, orderId))
+	return err
+}
+
+// checkIdempotencyKey returns a DuplicateOrderError if the order repeats one its participant placed with the
+// same key, and an error if the key was used for a different order.
+func (p *Product) checkIdempotencyKey(ev event_sourcing.Event) error {
+	placed, ok := ev.(event_sourcing.OrderEvent)
+	if !ok || placed.IdempotencyKey() == "" {
+		return nil
+	}
+
+	o := placed.Order()
+	original, ok := p.placements[placementKey{participant: o.Participant, key: placed.IdempotencyKey()}]
+	if !ok {
+		return nil
+	}
+
+	if original.order.OrderType != o.OrderType || !original.order.Price.Equal(o.Price) || !original.order.Qty.Equal(o.Qty) {
+		err := errors.New(constants.IdempotencyKeyReusedErrorMessage)
+		p.logger.Warn("order rejected", slog.String("product", p.name), slog.String("participant", o.Participant), slog.String("idempotency_key", placed.IdempotencyKey()), slog.String("error", err.Error()))
+		p.metrics.Rejections.Inc(p.name, rejectionReason(err))
+		return err
+	}
+
+	p.logger.Info("duplicate order ignored", slog.String("product", p.name), slog.String("participant", o.Participant), slog.String("idempotency_key", placed.IdempotencyKey()), slog.String("order_id", original.order.Id))
+	return &DuplicateOrderError{Key: placed.IdempotencyKey(), OrderId: original.order.Id, Demands: original.demands, Supplies: original.supplies}
+}
+
+func (p *Product) checkBalance(ev event_sourcing.Event) error {
+	placed, ok := ev.(event_sourcing.OrderEvent)
+	if p.balances == nil || !ok {
+		return nil
+	}
+
+	o := placed.Order()
+	var err error
+	if p.replacing != "" {
+		// the order being replaced releases its reservation along with the replacement
+		err = p.balances.CheckReplacement(p.Id, p.name, p.replacing, o)
+	} else {
+		err = p.balances.Check(p.name, o)
+	}
+	if err != nil {
+		p.logger.Warn("order rejected", slog.String("product", p.name), slog.String("side", o.OrderType), slog.String("participant", o.Participant), slog.String("price", o.Price.String()), slog.String("qty", o.Qty.String()), slog.String("error", err.Error()))
+		p.metrics.Rejections.Inc(p.name, rejectionReason(err))
+		return err
+	}
+	return nil
+}
+
+// rejectOrder records the rejection of an order whose event was already built.
+func (p *Product) rejectOrder(ev event_sourcing.Event, err error) {
+	placed, ok := ev.(event_sourcing.OrderEvent)
+	if !ok {
+		return
+	}
+
+	o := placed.Order()
+	price, _ := o.Price.Float64()
+	quantity, _ := o.Qty.Float64()
+	p.reject(o.OrderType, price, quantity, err,
+		event_sourcing.WithOrderId(o.Id),
+		event_sourcing.WithParticipant(o.Participant),
+		event_sourcing.WithOrigin(placed.Origin()),
+		event_sourcing.WithIdempotencyKey(placed.IdempotencyKey()),
+	)
+}
+
+// reject records that an order was refused, so that its status can be told like that of any other order.
+func (p *Product) reject(orderType string, price, quantity float64, err error, opts ...event_sourcing.OrderOption) {
+	_, _, _ = p.record(event_sourcing.NewProductRejectEvent(p.name, orderType, price, quantity, err.Error(), opts...))
+}
+
+// TradeProduct records the trade of a match returned by placing an order. Once every match of the placement is
+// traded, TriggerStops places the stop orders that the trades triggered.
 func (p *Product) TradeProduct(matchSupply, matchDemand *order.Order) error {
-	ev := event_sourcing.NewTradeEvent(matchSupply, matchDemand)
-	err, _, _ := p.AddEvent(ev)
+	var opts []event_sourcing.TradeOption
+	if p.fees != nil {
+		opts = append(opts, event_sourcing.WithFees(p.fees.Charge(p.name, matchSupply, matchDemand, p.aggressor)))
+	}
+
+	ev := event_sourcing.NewTradeEvent(p.name, matchSupply, matchDemand, opts...)
+	err, _, _ := p.record(ev)
 	if err != nil {
 		return err
 	}
@@ -68,9 +447,115 @@ func (p *Product) AddEvent(ev event_sourcing.Event) (error, []*order.Order, []*o
 	}
 
 	p.events = append(p.events, ev)
+	p.remember(ev)
 	return nil, matchDemand, matchSupply
 }
 
+// remember keeps the orders placed with an idempotency key along with the trades recorded right after them,
+// so that the placements are rebuilt when the events are replayed.
+func (p *Product) remember(ev event_sourcing.Event) {
+	switch e := ev.(type) {
+	case event_sourcing.OrderEvent:
+		p.placing = nil
+		if e.IdempotencyKey() == "" {
+			return
+		}
+
+		o := e.Order()
+		p.placing = &placement{order: o}
+		p.placements[placementKey{participant: o.Participant, key: e.IdempotencyKey()}] = p.placing
+	case event_sourcing.TradeEvent:
+		if p.placing == nil {
+			return
+		}
+
+		supply, demand := e.Orders()
+		if supply.Id != p.placing.order.Id && demand.Id != p.placing.order.Id {
+			return
+		}
+		p.placing.demands = append(p.placing.demands, &demand)
+		p.placing.supplies = append(p.placing.supplies, &supply)
+	}
+}
+
+// record appends a newly issued event and logs the outcome. Replayed events go through AddEvent directly and are not logged again.
+func (p *Product) record(ev event_sourcing.Event) (error, []*order.Order, []*order.Order) {
+	start := time.Now()
+	err, matchDemand, matchSupply := p.AddEvent(ev)
+	if err != nil {
+		p.logger.Warn("event rejected", slog.Any("event", ev), slog.String("error", err.Error()))
+		p.metrics.Rejections.Inc(p.name, rejectionReason(err))
+		return err, nil, nil
+	}
+
+	switch ev.Type() {
+	case constants.SupplyEventType:
+		p.metrics.MatchingLatency.Observe(time.Since(start).Seconds(), p.name)
+		p.metrics.Orders.Inc(p.name, sideLabel(constants.SupplyOrderType))
+	case constants.DemandEventType:
+		p.metrics.MatchingLatency.Observe(time.Since(start).Seconds(), p.name)
+		p.metrics.Orders.Inc(p.name, sideLabel(constants.DemandOrderType))
+	case constants.StopEventType:
+		if placed, ok := ev.(event_sourcing.OrderEvent); ok {
+			p.metrics.Orders.Inc(p.name, sideLabel(placed.Order().OrderType))
+		}
+	case constants.TriggerEventType:
+		p.metrics.MatchingLatency.Observe(time.Since(start).Seconds(), p.name)
+	case constants.TradeEventType:
+		p.metrics.Trades.Inc(p.name)
+	case constants.CancelEventType:
+		if len(matchDemand) > 0 {
+			p.metrics.Cancels.Inc(p.name, sideLabel(constants.DemandOrderType))
+		} else {
+			p.metrics.Cancels.Inc(p.name, sideLabel(constants.SupplyOrderType))
+		}
+	}
+	p.observeBook()
+
+	p.logger.Info("event recorded", slog.Any("event", ev))
+	return nil, matchDemand, matchSupply
+}
+
+func (p *Product) observeBook() {
+	demands, supplies := p.currentState.OrderBook.Get()
+
+	for side, orders := range map[string][]*order.Order{constants.DemandOrderType: demands, constants.SupplyOrderType: supplies} {
+		qty := decimal.Zero
+		for _, o := range orders {
+			qty = qty.Add(o.Qty)
+		}
+
+		resting, _ := qty.Float64()
+		p.metrics.BookDepth.Set(float64(len(orders)), p.name, sideLabel(side))
+		p.metrics.RestingQty.Set(resting, p.name, sideLabel(side))
+	}
+}
+
+func rejectionReason(err error) string {
+	var violation *instrument.ViolationError
+	var insufficient *settlement.InsufficientBalanceError
+	switch {
+	case errors.As(err, &violation):
+		return "instrument"
+	case errors.As(err, &insufficient):
+		return "insufficient_balance"
+	case err.Error() == constants.IdempotencyKeyReusedErrorMessage:
+		return "idempotency_key_reused"
+	case err.Error() == constants.StopPriceCrossedErrorMessage:
+		return "stop_price_reached"
+	case err.Error() == constants.ProductHaltedErrorMessage:
+		return "halted"
+	case err.Error() == constants.OrderNotFoundErrorMessage:
+		return "not_found"
+	default:
+		return "other"
+	}
+}
+
+func sideLabel(orderType string) string {
+	return strings.ToLower(orderType)
+}
+
 func (p *Product) GetEvents() []event_sourcing.Event {
 	return p.events
 }
@@ -0,0 +1,68 @@
+package stop_book
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/shopspring/decimal"
+)
+
+// StopBook holds the stop orders of a product, in the order they were placed, until the last trade price
+// reaches their stop price. A demand stop is reached once the price rises to its stop price, a supply stop
+// once it falls to it.
+type StopBook struct {
+	orders []*order.Order
+}
+
+func ProvideStopBook() *StopBook {
+	return &StopBook{orders: make([]*order.Order, 0)}
+}
+
+func (sb *StopBook) Get() []*order.Order {
+	return append(make([]*order.Order, 0, len(sb.orders)), sb.orders...)
+}
+
+func (sb *StopBook) Add(o *order.Order) {
+	sb.orders = append(sb.orders, o)
+}
+
+// Remove takes the stop order with the given id out of the book.
+func (sb *StopBook) Remove(orderId string) (*order.Order, bool) {
+	for i, o := range sb.orders {
+		if o.Id == orderId {
+			sb.orders = append(sb.orders[:i:i], sb.orders[i+1:]...)
+			return o, true
+		}
+	}
+	return nil, false
+}
+
+// Next returns the earliest placed stop order that lastPrice reached.
+func (sb *StopBook) Next(lastPrice decimal.Decimal) (*order.Order, bool) {
+	if lastPrice.IsZero() {
+		return nil, false
+	}
+
+	for _, o := range sb.orders {
+		if Reached(o, lastPrice) {
+			return o, true
+		}
+	}
+	return nil, false
+}
+
+// Reached tells whether lastPrice reached the stop price of o.
+func Reached(o *order.Order, lastPrice decimal.Decimal) bool {
+	if o.OrderType == constants.DemandOrderType {
+		return lastPrice.GreaterThanOrEqual(o.StopPrice)
+	}
+	return lastPrice.LessThanOrEqual(o.StopPrice)
+}
+
+func (sb *StopBook) Clone() *StopBook {
+	clone := &StopBook{orders: make([]*order.Order, 0, len(sb.orders))}
+	for _, o := range sb.orders {
+		c := *o
+		clone.orders = append(clone.orders, &c)
+	}
+	return clone
+}
@@ -0,0 +1,61 @@
+package stop_book_test
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/stop_book"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type stopBookSuite struct {
+	suite.Suite
+	book *stop_book.StopBook
+}
+
+func TestStopBookSuite(t *testing.T) {
+	suite.Run(t, new(stopBookSuite))
+}
+
+func (suite *stopBookSuite) SetupTest() {
+	suite.book = stop_book.ProvideStopBook()
+	suite.book.Add(&order.Order{Id: "buy-25", OrderType: constants.DemandOrderType, StopPrice: decimal.NewFromInt(25)})
+	suite.book.Add(&order.Order{Id: "sell-20", OrderType: constants.SupplyOrderType, StopPrice: decimal.NewFromInt(20)})
+	suite.book.Add(&order.Order{Id: "buy-22", OrderType: constants.DemandOrderType, StopPrice: decimal.NewFromInt(22)})
+}
+
+func (suite *stopBookSuite) TestNextWaitsForTheFirstTrade() {
+	_, ok := suite.book.Next(decimal.Zero)
+	suite.Assert().False(ok)
+
+	_, ok = suite.book.Next(decimal.NewFromInt(21))
+	suite.Assert().False(ok, "21 lies between the stop prices")
+}
+
+func (suite *stopBookSuite) TestNextReturnsTheEarliestStopThePriceReached() {
+	o, ok := suite.book.Next(decimal.NewFromInt(25))
+	suite.Require().True(ok)
+	suite.Assert().Equal("buy-25", o.Id)
+
+	o, ok = suite.book.Next(decimal.NewFromInt(20))
+	suite.Require().True(ok)
+	suite.Assert().Equal("sell-20", o.Id)
+
+	_, _ = suite.book.Remove("buy-25")
+	o, ok = suite.book.Next(decimal.NewFromInt(30))
+	suite.Require().True(ok)
+	suite.Assert().Equal("buy-22", o.Id)
+}
+
+func (suite *stopBookSuite) TestCloneLeavesTheBookUntouched() {
+	clone := suite.book.Clone()
+	_, ok := clone.Remove("sell-20")
+	suite.Require().True(ok)
+
+	suite.Assert().Len(clone.Get(), 2)
+	suite.Assert().Len(suite.book.Get(), 3)
+
+	_, ok = clone.Remove("sell-20")
+	suite.Assert().False(ok)
+}
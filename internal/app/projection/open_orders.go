@@ -0,0 +1,151 @@
+package projection
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"sort"
+	"sync"
+)
+
+const OpenOrdersName = "open_orders"
+
+type OpenOrder struct {
+	Product string
+	order.Order
+}
+
+type openOrderKey struct {
+	streamId string
+	orderId  string
+}
+
+// OpenOrders tracks the resting quantity of every order that is still in a book, or waits for its stop price,
+// grouped by participant.
+type OpenOrders struct {
+	mtx    sync.RWMutex
+	orders map[openOrderKey]*OpenOrder
+}
+
+func NewOpenOrders() *OpenOrders {
+	o := &OpenOrders{}
+	o.Reset()
+	return o
+}
+
+func (o *OpenOrders) Name() string {
+	return OpenOrdersName
+}
+
+func (o *OpenOrders) Handlers() map[string]Handler {
+	return map[string]Handler{
+		constants.SupplyEventType:  o.place,
+		constants.DemandEventType:  o.place,
+		constants.StopEventType:    o.place,
+		constants.TriggerEventType: o.trigger,
+		constants.TradeEventType:   o.fill,
+		constants.CancelEventType:  o.cancel,
+		constants.ExpireEventType:  o.cancel,
+	}
+}
+
+func (o *OpenOrders) Reset() {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.orders = make(map[openOrderKey]*OpenOrder)
+}
+
+// ByParticipant returns the open orders of a participant across all products, oldest first.
+func (o *OpenOrders) ByParticipant(participant string) []OpenOrder {
+	o.mtx.RLock()
+	defer o.mtx.RUnlock()
+
+	result := make([]OpenOrder, 0)
+	for _, oo := range o.orders {
+		if oo.Participant == participant {
+			result = append(result, *oo)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Timestamp < result[j].Timestamp })
+	return result
+}
+
+// ById returns the open order with the given id, in whichever product it rests.
+func (o *OpenOrders) ById(orderId string) (OpenOrder, bool) {
+	o.mtx.RLock()
+	defer o.mtx.RUnlock()
+
+	for _, oo := range o.orders {
+		if oo.Id == orderId {
+			return *oo, true
+		}
+	}
+	return OpenOrder{}, false
+}
+
+func (o *OpenOrders) place(record event_sourcing.Record) {
+	placed, ok := record.Event.(event_sourcing.OrderEvent)
+	if !ok {
+		return
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.orders[openOrderKey{streamId: record.Stream, orderId: placed.Order().Id}] = &OpenOrder{Product: record.Event.Product(), Order: placed.Order()}
+}
+
+// trigger replaces a stop order with the order it became.
+func (o *OpenOrders) trigger(record event_sourcing.Record) {
+	te, ok := record.Event.(event_sourcing.TriggerEvent)
+	if !ok {
+		return
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	key := openOrderKey{streamId: record.Stream, orderId: te.Order().Id}
+	if _, ok := o.orders[key]; !ok {
+		return
+	}
+	o.orders[key] = &OpenOrder{Product: record.Event.Product(), Order: te.Order()}
+}
+
+func (o *OpenOrders) fill(record event_sourcing.Record) {
+	te, ok := record.Event.(event_sourcing.TradeEvent)
+	if !ok {
+		return
+	}
+	t := te.Trade()
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	for _, orderId := range []string{t.SupplyOrderId, t.DemandOrderId} {
+		key := openOrderKey{streamId: record.Stream, orderId: orderId}
+		oo, ok := o.orders[key]
+		if !ok {
+			continue
+		}
+
+		oo.Qty = oo.Qty.Sub(t.Qty)
+		if !oo.Qty.IsPositive() {
+			delete(o.orders, key)
+		}
+	}
+}
+
+func (o *OpenOrders) cancel(record event_sourcing.Record) {
+	ce, ok := record.Event.(event_sourcing.CancelEvent)
+	if !ok {
+		return
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	delete(o.orders, openOrderKey{streamId: record.Stream, orderId: ce.OrderId()})
+}
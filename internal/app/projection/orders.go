@@ -0,0 +1,302 @@
+package projection
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/shopspring/decimal"
+	"sort"
+	"sync"
+	"time"
+)
+
+const OrdersName = "orders"
+
+// Fill is a trade seen from one of its orders.
+type Fill struct {
+	TradeId        string
+	CounterOrderId string
+	Price          decimal.Decimal
+	Qty            decimal.Decimal
+	Liquidity      string
+	Timestamp      time.Time
+}
+
+// OrderState is the lifecycle of an order. Order is the order as it was placed and Status one of the
+// constants order statuses:
+//
+//	pending           a stop order waiting for the last trade price to reach its stop price
+//	new               resting in the book, nothing filled
+//	partially_filled  resting in the book, part of it filled
+//	filled            filled completely
+//	cancelled         cancelled by its participant
+//	expired           withdrawn by the ledger, as the remainder of an order whose sweep halted the product
+//	rejected          refused when submitted, Reason says why
+//
+// Once filled, cancelled, expired or rejected an order does not change anymore, LeavesQty then is what was
+// left unfilled.
+type OrderState struct {
+	Product string
+	order.Order
+	Status    string
+	Reason    string
+	FilledQty decimal.Decimal
+	LeavesQty decimal.Decimal
+	Notional  decimal.Decimal
+	Fills     []Fill
+	UpdatedAt time.Time
+
+	sequence uint64
+}
+
+// AvgPrice is the average price of the fills, weighted by their quantity, zero without fills.
+func (s OrderState) AvgPrice() decimal.Decimal {
+	if s.FilledQty.IsZero() {
+		return decimal.Zero
+	}
+	return s.Notional.DivRound(s.FilledQty, 8)
+}
+
+// Open tells whether the order still rests in its book, or waits for its stop price.
+func (s OrderState) Open() bool {
+	return s.Status == constants.PendingOrderStatus || s.Status == constants.NewOrderStatus || s.Status == constants.PartiallyFilledOrderStatus
+}
+
+// Orders tracks every order placed or rejected, including the ones that left their book, with their fills.
+// byId indexes the latest placement of every order id across products.
+type Orders struct {
+	mtx    sync.RWMutex
+	orders map[openOrderKey]*OrderState
+	byId   map[string]*OrderState
+}
+
+func NewOrders() *Orders {
+	o := &Orders{}
+	o.Reset()
+	return o
+}
+
+func (o *Orders) Name() string {
+	return OrdersName
+}
+
+func (o *Orders) Handlers() map[string]Handler {
+	return map[string]Handler{
+		constants.SupplyEventType:  o.place,
+		constants.DemandEventType:  o.place,
+		constants.StopEventType:    o.stop,
+		constants.TriggerEventType: o.trigger,
+		constants.RejectEventType:  o.reject,
+		constants.TradeEventType:   o.fill,
+		constants.CancelEventType:  o.cancel,
+		constants.ExpireEventType:  o.expire,
+	}
+}
+
+func (o *Orders) Reset() {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.orders = make(map[openOrderKey]*OrderState)
+	o.byId = make(map[string]*OrderState)
+}
+
+// ById returns the order with the given id, in whichever product it was placed. An id placed again, as an
+// amended order is, returns its latest placement.
+func (o *Orders) ById(orderId string) (OrderState, bool) {
+	o.mtx.RLock()
+	defer o.mtx.RUnlock()
+
+	s, ok := o.byId[orderId]
+	if !ok {
+		return OrderState{}, false
+	}
+	return s.copy(), true
+}
+
+// ByParticipant returns the orders of a participant across all products, oldest first.
+func (o *Orders) ByParticipant(participant string) []OrderState {
+	o.mtx.RLock()
+	defer o.mtx.RUnlock()
+
+	result := make([]OrderState, 0)
+	for _, s := range o.orders {
+		if s.Participant == participant {
+			result = append(result, s.copy())
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].sequence < result[j].sequence })
+	return result
+}
+
+func (o *Orders) place(record event_sourcing.Record) {
+	placed, ok := record.Event.(event_sourcing.OrderEvent)
+	if !ok {
+		return
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.add(record, placed.Order(), constants.NewOrderStatus)
+}
+
+// stop tracks a stop order as pending until it is triggered.
+func (o *Orders) stop(record event_sourcing.Record) {
+	placed, ok := record.Event.(event_sourcing.OrderEvent)
+	if !ok {
+		return
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.add(record, placed.Order(), constants.PendingOrderStatus)
+}
+
+func (o *Orders) trigger(record event_sourcing.Record) {
+	te, ok := record.Event.(event_sourcing.TriggerEvent)
+	if !ok {
+		return
+	}
+	triggered := te.Order()
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	key := openOrderKey{streamId: record.Stream, orderId: triggered.Id}
+	s, ok := o.orders[key]
+	if !ok || s.Status != constants.PendingOrderStatus {
+		return
+	}
+
+	s.Status = constants.NewOrderStatus
+	s.UpdatedAt = record.Time
+}
+
+func (o *Orders) add(record event_sourcing.Record, placedOrder order.Order, status string) {
+	key := openOrderKey{streamId: record.Stream, orderId: placedOrder.Id}
+	o.index(key, &OrderState{
+		Product:   record.Event.Product(),
+		Order:     placedOrder,
+		Status:    status,
+		FilledQty: decimal.Zero,
+		LeavesQty: placedOrder.Qty,
+		Notional:  decimal.Zero,
+		UpdatedAt: record.Time,
+		sequence:  record.Sequence,
+	})
+}
+
+func (o *Orders) reject(record event_sourcing.Record) {
+	re, ok := record.Event.(event_sourcing.RejectEvent)
+	if !ok {
+		return
+	}
+	rejected := re.Order()
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	key := openOrderKey{streamId: record.Stream, orderId: rejected.Id}
+	if s, ok := o.orders[key]; ok && s.Open() {
+		// the id is still resting, the rejected order did not replace it
+		return
+	}
+
+	o.index(key, &OrderState{
+		Product:   record.Event.Product(),
+		Order:     rejected,
+		Status:    constants.RejectedOrderStatus,
+		Reason:    re.Reason(),
+		FilledQty: decimal.Zero,
+		LeavesQty: decimal.Zero,
+		Notional:  decimal.Zero,
+		UpdatedAt: record.Time,
+		sequence:  record.Sequence,
+	})
+}
+
+func (o *Orders) index(key openOrderKey, s *OrderState) {
+	o.orders[key] = s
+	o.byId[key.orderId] = s
+}
+
+func (o *Orders) fill(record event_sourcing.Record) {
+	te, ok := record.Event.(event_sourcing.TradeEvent)
+	if !ok {
+		return
+	}
+	t := te.Trade()
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	for _, side := range []struct {
+		orderId, counterOrderId string
+		fee                     event_sourcing.Fee
+	}{
+		{t.SupplyOrderId, t.DemandOrderId, t.SupplyFee},
+		{t.DemandOrderId, t.SupplyOrderId, t.DemandFee},
+	} {
+		s, ok := o.orders[openOrderKey{streamId: record.Stream, orderId: side.orderId}]
+		if !ok || s.Status == constants.RejectedOrderStatus {
+			continue
+		}
+
+		s.Fills = append(s.Fills, Fill{TradeId: t.Id, CounterOrderId: side.counterOrderId, Price: t.Price, Qty: t.Qty, Liquidity: side.fee.Liquidity, Timestamp: t.Timestamp})
+		s.FilledQty = s.FilledQty.Add(t.Qty)
+		s.Notional = s.Notional.Add(t.Price.Mul(t.Qty))
+		s.LeavesQty = decimal.Max(s.LeavesQty.Sub(t.Qty), decimal.Zero)
+		if s.Open() {
+			s.Status = constants.PartiallyFilledOrderStatus
+			if s.LeavesQty.IsZero() {
+				s.Status = constants.FilledOrderStatus
+			}
+		}
+		s.UpdatedAt = record.Time
+	}
+}
+
+func (o *Orders) cancel(record event_sourcing.Record) {
+	ce, ok := record.Event.(event_sourcing.CancelEvent)
+	if !ok {
+		return
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.end(openOrderKey{streamId: record.Stream, orderId: ce.OrderId()}, constants.CancelledOrderStatus, record.Time)
+}
+
+// expire ends the order whose sweep tripped the circuit breaker. The trades of the sweep are recorded after
+// it and still fill it.
+func (o *Orders) expire(record event_sourcing.Record) {
+	ce, ok := record.Event.(event_sourcing.CancelEvent)
+	if !ok {
+		return
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.end(openOrderKey{streamId: record.Stream, orderId: ce.OrderId()}, constants.ExpiredOrderStatus, record.Time)
+}
+
+func (o *Orders) end(key openOrderKey, status string, at time.Time) {
+	s, ok := o.orders[key]
+	if !ok || !s.Open() {
+		return
+	}
+
+	s.Status = status
+	s.UpdatedAt = at
+}
+
+func (s *OrderState) copy() OrderState {
+	c := *s
+	c.Fills = append([]Fill(nil), s.Fills...)
+	return c
+}
@@ -0,0 +1,160 @@
+package projection
+
+import (
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"log/slog"
+	"sync"
+)
+
+type Handler func(record event_sourcing.Record)
+
+// Projection is a read model built from the event store. Handlers are keyed by event type, events of any
+// other type are skipped. Reset drops everything built so far, so the projection can be rebuilt from zero.
+type Projection interface {
+	Name() string
+	Handlers() map[string]Handler
+	Reset()
+}
+
+// Store is the part of the event store projections read from.
+type Store interface {
+	ReadAll(after uint64) []event_sourcing.Record
+	Subscribe(fn func(records []event_sourcing.Record))
+}
+
+type Option func(m *Manager)
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+type subscription struct {
+	projection Projection
+	handlers   map[string]Handler
+	position   uint64
+}
+
+// Manager keeps registered projections up to date. A projection catches up from history when registered
+// and then follows every append to the store. Positions are global sequence numbers, so every projection
+// sees events in the order they were appended across products.
+type Manager struct {
+	mtx           sync.Mutex
+	store         Store
+	subscriptions []*subscription
+	logger        *slog.Logger
+}
+
+func NewManager(store Store, opts ...Option) *Manager {
+	m := &Manager{store: store, logger: logging.Discard()}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	store.Subscribe(m.follow)
+	return m
+}
+
+func (m *Manager) Register(p Projection) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.find(p.Name()) != nil {
+		return fmt.Errorf("projection %s registered twice", p.Name())
+	}
+
+	sub := &subscription{projection: p, handlers: p.Handlers()}
+	m.subscriptions = append(m.subscriptions, sub)
+	m.catchUp(sub)
+	return nil
+}
+
+// Rebuild resets the named projection and replays the whole store into it.
+func (m *Manager) Rebuild(name string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	sub := m.find(name)
+	if sub == nil {
+		return fmt.Errorf("unknown projection %s", name)
+	}
+
+	m.rebuild(sub)
+	return nil
+}
+
+func (m *Manager) RebuildAll() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, sub := range m.subscriptions {
+		m.rebuild(sub)
+	}
+}
+
+func (m *Manager) Names() []string {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	names := make([]string, 0, len(m.subscriptions))
+	for _, sub := range m.subscriptions {
+		names = append(names, sub.projection.Name())
+	}
+	return names
+}
+
+// Position returns the sequence number of the last event the named projection processed.
+func (m *Manager) Position(name string) (uint64, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	sub := m.find(name)
+	if sub == nil {
+		return 0, false
+	}
+	return sub.position, true
+}
+
+func (m *Manager) follow(_ []event_sourcing.Record) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, sub := range m.subscriptions {
+		m.catchUp(sub)
+	}
+}
+
+func (m *Manager) rebuild(sub *subscription) {
+	sub.projection.Reset()
+	sub.position = 0
+	m.catchUp(sub)
+	m.logger.Info("projection rebuilt", slog.String("projection", sub.projection.Name()))
+}
+
+func (m *Manager) catchUp(sub *subscription) {
+	records := m.store.ReadAll(sub.position)
+
+	for _, record := range records {
+		if handle, ok := sub.handlers[record.Event.Type()]; ok {
+			handle(record)
+		}
+		sub.position = record.Sequence
+	}
+
+	if len(records) > 0 {
+		m.logger.Debug("projection advanced", slog.String("projection", sub.projection.Name()), slog.Uint64("position", sub.position))
+	}
+}
+
+func (m *Manager) find(name string) *subscription {
+	for _, sub := range m.subscriptions {
+		if sub.projection.Name() == name {
+			return sub
+		}
+	}
+	return nil
+}
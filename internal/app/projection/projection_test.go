@@ -0,0 +1,236 @@
+package projection_test
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/circuit_breaker"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/instrument"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/product"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/repository"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type countingProjection struct {
+	seen []string
+}
+
+func (c *countingProjection) add(record event_sourcing.Record) {
+	c.seen = append(c.seen, record.Event.Product()+":"+record.Event.Type())
+}
+
+func (c *countingProjection) Name() string {
+	return "counting"
+}
+
+func (c *countingProjection) Handlers() map[string]projection.Handler {
+	return map[string]projection.Handler{
+		constants.SupplyEventType: c.add,
+		constants.TradeEventType:  c.add,
+	}
+}
+
+func (c *countingProjection) Reset() {
+	c.seen = nil
+}
+
+type projectionSuite struct {
+	suite.Suite
+	repo    *repository.LedgerRepository
+	manager *projection.Manager
+	tomato  *product.Product
+}
+
+func TestProjectionSuite(t *testing.T) {
+	suite.Run(t, new(projectionSuite))
+}
+
+func (suite *projectionSuite) SetupTest() {
+	suite.repo = repository.NewWarehouseRepository()
+	suite.manager = projection.NewManager(suite.repo)
+	suite.tomato = suite.repo.Get("tomato-stream", "tomato")
+}
+
+func (suite *projectionSuite) supply(p *product.Product, id, participant string, price, qty float64) {
+	err, demands, supplies := p.SupplyProduct(price, qty, event_sourcing.WithOrderId(id), event_sourcing.WithParticipant(participant))
+	suite.trade(p, err, demands, supplies)
+}
+
+func (suite *projectionSuite) demand(p *product.Product, id, participant string, price, qty float64) {
+	err, demands, supplies := p.DemandProduct(price, qty, event_sourcing.WithOrderId(id), event_sourcing.WithParticipant(participant))
+	suite.trade(p, err, demands, supplies)
+}
+
+func (suite *projectionSuite) trade(p *product.Product, err error, demands, supplies []*order.Order) {
+	suite.Require().NoError(err)
+	for i := range supplies {
+		suite.Require().NoError(p.TradeProduct(supplies[i], demands[i]))
+	}
+}
+
+func (suite *projectionSuite) TestCatchesUpFromHistoryAndFollowsAppends() {
+	suite.supply(suite.tomato, "s1", "grower-1", 20, 90)
+	suite.repo.Save(suite.tomato)
+
+	counting := &countingProjection{}
+	suite.Require().NoError(suite.manager.Register(counting))
+	suite.Assert().Equal([]string{"tomato:supply"}, counting.seen)
+
+	suite.demand(suite.tomato, "d1", "buyer-1", 22, 50)
+	suite.repo.Save(suite.tomato)
+	suite.Assert().Equal([]string{"tomato:supply", "tomato:trade"}, counting.seen)
+
+	position, ok := suite.manager.Position("counting")
+	suite.Require().True(ok)
+	suite.Assert().Equal(uint64(3), position)
+}
+
+func (suite *projectionSuite) TestSeesStreamsInAppendOrder() {
+	potato := suite.repo.Get("potato-stream", "potato")
+
+	suite.supply(suite.tomato, "s1", "grower-1", 20, 90)
+	suite.repo.Save(suite.tomato)
+	suite.supply(potato, "s2", "grower-2", 10, 30)
+	suite.repo.Save(potato)
+	suite.supply(suite.tomato, "s3", "grower-1", 21, 10)
+	suite.repo.Save(suite.tomato)
+
+	counting := &countingProjection{}
+	suite.Require().NoError(suite.manager.Register(counting))
+	suite.Assert().Equal([]string{"tomato:supply", "potato:supply", "tomato:supply"}, counting.seen)
+}
+
+func (suite *projectionSuite) TestRebuildReplaysFromZero() {
+	counting := &countingProjection{}
+	suite.Require().NoError(suite.manager.Register(counting))
+
+	suite.supply(suite.tomato, "s1", "grower-1", 20, 90)
+	suite.repo.Save(suite.tomato)
+
+	counting.seen = append(counting.seen, "garbage")
+	suite.Require().NoError(suite.manager.Rebuild("counting"))
+	suite.Assert().Equal([]string{"tomato:supply"}, counting.seen)
+
+	suite.Assert().Error(suite.manager.Rebuild("unknown"))
+	suite.Assert().Error(suite.manager.Register(&countingProjection{}))
+}
+
+func (suite *projectionSuite) TestOpenOrdersByParticipant() {
+	openOrders := projection.NewOpenOrders()
+	suite.Require().NoError(suite.manager.Register(openOrders))
+
+	potato := suite.repo.Get("potato-stream", "potato")
+	suite.supply(suite.tomato, "s1", "grower-1", 20, 90)
+	suite.supply(suite.tomato, "s2", "grower-1", 25, 40)
+	suite.supply(potato, "s3", "grower-1", 10, 30)
+	suite.demand(suite.tomato, "d1", "buyer-1", 22, 50)
+	suite.Require().NoError(suite.tomato.CancelOrder("s2"))
+	suite.repo.Save(suite.tomato)
+	suite.repo.Save(potato)
+
+	orders := openOrders.ByParticipant("grower-1")
+	suite.Require().Len(orders, 2)
+	suite.Assert().Equal("tomato", orders[0].Product)
+	suite.Assert().Equal("s1", orders[0].Id)
+	suite.Assert().True(decimal.NewFromInt(40).Equal(orders[0].Qty))
+	suite.Assert().Equal("potato", orders[1].Product)
+	suite.Assert().Equal("s3", orders[1].Id)
+
+	suite.Assert().Empty(openOrders.ByParticipant("buyer-1"))
+}
+
+func (suite *projectionSuite) TestTradeHistory() {
+	history := projection.NewTradeHistory()
+	suite.Require().NoError(suite.manager.Register(history))
+
+	suite.supply(suite.tomato, "s1", "grower-1", 20, 90)
+	suite.demand(suite.tomato, "d1", "buyer-1", 22, 50)
+	suite.demand(suite.tomato, "d2", "buyer-2", 21, 40)
+	suite.repo.Save(suite.tomato)
+
+	suite.Require().Len(history.Trades("", ""), 2)
+	suite.Require().Len(history.Trades("tomato", "buyer-2"), 1)
+	suite.Assert().Equal("d2", history.Trades("tomato", "buyer-2")[0].DemandOrderId)
+	suite.Assert().Len(history.Trades("", "grower-1"), 2)
+	suite.Assert().Empty(history.Trades("potato", ""))
+}
+
+func (suite *projectionSuite) TestOrdersFollowTheLifecycleOfEveryOrder() {
+	orders := projection.NewOrders()
+	suite.Require().NoError(suite.manager.Register(orders))
+
+	suite.supply(suite.tomato, "s1", "grower-1", 20, 90)
+	suite.demand(suite.tomato, "d1", "buyer-1", 22, 50)
+	suite.demand(suite.tomato, "d2", "buyer-2", 21, 40)
+	suite.supply(suite.tomato, "s2", "grower-1", 25, 30)
+	suite.Require().NoError(suite.tomato.CancelOrder("s2"))
+	suite.supply(suite.tomato, "s3", "grower-2", 24, 10)
+	suite.supply(suite.tomato, "s4", "grower-2", 26, 10)
+	suite.demand(suite.tomato, "d3", "buyer-1", 30, 35)
+	suite.repo.Save(suite.tomato)
+
+	s1, ok := orders.ById("s1")
+	suite.Require().True(ok)
+	suite.Assert().Equal(constants.FilledOrderStatus, s1.Status)
+	suite.Assert().True(decimal.NewFromInt(90).Equal(s1.FilledQty))
+	suite.Assert().True(decimal.NewFromInt(90).Equal(s1.Qty), "the order as it was placed")
+	suite.Require().Len(s1.Fills, 2)
+	suite.Assert().Equal("d1", s1.Fills[0].CounterOrderId)
+	suite.Assert().Equal("d2", s1.Fills[1].CounterOrderId)
+
+	s2, _ := orders.ById("s2")
+	suite.Assert().Equal(constants.CancelledOrderStatus, s2.Status)
+	suite.Assert().True(decimal.NewFromInt(30).Equal(s2.LeavesQty))
+
+	d3, _ := orders.ById("d3")
+	suite.Assert().Equal(constants.PartiallyFilledOrderStatus, d3.Status)
+	suite.Assert().True(decimal.NewFromInt(20).Equal(d3.FilledQty))
+	suite.Assert().True(decimal.NewFromInt(15).Equal(d3.LeavesQty))
+	suite.Assert().True(decimal.NewFromInt(25).Equal(d3.AvgPrice()))
+
+	_, ok = orders.ById("unknown")
+	suite.Assert().False(ok)
+	suite.Assert().Len(orders.ByParticipant("grower-2"), 2)
+}
+
+func (suite *projectionSuite) TestOrdersTellRejectedAndExpiredOrders() {
+	orders := projection.NewOrders()
+	suite.Require().NoError(suite.manager.Register(orders))
+
+	potato := product.NewProduct("potato-stream", "potato",
+		product.WithInstrumentSpec(instrument.Spec{TickSize: decimal.NewFromFloat(0.5), LotSize: decimal.NewFromInt(1), Rounding: instrument.RejectPolicy}),
+		product.WithCircuitBreaker(circuit_breaker.Config{MaxMovePercent: decimal.NewFromInt(10), Window: time.Minute}),
+	)
+
+	err, _, _ := potato.SupplyProduct(20.2, 10, event_sourcing.WithOrderId("s0"), event_sourcing.WithParticipant("grower-1"))
+	suite.Require().Error(err)
+	suite.supply(potato, "s1", "grower-1", 20, 10)
+	suite.supply(potato, "s2", "grower-1", 21, 10)
+	suite.supply(potato, "s3", "grower-1", 25, 10)
+	suite.demand(potato, "d1", "buyer-1", 30, 30)
+	suite.Require().True(potato.IsHalted())
+	suite.repo.Save(potato)
+
+	s0, ok := orders.ById("s0")
+	suite.Require().True(ok)
+	suite.Assert().Equal(constants.RejectedOrderStatus, s0.Status)
+	suite.Assert().Equal("price 20.2 violates tick size 0.5", s0.Reason)
+	suite.Assert().Equal("potato", s0.Product)
+
+	d1, _ := orders.ById("d1")
+	suite.Assert().Equal(constants.ExpiredOrderStatus, d1.Status)
+	suite.Assert().True(decimal.NewFromInt(20).Equal(d1.FilledQty))
+	suite.Assert().True(decimal.NewFromInt(10).Equal(d1.LeavesQty))
+	suite.Assert().True(decimal.NewFromFloat(20.5).Equal(d1.AvgPrice()))
+
+	s3, _ := orders.ById("s3")
+	suite.Assert().Equal(constants.NewOrderStatus, s3.Status)
+
+	suite.Require().NoError(suite.manager.Rebuild(projection.OrdersName))
+	rebuilt, _ := orders.ById("d1")
+	suite.Assert().Equal(d1, rebuilt)
+}
@@ -0,0 +1,67 @@
+package projection
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"sync"
+)
+
+const TradeHistoryName = "trade_history"
+
+// TradeHistory keeps every executed trade in the order it was recorded.
+type TradeHistory struct {
+	mtx    sync.RWMutex
+	trades []event_sourcing.Trade
+}
+
+func NewTradeHistory() *TradeHistory {
+	return &TradeHistory{}
+}
+
+func (th *TradeHistory) Name() string {
+	return TradeHistoryName
+}
+
+func (th *TradeHistory) Handlers() map[string]Handler {
+	return map[string]Handler{
+		constants.TradeEventType: th.add,
+	}
+}
+
+func (th *TradeHistory) Reset() {
+	th.mtx.Lock()
+	defer th.mtx.Unlock()
+
+	th.trades = nil
+}
+
+// Trades returns the recorded trades, narrowed to a product and to trades a participant took either side
+// of. Empty filters match everything.
+func (th *TradeHistory) Trades(product, participant string) []event_sourcing.Trade {
+	th.mtx.RLock()
+	defer th.mtx.RUnlock()
+
+	result := make([]event_sourcing.Trade, 0)
+	for _, t := range th.trades {
+		if product != "" && t.Product != product {
+			continue
+		}
+		if participant != "" && t.SupplyParticipant != participant && t.DemandParticipant != participant {
+			continue
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+func (th *TradeHistory) add(record event_sourcing.Record) {
+	te, ok := record.Event.(event_sourcing.TradeEvent)
+	if !ok {
+		return
+	}
+
+	th.mtx.Lock()
+	defer th.mtx.Unlock()
+
+	th.trades = append(th.trades, te.Trade())
+}
@@ -0,0 +1,232 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/current_state"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/settlement"
+	"github.com/shopspring/decimal"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DateLayout = "2006-01-02"
+
+// Store is the part of the event store reports are built from.
+type Store interface {
+	ReadAll(after uint64) []event_sourcing.Record
+	StateAt(id string, name string, version int) (*current_state.CurrentState, error)
+	VersionAt(id string, at time.Time) int
+}
+
+type Trade struct {
+	Sequence          uint64          `json:"sequence"`
+	TradeId           string          `json:"trade_id"`
+	Product           string          `json:"product"`
+	SupplyOrderId     string          `json:"supply_order_id"`
+	SupplyParticipant string          `json:"supply_participant"`
+	DemandOrderId     string          `json:"demand_order_id"`
+	DemandParticipant string          `json:"demand_participant"`
+	Price             decimal.Decimal `json:"price"`
+	Qty               decimal.Decimal `json:"qty"`
+	SupplyFee         decimal.Decimal `json:"supply_fee"`
+	DemandFee         decimal.Decimal `json:"demand_fee"`
+	ExecutedAt        time.Time       `json:"executed_at"`
+}
+
+type OpenOrder struct {
+	Product     string          `json:"product"`
+	Side        string          `json:"side"`
+	OrderId     string          `json:"order_id"`
+	Participant string          `json:"participant"`
+	Price       decimal.Decimal `json:"price"`
+	Qty         decimal.Decimal `json:"qty"`
+	PlacedAt    time.Time       `json:"placed_at"`
+}
+
+// Position is what a participant bought and sold of a product during the day, and their net position in it
+// at the end of the day across all days so far.
+type Position struct {
+	Participant string          `json:"participant"`
+	Product     string          `json:"product"`
+	Bought      decimal.Decimal `json:"bought"`
+	Sold        decimal.Decimal `json:"sold"`
+	Net         decimal.Decimal `json:"net"`
+	Closing     decimal.Decimal `json:"closing"`
+}
+
+// Report is the end of day report of the UTC day starting at Date.
+type Report struct {
+	Date       string      `json:"date"`
+	Trades     []Trade     `json:"trades"`
+	OpenOrders []OpenOrder `json:"open_orders"`
+	Positions  []Position  `json:"positions"`
+}
+
+type positionKey struct {
+	participant string
+	product     string
+}
+
+// Build reports on the UTC day holding day. It only reads the event store, so the report of a past day
+// comes out the same whenever it is generated.
+func Build(store Store, day time.Time) (*Report, error) {
+	day = day.UTC()
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	r := &Report{Date: start.Format(DateLayout), Trades: make([]Trade, 0), OpenOrders: make([]OpenOrder, 0), Positions: make([]Position, 0)}
+
+	streams := make(map[string]string)
+	var streamOrder []string
+	positions := make(map[positionKey]*Position)
+	position := func(participant, product string) *Position {
+		if participant == "" {
+			participant = settlement.Unassigned
+		}
+		key := positionKey{participant: participant, product: product}
+		p, ok := positions[key]
+		if !ok {
+			p = &Position{Participant: participant, Product: product}
+			positions[key] = p
+		}
+		return p
+	}
+
+	for _, record := range store.ReadAll(0) {
+		if !record.Time.Before(end) {
+			break
+		}
+		if _, ok := streams[record.Stream]; !ok {
+			streams[record.Stream] = record.Event.Product()
+			streamOrder = append(streamOrder, record.Stream)
+		}
+
+		te, ok := record.Event.(event_sourcing.TradeEvent)
+		if !ok {
+			continue
+		}
+		t := te.Trade()
+
+		seller, buyer := position(t.SupplyParticipant, t.Product), position(t.DemandParticipant, t.Product)
+		seller.Closing = seller.Closing.Sub(t.Qty)
+		buyer.Closing = buyer.Closing.Add(t.Qty)
+
+		if record.Time.Before(start) {
+			continue
+		}
+
+		seller.Sold = seller.Sold.Add(t.Qty)
+		buyer.Bought = buyer.Bought.Add(t.Qty)
+		r.Trades = append(r.Trades, Trade{
+			Sequence:          record.Sequence,
+			TradeId:           t.Id,
+			Product:           t.Product,
+			SupplyOrderId:     t.SupplyOrderId,
+			SupplyParticipant: t.SupplyParticipant,
+			DemandOrderId:     t.DemandOrderId,
+			DemandParticipant: t.DemandParticipant,
+			Price:             t.Price,
+			Qty:               t.Qty,
+			SupplyFee:         t.SupplyFee.Amount,
+			DemandFee:         t.DemandFee.Amount,
+			ExecutedAt:        t.Timestamp.UTC(),
+		})
+	}
+
+	for _, id := range streamOrder {
+		state, err := store.StateAt(id, streams[id], store.VersionAt(id, end.Add(-time.Nanosecond)))
+		if err != nil {
+			return nil, err
+		}
+
+		demands, supplies := state.OrderBook.Get()
+		for _, o := range append(demands, supplies...) {
+			r.OpenOrders = append(r.OpenOrders, OpenOrder{
+				Product:     streams[id],
+				Side:        strings.ToLower(o.OrderType),
+				OrderId:     o.Id,
+				Participant: o.Participant,
+				Price:       o.Price,
+				Qty:         o.Qty,
+				PlacedAt:    time.Unix(0, o.Timestamp).UTC(),
+			})
+		}
+	}
+
+	for _, p := range positions {
+		p.Net = p.Bought.Sub(p.Sold)
+		r.Positions = append(r.Positions, *p)
+	}
+	sort.Slice(r.Positions, func(i, j int) bool {
+		if r.Positions[i].Participant != r.Positions[j].Participant {
+			return r.Positions[i].Participant < r.Positions[j].Participant
+		}
+		return r.Positions[i].Product < r.Positions[j].Product
+	})
+
+	return r, nil
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteBlotterCSV writes the trades of the day in the order they were recorded.
+func (r *Report) WriteBlotterCSV(w io.Writer) error {
+	rows := make([][]string, 0, len(r.Trades))
+	for _, t := range r.Trades {
+		rows = append(rows, []string{
+			strconv.FormatUint(t.Sequence, 10),
+			t.TradeId,
+			t.Product,
+			t.SupplyOrderId,
+			t.SupplyParticipant,
+			t.DemandOrderId,
+			t.DemandParticipant,
+			t.Price.String(),
+			t.Qty.String(),
+			t.SupplyFee.String(),
+			t.DemandFee.String(),
+			t.ExecutedAt.Format(time.RFC3339Nano),
+		})
+	}
+	return writeCSV(w, []string{"sequence", "trade_id", "product", "supply_order_id", "supply_participant", "demand_order_id", "demand_participant", "price", "qty", "supply_fee", "demand_fee", "executed_at"}, rows)
+}
+
+// WriteOpenOrdersCSV writes the orders resting in every book at the end of the day, in book order.
+func (r *Report) WriteOpenOrdersCSV(w io.Writer) error {
+	rows := make([][]string, 0, len(r.OpenOrders))
+	for _, o := range r.OpenOrders {
+		rows = append(rows, []string{o.Product, o.Side, o.OrderId, o.Participant, o.Price.String(), o.Qty.String(), o.PlacedAt.Format(time.RFC3339Nano)})
+	}
+	return writeCSV(w, []string{"product", "side", "order_id", "participant", "price", "qty", "placed_at"}, rows)
+}
+
+func (r *Report) WritePositionsCSV(w io.Writer) error {
+	rows := make([][]string, 0, len(r.Positions))
+	for _, p := range r.Positions {
+		rows = append(rows, []string{p.Participant, p.Product, p.Bought.String(), p.Sold.String(), p.Net.String(), p.Closing.String()})
+	}
+	return writeCSV(w, []string{"participant", "product", "bought", "sold", "net", "closing"}, rows)
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
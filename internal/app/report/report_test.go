@@ -0,0 +1,142 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/product"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/report"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/repository"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+	"time"
+)
+
+type reportSuite struct {
+	suite.Suite
+	repo   *repository.LedgerRepository
+	tomato *product.Product
+	clock  time.Time
+}
+
+func TestReportSuite(t *testing.T) {
+	suite.Run(t, new(reportSuite))
+}
+
+func (suite *reportSuite) SetupTest() {
+	suite.clock = time.Date(2023, 3, 1, 9, 45, 0, 0, time.UTC)
+	suite.repo = repository.NewWarehouseRepository(repository.WithClock(func() time.Time { return suite.clock }))
+	suite.tomato = suite.repo.Get("tomato-stream", "tomato")
+
+	suite.place(true, "s1", "grower-1", 20, 100)
+	suite.place(false, "d1", "buyer-1", 22, 30)
+	suite.place(false, "d2", "buyer-2", 18, 10)
+
+	suite.clock = time.Date(2023, 3, 2, 10, 0, 0, 0, time.UTC)
+	suite.place(false, "d3", "buyer-1", 21, 50)
+	suite.place(true, "s2", "grower-2", 25, 5)
+	suite.Require().NoError(suite.tomato.CancelOrder("d2"))
+	suite.Require().NoError(suite.repo.Save(suite.tomato))
+}
+
+func (suite *reportSuite) place(supply bool, id, participant string, price, qty float64) {
+	opts := []event_sourcing.OrderOption{event_sourcing.WithOrderId(id), event_sourcing.WithParticipant(participant)}
+
+	place := suite.tomato.DemandProduct
+	if supply {
+		place = suite.tomato.SupplyProduct
+	}
+
+	err, demands, supplies := place(price, qty, opts...)
+	suite.Require().NoError(err)
+	for i := range supplies {
+		suite.Require().NoError(suite.tomato.TradeProduct(supplies[i], demands[i]))
+	}
+	suite.Require().NoError(suite.repo.Save(suite.tomato))
+}
+
+func (suite *reportSuite) TestReportsTheTradesOrdersAndPositionsOfADay() {
+	r, err := report.Build(suite.repo, time.Date(2023, 3, 1, 23, 0, 0, 0, time.UTC))
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal("2023-03-01", r.Date)
+	suite.Require().Len(r.Trades, 1)
+	suite.Assert().Equal("d1", r.Trades[0].DemandOrderId)
+
+	suite.Require().Len(r.OpenOrders, 2)
+	suite.Assert().Equal("d2", r.OpenOrders[0].OrderId)
+	suite.Assert().Equal("s1", r.OpenOrders[1].OrderId)
+	suite.Assert().Equal("70", r.OpenOrders[1].Qty.String())
+
+	suite.Require().Len(r.Positions, 2)
+	suite.Assert().Equal("buyer-1", r.Positions[0].Participant)
+	suite.Assert().Equal("30", r.Positions[0].Closing.String())
+	suite.Assert().Equal("grower-1", r.Positions[1].Participant)
+	suite.Assert().Equal("-30", r.Positions[1].Net.String())
+}
+
+func (suite *reportSuite) TestCarriesClosingPositionsIntoLaterDays() {
+	r, err := report.Build(suite.repo, time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC))
+	suite.Require().NoError(err)
+
+	suite.Require().Len(r.Trades, 1)
+	suite.Assert().Equal("d3", r.Trades[0].DemandOrderId)
+	suite.Assert().Equal("50", r.Trades[0].Qty.String())
+
+	suite.Require().Len(r.OpenOrders, 2, "d2 was cancelled")
+	suite.Assert().Equal("s1", r.OpenOrders[0].OrderId)
+	suite.Assert().Equal("20", r.OpenOrders[0].Qty.String())
+	suite.Assert().Equal("s2", r.OpenOrders[1].OrderId)
+
+	suite.Require().Len(r.Positions, 2)
+	buyer := r.Positions[0]
+	suite.Assert().Equal("buyer-1", buyer.Participant)
+	suite.Assert().Equal("50", buyer.Bought.String())
+	suite.Assert().Equal("80", buyer.Closing.String())
+
+	grower := r.Positions[1]
+	suite.Assert().Equal("grower-1", grower.Participant)
+	suite.Assert().Equal("-50", grower.Net.String())
+	suite.Assert().Equal("-80", grower.Closing.String())
+}
+
+func (suite *reportSuite) TestRegeneratesTheSameReport() {
+	day := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	first, err := report.Build(suite.repo, day)
+	suite.Require().NoError(err)
+
+	suite.clock = time.Date(2023, 3, 3, 10, 0, 0, 0, time.UTC)
+	suite.place(false, "d4", "buyer-3", 25, 20)
+
+	second, err := report.Build(suite.repo, day)
+	suite.Require().NoError(err)
+
+	var a, b bytes.Buffer
+	suite.Require().NoError(first.WriteJSON(&a))
+	suite.Require().NoError(second.WriteJSON(&b))
+	suite.Assert().JSONEq(a.String(), b.String())
+
+	var decoded map[string]json.RawMessage
+	suite.Require().NoError(json.Unmarshal(a.Bytes(), &decoded))
+	suite.Assert().Contains(decoded, "positions")
+}
+
+func (suite *reportSuite) TestWritesCSV() {
+	r, err := report.Build(suite.repo, time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC))
+	suite.Require().NoError(err)
+
+	var blotter, orders, positions bytes.Buffer
+	suite.Require().NoError(r.WriteBlotterCSV(&blotter))
+	suite.Require().NoError(r.WriteOpenOrdersCSV(&orders))
+	suite.Require().NoError(r.WritePositionsCSV(&positions))
+
+	lines := strings.Split(strings.TrimSpace(blotter.String()), "\n")
+	suite.Require().Len(lines, 2)
+	suite.Assert().True(strings.HasPrefix(lines[0], "sequence,trade_id,product"))
+	suite.Assert().Contains(lines[1], ",tomato,s1,grower-1,d1,buyer-1,20,30,0,0,")
+
+	suite.Assert().Equal("product,side,order_id,participant,price,qty,placed_at\n", strings.SplitAfter(orders.String(), "\n")[0])
+	suite.Assert().Contains(positions.String(), "buyer-1,tomato,30,0,30,30\n")
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"os"
+	"time"
+)
+
+// Journal is durable storage for the records of the repository.
+type Journal interface {
+	Load() ([]event_sourcing.Record, error)
+	Append(records []event_sourcing.Record) error
+}
+
+type journalEntry struct {
+	Sequence       uint64          `json:"sequence"`
+	Stream         string          `json:"stream"`
+	Version        int             `json:"version"`
+	Time           time.Time       `json:"time"`
+	Event          json.RawMessage `json:"event"`
+	PrevHash       string          `json:"prev_hash"`
+	Hash           string          `json:"hash"`
+	StreamPrevHash string          `json:"stream_prev_hash"`
+	StreamHash     string          `json:"stream_hash"`
+}
+
+// FileJournal stores records as JSON lines in a single append only file.
+type FileJournal struct {
+	path string
+}
+
+func NewFileJournal(path string) *FileJournal {
+	return &FileJournal{path: path}
+}
+
+// Load reads every record of the journal. A missing file is an empty journal.
+func (fj *FileJournal) Load() ([]event_sourcing.Record, error) {
+	f, err := os.Open(fj.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := make([]event_sourcing.Record, 0)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+
+		var entry journalEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", fj.path, lineNo, err)
+		}
+
+		ev, err := event_sourcing.Unmarshal(entry.Event)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", fj.path, lineNo, err)
+		}
+
+		records = append(records, event_sourcing.Record{
+			Stream:         entry.Stream,
+			Version:        entry.Version,
+			Sequence:       entry.Sequence,
+			Time:           entry.Time,
+			Event:          ev,
+			PrevHash:       entry.PrevHash,
+			Hash:           entry.Hash,
+			StreamPrevHash: entry.StreamPrevHash,
+			StreamHash:     entry.StreamHash,
+		})
+	}
+
+	return records, scanner.Err()
+}
+
+func (fj *FileJournal) Append(records []event_sourcing.Record) error {
+	f, err := os.OpenFile(fj.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		ev, err := event_sourcing.Marshal(r.Event)
+		if err != nil {
+			return err
+		}
+
+		line, err := json.Marshal(journalEntry{
+			Sequence:       r.Sequence,
+			Stream:         r.Stream,
+			Version:        r.Version,
+			Time:           r.Time,
+			Event:          ev,
+			PrevHash:       r.PrevHash,
+			Hash:           r.Hash,
+			StreamPrevHash: r.StreamPrevHash,
+			StreamHash:     r.StreamHash,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err = w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
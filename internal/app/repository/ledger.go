@@ -1,30 +1,349 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/current_state"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/product"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/telemetry"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"log/slog"
+	"sync"
+	"time"
 )
 
+type Option func(wr *LedgerRepository)
+
+func WithProductOptions(name string, opts ...product.Option) Option {
+	return func(wr *LedgerRepository) {
+		wr.productOptions[name] = append(wr.productOptions[name], opts...)
+	}
+}
+
+func WithDefaultProductOptions(opts ...product.Option) Option {
+	return func(wr *LedgerRepository) {
+		wr.defaultOptions = append(wr.defaultOptions, opts...)
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(wr *LedgerRepository) {
+		wr.logger = logger
+	}
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(wr *LedgerRepository) {
+		wr.now = now
+	}
+}
+
+// WithJournal persists every appended record to j.
+func WithJournal(j Journal) Option {
+	return func(wr *LedgerRepository) {
+		wr.journal = j
+	}
+}
+
+// WithSnapshotEvery keeps a copy of a product's state whenever its stream grew by n events since the last
+// copy, so historical states are rebuilt from the closest copy instead of from the first event.
+func WithSnapshotEvery(n int) Option {
+	return func(wr *LedgerRepository) {
+		wr.snapshotEvery = n
+	}
+}
+
+func WithMetrics(metrics *telemetry.Metrics) Option {
+	return func(wr *LedgerRepository) {
+		wr.metrics = metrics
+	}
+}
+
+// LedgerRepository is the event store. Every product is a stream, and all streams share one log in append
+// order, so the interleaving of products is preserved.
 type LedgerRepository struct {
-	inMemoryLedger map[string][]event_sourcing.Event
+	mtx            sync.RWMutex
+	log            []event_sourcing.Record
+	inMemoryLedger map[string][]int
+	streams        map[string]string
+	snapshots      map[string][]snapshot
+	snapshotEvery  int
+	journal        Journal
+	subscribers    []func(records []event_sourcing.Record)
+	productOptions map[string][]product.Option
+	defaultOptions []product.Option
+	logger         *slog.Logger
+	metrics        *telemetry.Metrics
+	now            func() time.Time
+}
+
+func NewWarehouseRepository(opts ...Option) *LedgerRepository {
+	wr := &LedgerRepository{
+		inMemoryLedger: make(map[string][]int),
+		streams:        make(map[string]string),
+		snapshots:      make(map[string][]snapshot),
+		productOptions: make(map[string][]product.Option),
+		logger:         logging.Discard(),
+		metrics:        telemetry.Discard(),
+		now:            time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(wr)
+	}
+
+	return wr
 }
 
-func NewWarehouseRepository() *LedgerRepository {
-	return &LedgerRepository{inMemoryLedger: make(map[string][]event_sourcing.Event)}
+type snapshot struct {
+	version int
+	state   *current_state.CurrentState
 }
 
 func (wr *LedgerRepository) Get(id string, name string) *product.Product {
-	newProduct := product.NewProduct(id, name)
+	newProduct := wr.newProduct(id, name)
 
-	if events, ok := wr.inMemoryLedger[id]; ok {
+	if events := wr.Read(id, 0); len(events) > 0 {
 		for _, e := range events {
 			_, _, _ = newProduct.AddEvent(e)
 		}
+		wr.logger.Debug("product replayed", slog.String("product_id", id), slog.String("product", name), slog.Int("events", len(events)))
+
+		wr.mtx.Lock()
+		wr.snapshot(id, len(events), newProduct.GetCurrentState())
+		wr.mtx.Unlock()
 	}
 
 	return newProduct
 }
 
-func (wr *LedgerRepository) Save(product *product.Product) {
-	wr.inMemoryLedger[product.Id] = product.GetEvents()
+// StateAt rebuilds the state of a product as it was right after the event with the given version of its
+// stream was applied. Version 0 is the state before the first event.
+func (wr *LedgerRepository) StateAt(id string, name string, version int) (*current_state.CurrentState, error) {
+	wr.mtx.RLock()
+	streamLen := len(wr.inMemoryLedger[id])
+	var base *snapshot
+	for i := len(wr.snapshots[id]) - 1; i >= 0; i-- {
+		if wr.snapshots[id][i].version <= version {
+			base = &wr.snapshots[id][i]
+			break
+		}
+	}
+	wr.mtx.RUnlock()
+
+	if version < 0 || version > streamLen {
+		return nil, fmt.Errorf("version %d is outside of stream %s holding %d events", version, id, streamLen)
+	}
+
+	state, from := wr.newProduct(id, name).GetCurrentState(), 0
+	if base != nil {
+		state, from = base.state.Clone(), base.version
+	}
+
+	for _, record := range wr.ReadStream(id, from) {
+		if record.Version > version {
+			break
+		}
+		_, _, _ = record.Event.Apply(state)
+	}
+
+	wr.logger.Debug("state rebuilt", slog.String("product_id", id), slog.String("product", name), slog.Int("version", version), slog.Int("replayed", version-from))
+	return state, nil
+}
+
+// VersionAt returns the version of the last event appended to a stream at or before at.
+func (wr *LedgerRepository) VersionAt(id string, at time.Time) int {
+	version := 0
+	for _, record := range wr.ReadStream(id, 0) {
+		if record.Time.After(at) {
+			break
+		}
+		version = record.Version
+	}
+	return version
+}
+
+func (wr *LedgerRepository) newProduct(id string, name string) *product.Product {
+	opts, ok := wr.productOptions[name]
+	if !ok {
+		opts = wr.defaultOptions
+	}
+
+	return product.NewProduct(id, name, opts...)
+}
+
+func (wr *LedgerRepository) snapshot(id string, version int, state *current_state.CurrentState) {
+	if wr.snapshotEvery <= 0 {
+		return
+	}
+
+	last := 0
+	if snapshots := wr.snapshots[id]; len(snapshots) > 0 {
+		last = snapshots[len(snapshots)-1].version
+	}
+
+	if version-last >= wr.snapshotEvery {
+		wr.snapshots[id] = append(wr.snapshots[id], snapshot{version: version, state: state.Clone()})
+	}
+}
+
+// Save appends the events recorded by product since it was last saved. Records are sealed into the hash
+// chains and written to the journal before they become visible to readers.
+func (wr *LedgerRepository) Save(product *product.Product) error {
+	start := time.Now()
+	defer func() { wr.metrics.AppendLatency.Observe(time.Since(start).Seconds()) }()
+
+	events := product.GetEvents()
+
+	wr.mtx.Lock()
+	stream := wr.inMemoryLedger[product.Id]
+	head, streamHead := wr.head(product.Id)
+
+	appended := make([]event_sourcing.Record, 0, len(events)-len(stream))
+	for version := len(stream) + 1; version <= len(events); version++ {
+		record := event_sourcing.Record{
+			Stream:   product.Id,
+			Version:  version,
+			Sequence: uint64(len(wr.log) + len(appended) + 1),
+			Time:     wr.now(),
+			Event:    events[version-1],
+		}
+		if err := record.Seal(head, streamHead); err != nil {
+			wr.mtx.Unlock()
+			return err
+		}
+		head, streamHead = record.Hash, record.StreamHash
+		appended = append(appended, record)
+	}
+
+	if len(appended) > 0 && wr.journal != nil {
+		if err := wr.journal.Append(appended); err != nil {
+			wr.mtx.Unlock()
+			return err
+		}
+	}
+
+	wr.commit(appended)
+	wr.snapshot(product.Id, len(events), product.GetCurrentState())
+	subscribers := wr.subscribers
+	wr.mtx.Unlock()
+
+	wr.logger.Debug("product saved", slog.String("product_id", product.Id), slog.Int("events", len(events)), slog.Int("appended", len(appended)))
+
+	if len(appended) > 0 {
+		for _, notify := range subscribers {
+			notify(appended)
+		}
+	}
+
+	return nil
+}
+
+// Restore loads previously persisted records, typically read back from the journal, into an empty
+// repository. The records must form an unbroken chain.
+func (wr *LedgerRepository) Restore(records []event_sourcing.Record) error {
+	if err := event_sourcing.VerifyChain(records); err != nil {
+		return err
+	}
+
+	wr.mtx.Lock()
+	defer wr.mtx.Unlock()
+
+	if len(wr.log) > 0 {
+		return errors.New("repository already holds events")
+	}
+
+	wr.commit(records)
+	wr.logger.Info("event store restored", slog.Int("events", len(records)))
+	return nil
+}
+
+// Verify checks the hash chains of everything the repository holds.
+func (wr *LedgerRepository) Verify() error {
+	return event_sourcing.VerifyChain(wr.ReadAll(0))
+}
+
+// StreamOf returns the id of the stream holding the events of the named product.
+func (wr *LedgerRepository) StreamOf(name string) (string, bool) {
+	wr.mtx.RLock()
+	defer wr.mtx.RUnlock()
+
+	id, ok := wr.streams[name]
+	return id, ok
+}
+
+func (wr *LedgerRepository) head(streamId string) (string, string) {
+	var head, streamHead string
+	if len(wr.log) > 0 {
+		head = wr.log[len(wr.log)-1].Hash
+	}
+	if stream := wr.inMemoryLedger[streamId]; len(stream) > 0 {
+		streamHead = wr.log[stream[len(stream)-1]].StreamHash
+	}
+	return head, streamHead
+}
+
+func (wr *LedgerRepository) commit(records []event_sourcing.Record) {
+	for _, record := range records {
+		wr.log = append(wr.log, record)
+		wr.inMemoryLedger[record.Stream] = append(wr.inMemoryLedger[record.Stream], len(wr.log)-1)
+		if _, ok := wr.streams[record.Event.Product()]; !ok {
+			wr.streams[record.Event.Product()] = record.Stream
+		}
+	}
+}
+
+// Read returns the events of a stream starting at the zero based position from.
+func (wr *LedgerRepository) Read(streamId string, from int) []event_sourcing.Event {
+	records := wr.ReadStream(streamId, from)
+
+	events := make([]event_sourcing.Event, 0, len(records))
+	for _, r := range records {
+		events = append(events, r.Event)
+	}
+	return events
+}
+
+// ReadStream returns the records of a stream whose version is greater than after.
+func (wr *LedgerRepository) ReadStream(streamId string, after int) []event_sourcing.Record {
+	wr.mtx.RLock()
+	defer wr.mtx.RUnlock()
+
+	stream := wr.inMemoryLedger[streamId]
+	if after >= len(stream) {
+		return nil
+	}
+
+	records := make([]event_sourcing.Record, 0, len(stream)-after)
+	for _, i := range stream[after:] {
+		records = append(records, wr.log[i])
+	}
+	return records
+}
+
+// ReadAll returns the records of every stream whose sequence is greater than after, in append order.
+func (wr *LedgerRepository) ReadAll(after uint64) []event_sourcing.Record {
+	wr.mtx.RLock()
+	defer wr.mtx.RUnlock()
+
+	if after >= uint64(len(wr.log)) {
+		return nil
+	}
+	return append([]event_sourcing.Record(nil), wr.log[after:]...)
+}
+
+func (wr *LedgerRepository) LastSequence() uint64 {
+	wr.mtx.RLock()
+	defer wr.mtx.RUnlock()
+
+	return uint64(len(wr.log))
+}
+
+// Subscribe registers fn to receive the records appended by every Save.
+func (wr *LedgerRepository) Subscribe(fn func(records []event_sourcing.Record)) {
+	wr.mtx.Lock()
+	defer wr.mtx.Unlock()
+
+	wr.subscribers = append(wr.subscribers, fn)
 }
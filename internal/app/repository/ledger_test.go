@@ -1,16 +1,23 @@
 package repository_test
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/circuit_breaker"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/instrument"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/product"
 	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/repository"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"log/slog"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLedgerRepository_Scenario1(t *testing.T) {
@@ -68,19 +75,19 @@ func TestLedgerRepository_Scenario1(t *testing.T) {
 		event_sourcing.NewProductSupplyEvent(name, 24, 100),
 		event_sourcing.NewProductSupplyEvent(name, 20, 90),
 		event_sourcing.NewProductDemandEvent(name, 22, 110),
-		event_sourcing.NewTradeEvent(
+		event_sourcing.NewTradeEvent(name,
 			&order.Order{Price: decimal.NewFromFloat(20), Qty: decimal.NewFromFloat(90)},
 			&order.Order{Price: decimal.NewFromFloat(20), Qty: decimal.NewFromFloat(90)}),
 		event_sourcing.NewProductDemandEvent(name, 21, 10),
 		event_sourcing.NewProductDemandEvent(name, 21, 40),
 		event_sourcing.NewProductSupplyEvent(name, 19, 50),
-		event_sourcing.NewTradeEvent(
+		event_sourcing.NewTradeEvent(name,
 			&order.Order{Price: decimal.NewFromFloat(19), Qty: decimal.NewFromFloat(20)},
 			&order.Order{Price: decimal.NewFromFloat(19), Qty: decimal.NewFromFloat(20)}),
-		event_sourcing.NewTradeEvent(
+		event_sourcing.NewTradeEvent(name,
 			&order.Order{Price: decimal.NewFromFloat(19), Qty: decimal.NewFromFloat(10)},
 			&order.Order{Price: decimal.NewFromFloat(19), Qty: decimal.NewFromFloat(10)}),
-		event_sourcing.NewTradeEvent(
+		event_sourcing.NewTradeEvent(name,
 			&order.Order{Price: decimal.NewFromFloat(19), Qty: decimal.NewFromFloat(20)},
 			&order.Order{Price: decimal.NewFromFloat(19), Qty: decimal.NewFromFloat(20)}),
 	}
@@ -169,18 +176,18 @@ func TestLedgerRepository_Scenario2(t *testing.T) {
 	expectedEventsPotato := []event_sourcing.Event{
 		event_sourcing.NewProductDemandEvent(potatoName, 110, 10),
 		event_sourcing.NewProductSupplyEvent(potatoName, 110, 1),
-		event_sourcing.NewTradeEvent(
+		event_sourcing.NewTradeEvent(potatoName,
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(1)},
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(1)}),
 		event_sourcing.NewProductSupplyEvent(potatoName, 110, 7),
-		event_sourcing.NewTradeEvent(
+		event_sourcing.NewTradeEvent(potatoName,
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(7)},
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(7)}),
 		event_sourcing.NewProductSupplyEvent(potatoName, 110, 2),
-		event_sourcing.NewTradeEvent(
+		event_sourcing.NewTradeEvent(potatoName,
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(2)},
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(2)}),
-		event_sourcing.NewTradeEvent(
+		event_sourcing.NewTradeEvent(potatoName,
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(1)},
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(1)}),
 	}
@@ -189,7 +196,7 @@ func TestLedgerRepository_Scenario2(t *testing.T) {
 		event_sourcing.NewProductDemandEvent(tomatoName, 110, 1),
 		event_sourcing.NewProductDemandEvent(tomatoName, 110, 10),
 		event_sourcing.NewProductSupplyEvent(tomatoName, 110, 11),
-		event_sourcing.NewTradeEvent(
+		event_sourcing.NewTradeEvent(tomatoName,
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(11)},
 			&order.Order{Price: decimal.NewFromFloat(110), Qty: decimal.NewFromFloat(11)}),
 	}
@@ -211,6 +218,342 @@ func TestLedgerRepository_Scenario2(t *testing.T) {
 	}
 }
 
+func TestLedgerRepository_CircuitBreakerHaltsAndResumes(t *testing.T) {
+	id := uuid.New().String()
+	name := "tomato"
+	breakerConfig := circuit_breaker.Config{
+		MaxMovePercent: decimal.NewFromFloat(10),
+		Window:         time.Minute,
+		CoolOff:        time.Hour,
+	}
+
+	newProduct := product.NewProduct(id, name, product.WithCircuitBreaker(breakerConfig))
+
+	for _, price := range []float64{20, 21, 25} {
+		err, _, _ := newProduct.SupplyProduct(price, 10)
+		require.NoError(t, err)
+	}
+
+	err, matchDemand, matchSupply := newProduct.DemandProduct(30, 30)
+	require.NoError(t, err)
+	require.Len(t, matchDemand, 2)
+	require.Len(t, matchSupply, 2)
+	require.True(t, newProduct.IsHalted())
+
+	for i := 0; i < len(matchSupply); i++ {
+		err = newProduct.TradeProduct(matchSupply[i], matchDemand[i])
+		require.NoError(t, err)
+	}
+
+	demands, supplies := newProduct.GetCurrentState().OrderBook.Get()
+	assert.Empty(t, demands)
+	require.Len(t, supplies, 1)
+	assert.True(t, decimal.NewFromFloat(25).Equal(supplies[0].Price))
+
+	err, _, _ = newProduct.DemandProduct(30, 10)
+	require.Error(t, err)
+	assert.Equal(t, constants.ProductHaltedErrorMessage, err.Error())
+
+	require.NoError(t, newProduct.Resume())
+	require.False(t, newProduct.IsHalted())
+
+	err, matchDemand, matchSupply = newProduct.DemandProduct(30, 10)
+	require.NoError(t, err)
+	require.Len(t, matchSupply, 1)
+	assert.True(t, decimal.NewFromFloat(25).Equal(matchSupply[0].Price))
+
+	repo := repository.NewWarehouseRepository(repository.WithProductOptions(name, product.WithCircuitBreaker(breakerConfig)))
+	repo.Save(newProduct)
+
+	expectedEvents := []event_sourcing.Event{
+		event_sourcing.NewProductSupplyEvent(name, 20, 10),
+		event_sourcing.NewProductSupplyEvent(name, 21, 10),
+		event_sourcing.NewProductSupplyEvent(name, 25, 10),
+		event_sourcing.NewProductDemandEvent(name, 30, 30),
+		event_sourcing.NewProductHaltEvent(name, decimal.NewFromFloat(20), decimal.NewFromFloat(25), 0),
+		event_sourcing.NewProductExpireEvent(name, ""),
+		event_sourcing.NewTradeEvent(name, &order.Order{}, &order.Order{}),
+		event_sourcing.NewTradeEvent(name, &order.Order{}, &order.Order{}),
+		event_sourcing.NewProductRejectEvent(name, constants.DemandOrderType, 30, 10, constants.ProductHaltedErrorMessage),
+		event_sourcing.NewProductResumeEvent(name, false),
+		event_sourcing.NewProductDemandEvent(name, 30, 10),
+	}
+
+	actualProduct := repo.Get(id, name)
+	actualEvents := actualProduct.GetEvents()
+
+	require.Equal(t, len(expectedEvents), len(actualEvents))
+	for i := 0; i < len(expectedEvents); i++ {
+		assert.Equal(t, typeofobject(expectedEvents[i]), typeofobject(actualEvents[i]))
+	}
+
+	assert.False(t, actualProduct.IsHalted())
+	_, supplies = actualProduct.GetCurrentState().OrderBook.Get()
+	assert.Empty(t, supplies)
+}
+
+func TestLedgerRepository_CircuitBreakerResumesAfterCoolOff(t *testing.T) {
+	name := "potato"
+	newProduct := product.NewProduct(uuid.New().String(), name, product.WithCircuitBreaker(circuit_breaker.Config{
+		MaxMovePercent: decimal.NewFromFloat(10),
+		Window:         time.Minute,
+		CoolOff:        time.Nanosecond,
+	}))
+
+	for _, price := range []float64{20, 30} {
+		err, _, _ := newProduct.SupplyProduct(price, 10)
+		require.NoError(t, err)
+	}
+
+	err, _, _ := newProduct.DemandProduct(30, 20)
+	require.NoError(t, err)
+	require.True(t, newProduct.IsHalted())
+
+	err, _, matchSupply := newProduct.DemandProduct(30, 10)
+	require.NoError(t, err)
+	require.False(t, newProduct.IsHalted())
+	require.Len(t, matchSupply, 1)
+
+	events := newProduct.GetEvents()
+	assert.Equal(t, typeofobject(event_sourcing.NewProductResumeEvent(name, true)), typeofobject(events[len(events)-2]))
+}
+
+func TestLedgerRepository_InstrumentSpecRejectsUndeliverableOrders(t *testing.T) {
+	newProduct := product.NewProduct(uuid.New().String(), "tomato", product.WithInstrumentSpec(instrument.Spec{
+		TickSize: decimal.NewFromFloat(0.5),
+		LotSize:  decimal.NewFromFloat(1),
+		Rounding: instrument.RejectPolicy,
+	}))
+
+	err, _, _ := newProduct.SupplyProduct(20.0001, 10)
+	var violation *instrument.ViolationError
+	require.ErrorAs(t, err, &violation)
+
+	err, _, _ = newProduct.DemandProduct(20, 0.0003)
+	require.ErrorAs(t, err, &violation)
+
+	events := newProduct.GetEvents()
+	require.Len(t, events, 2, "rejections are recorded, nothing reached the book")
+	for _, ev := range events {
+		assert.Equal(t, constants.RejectEventType, ev.Type())
+	}
+	assert.Equal(t, "quantity 0.0003 violates lot size 1", events[1].(event_sourcing.RejectEvent).Reason())
+
+	err, _, _ = newProduct.SupplyProduct(20.5, 10)
+	require.NoError(t, err)
+	assert.Len(t, newProduct.GetEvents(), 3)
+}
+
+func TestLedgerRepository_LogsThroughInjectedLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	id := uuid.New().String()
+	newProduct := product.NewProduct(id, "tomato", product.WithLogger(logger))
+
+	err, _, _ := newProduct.SupplyProduct(24, 100, event_sourcing.WithOrderId("s1"))
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `msg="event recorded" event.type=supply`)
+	assert.Contains(t, buf.String(), "event.order_id=s1")
+
+	repo := repository.NewWarehouseRepository(repository.WithLogger(logger), repository.WithProductOptions("tomato", product.WithLogger(logger)))
+	repo.Save(newProduct)
+	assert.Contains(t, buf.String(), `msg="product saved" product_id=`+id)
+
+	buf.Reset()
+	_ = repo.Get(id, "tomato")
+	assert.Contains(t, buf.String(), `msg="product replayed"`)
+	assert.NotContains(t, buf.String(), "event recorded")
+}
+
+func TestLedgerRepository_CancelRemovesRestingOrder(t *testing.T) {
+	id := uuid.New().String()
+	name := "tomato"
+	newProduct := product.NewProduct(id, name)
+
+	err, _, _ := newProduct.SupplyProduct(24, 100, event_sourcing.WithOrderId("s1"))
+	require.NoError(t, err)
+	err, _, _ = newProduct.SupplyProduct(20, 90, event_sourcing.WithOrderId("s2"))
+	require.NoError(t, err)
+
+	require.NoError(t, newProduct.CancelOrder("s2"))
+	err = newProduct.CancelOrder("s2")
+	require.Error(t, err)
+	assert.Equal(t, constants.OrderNotFoundErrorMessage, err.Error())
+
+	repo := repository.NewWarehouseRepository()
+	repo.Save(newProduct)
+
+	actualProduct := repo.Get(id, name)
+	require.Len(t, actualProduct.GetEvents(), 3)
+	assert.Equal(t, typeofobject(event_sourcing.NewProductCancelEvent(name, "s2")), typeofobject(actualProduct.GetEvents()[2]))
+
+	_, supplies := actualProduct.GetCurrentState().OrderBook.Get()
+	require.Len(t, supplies, 1)
+	assert.Equal(t, "s1", supplies[0].Id)
+}
+
+func TestLedgerRepository_IdempotencyKeyReturnsOriginalOrder(t *testing.T) {
+	id := uuid.New().String()
+	name := "tomato"
+	newProduct := product.NewProduct(id, name)
+
+	err, _, _ := newProduct.SupplyProduct(20, 90, event_sourcing.WithOrderId("s1"), event_sourcing.WithParticipant("grower-1"))
+	require.NoError(t, err)
+	err, demands, supplies := newProduct.DemandProduct(22, 50, event_sourcing.WithOrderId("d1"), event_sourcing.WithParticipant("buyer-1"), event_sourcing.WithIdempotencyKey("k1"))
+	require.NoError(t, err)
+	require.NoError(t, newProduct.TradeProduct(supplies[0], demands[0]))
+
+	repo := repository.NewWarehouseRepository()
+	require.NoError(t, repo.Save(newProduct))
+
+	for _, p := range []*product.Product{newProduct, repo.Get(id, name)} {
+		err, _, _ = p.DemandProduct(22, 50, event_sourcing.WithParticipant("buyer-1"), event_sourcing.WithIdempotencyKey("k1"))
+		var duplicate *product.DuplicateOrderError
+		require.ErrorAs(t, err, &duplicate)
+		assert.Equal(t, "d1", duplicate.OrderId)
+		require.Len(t, duplicate.Supplies, 1)
+		assert.Equal(t, "s1", duplicate.Supplies[0].Id)
+		assert.True(t, decimal.NewFromInt(50).Equal(duplicate.Demands[0].Qty))
+
+		err, _, _ = p.DemandProduct(21, 50, event_sourcing.WithParticipant("buyer-1"), event_sourcing.WithIdempotencyKey("k1"))
+		require.Error(t, err)
+		assert.Equal(t, constants.IdempotencyKeyReusedErrorMessage, err.Error())
+
+		err, _, _ = p.DemandProduct(22, 10, event_sourcing.WithParticipant("buyer-2"), event_sourcing.WithIdempotencyKey("k1"))
+		require.NoError(t, err, "keys are scoped to their participant")
+		require.Len(t, p.GetEvents(), 5, "the reused key was rejected")
+	}
+}
+
+func TestLedgerRepository_SequencesEventsAcrossStreams(t *testing.T) {
+	clock := time.Date(2023, 3, 1, 9, 45, 0, 0, time.UTC)
+	repo := repository.NewWarehouseRepository(repository.WithClock(func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}))
+
+	var notified []uint64
+	repo.Subscribe(func(records []event_sourcing.Record) {
+		for _, r := range records {
+			notified = append(notified, r.Sequence)
+		}
+	})
+
+	tomato := repo.Get("tomato-id", "tomato")
+	potato := repo.Get("potato-id", "potato")
+
+	err, _, _ := tomato.SupplyProduct(24, 100)
+	require.NoError(t, err)
+	repo.Save(tomato)
+
+	err, _, _ = potato.SupplyProduct(10, 50)
+	require.NoError(t, err)
+	repo.Save(potato)
+
+	err, _, _ = tomato.SupplyProduct(20, 90)
+	require.NoError(t, err)
+	repo.Save(tomato)
+	repo.Save(tomato)
+
+	records := repo.ReadAll(0)
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"tomato-id", "potato-id", "tomato-id"}, []string{records[0].Stream, records[1].Stream, records[2].Stream})
+	assert.Equal(t, []int{1, 1, 2}, []int{records[0].Version, records[1].Version, records[2].Version})
+	assert.Equal(t, []uint64{1, 2, 3}, []uint64{records[0].Sequence, records[1].Sequence, records[2].Sequence})
+	assert.True(t, records[0].Time.Before(records[1].Time))
+	assert.Equal(t, uint64(3), repo.LastSequence())
+	assert.Equal(t, []uint64{1, 2, 3}, notified)
+
+	tail := repo.ReadAll(2)
+	require.Len(t, tail, 1)
+	assert.Equal(t, uint64(3), tail[0].Sequence)
+	assert.Empty(t, repo.ReadAll(3))
+
+	stream := repo.ReadStream("tomato-id", 1)
+	require.Len(t, stream, 1)
+	assert.Equal(t, 2, stream[0].Version)
+}
+
+func TestLedgerRepository_PersistsToFileJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+
+	repo := repository.NewWarehouseRepository(repository.WithJournal(repository.NewFileJournal(path)))
+	tomato := repo.Get("tomato-id", "tomato")
+
+	err, _, _ := tomato.SupplyProduct(20, 90, event_sourcing.WithOrderId("s1"))
+	require.NoError(t, err)
+	err, demands, supplies := tomato.DemandProduct(22, 50, event_sourcing.WithOrderId("d1"))
+	require.NoError(t, err)
+	require.NoError(t, tomato.TradeProduct(supplies[0], demands[0]))
+	require.NoError(t, repo.Save(tomato))
+	require.NoError(t, repo.Verify())
+
+	records, err := repository.NewFileJournal(path).Load()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, repo.ReadAll(0)[2].Hash, records[2].Hash)
+
+	restored := repository.NewWarehouseRepository()
+	require.NoError(t, restored.Restore(records))
+
+	id, ok := restored.StreamOf("tomato")
+	require.True(t, ok)
+	assert.Equal(t, "tomato-id", id)
+
+	_, resting := restored.Get(id, "tomato").GetCurrentState().OrderBook.Get()
+	require.Len(t, resting, 1)
+	assert.Equal(t, "s1", resting[0].Id)
+	assert.True(t, decimal.NewFromInt(40).Equal(resting[0].Qty))
+
+	records[1].Time = records[1].Time.Add(time.Second)
+	assert.Error(t, repository.NewWarehouseRepository().Restore(records))
+}
+
+func TestLedgerRepository_RebuildsStateAsOfVersionAndTime(t *testing.T) {
+	for _, snapshotEvery := range []int{0, 2} {
+		clock := time.Date(2023, 3, 1, 9, 45, 0, 0, time.UTC)
+		repo := repository.NewWarehouseRepository(
+			repository.WithSnapshotEvery(snapshotEvery),
+			repository.WithClock(func() time.Time {
+				clock = clock.Add(time.Minute)
+				return clock
+			}),
+		)
+
+		tomato := repo.Get("tomato-id", "tomato")
+		for i, price := range []float64{24, 20, 22} {
+			err, _, _ := tomato.SupplyProduct(price, 10, event_sourcing.WithOrderId(fmt.Sprintf("s%d", i+1)))
+			require.NoError(t, err)
+			require.NoError(t, repo.Save(tomato))
+		}
+		require.NoError(t, tomato.CancelOrder("s2"))
+		require.NoError(t, repo.Save(tomato))
+
+		for version, expected := range map[int][]string{0: {}, 1: {"s1"}, 2: {"s2", "s1"}, 3: {"s2", "s3", "s1"}, 4: {"s3", "s1"}} {
+			state, err := repo.StateAt("tomato-id", "tomato", version)
+			require.NoError(t, err)
+
+			_, supplies := state.OrderBook.Get()
+			ids := make([]string, 0)
+			for _, s := range supplies {
+				ids = append(ids, s.Id)
+			}
+			assert.Equal(t, expected, ids, "snapshot every %d, version %d", snapshotEvery, version)
+		}
+
+		_, err := repo.StateAt("tomato-id", "tomato", 5)
+		assert.Error(t, err)
+
+		assert.Equal(t, 0, repo.VersionAt("tomato-id", time.Date(2023, 3, 1, 9, 45, 30, 0, time.UTC)))
+		assert.Equal(t, 2, repo.VersionAt("tomato-id", time.Date(2023, 3, 1, 9, 47, 30, 0, time.UTC)))
+		assert.Equal(t, 4, repo.VersionAt("tomato-id", time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)))
+
+		_, supplies := tomato.GetCurrentState().OrderBook.Get()
+		assert.Len(t, supplies, 2, "rebuilding history must not touch the live book")
+	}
+}
+
 func typeofobject(x interface{}) string {
 	return fmt.Sprintf("%T", x)
 }
@@ -0,0 +1,231 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/api/ledgerpb"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/instrument"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/settlement"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+)
+
+type server struct {
+	ledgerpb.UnimplementedLedgerServer
+	ledger *app.App
+}
+
+// NewServer exposes the ledger over gRPC, see ledger.proto in api/ledgerpb for the service definition.
+func NewServer(ledger *app.App, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	ledgerpb.RegisterLedgerServer(s, &server{ledger: ledger})
+	return s
+}
+
+func (s *server) SubmitOrder(_ context.Context, req *ledgerpb.SubmitOrderRequest) (*ledgerpb.SubmitOrderResponse, error) {
+	cmd, err := toCommand(req)
+	if err != nil {
+		return nil, err
+	}
+
+	id, trades, err := s.ledger.Place(cmd)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ledgerpb.SubmitOrderResponse{OrderId: id, Trades: toTrades(trades)}, nil
+}
+
+func (s *server) CancelOrder(_ context.Context, req *ledgerpb.CancelOrderRequest) (*ledgerpb.CancelOrderResponse, error) {
+	if err := s.ledger.Cancel(req.GetProduct(), req.GetParticipant(), req.GetOrderId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &ledgerpb.CancelOrderResponse{}, nil
+}
+
+func (s *server) AmendOrder(_ context.Context, req *ledgerpb.AmendOrderRequest) (*ledgerpb.AmendOrderResponse, error) {
+	trades, err := s.ledger.Amend(req.GetProduct(), req.GetParticipant(), req.GetOrderId(), req.GetPrice(), req.GetQty())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ledgerpb.AmendOrderResponse{Trades: toTrades(trades)}, nil
+}
+
+func (s *server) GetBook(_ context.Context, req *ledgerpb.GetBookRequest) (*ledgerpb.GetBookResponse, error) {
+	demands, supplies, err := s.ledger.Book(req.GetProduct())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &ledgerpb.GetBookResponse{Product: req.GetProduct(), Demands: toOrders(demands), Supplies: toOrders(supplies)}, nil
+}
+
+func (s *server) GetEvents(_ context.Context, req *ledgerpb.GetEventsRequest) (*ledgerpb.GetEventsResponse, error) {
+	records := s.ledger.Events(req.GetAfter(), int(req.GetLimit()))
+
+	resp := &ledgerpb.GetEventsResponse{Events: make([]*ledgerpb.Event, 0, len(records))}
+	for _, rec := range records {
+		data, err := event_sourcing.Marshal(rec.Event)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		resp.Events = append(resp.Events, &ledgerpb.Event{
+			Sequence: rec.Sequence,
+			Stream:   rec.Stream,
+			Version:  int32(rec.Version),
+			Time:     timestamppb.New(rec.Time),
+			Type:     rec.Event.Type(),
+			Product:  rec.Event.Product(),
+			Data:     data,
+		})
+	}
+	return resp, nil
+}
+
+// OrderEntry places the orders of the stream one at a time, so that acks and fills are sent in the order
+// the orders were received. A rejected order does not end the stream.
+func (s *server) OrderEntry(stream ledgerpb.Ledger_OrderEntryServer) error {
+	for request := uint64(1); ; request++ {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, resp := range s.enter(request, req) {
+			if err = stream.Send(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *server) enter(request uint64, req *ledgerpb.SubmitOrderRequest) []*ledgerpb.OrderEntryResponse {
+	reject := func(err error) []*ledgerpb.OrderEntryResponse {
+		return []*ledgerpb.OrderEntryResponse{{
+			Request: request,
+			Result:  &ledgerpb.OrderEntryResponse_Reject{Reject: &ledgerpb.Reject{Error: status.Convert(err).Message()}},
+		}}
+	}
+
+	cmd, err := toCommand(req)
+	if err != nil {
+		return reject(err)
+	}
+
+	id, trades, err := s.ledger.Place(cmd)
+	if err != nil {
+		return reject(err)
+	}
+
+	resp := []*ledgerpb.OrderEntryResponse{{
+		Request: request,
+		Result:  &ledgerpb.OrderEntryResponse_Ack{Ack: &ledgerpb.Ack{OrderId: id}},
+	}}
+	for i, t := range toTrades(trades) {
+		resp = append(resp, &ledgerpb.OrderEntryResponse{
+			Request: request,
+			Result:  &ledgerpb.OrderEntryResponse_Fill{Fill: &ledgerpb.Fill{OrderId: filled(id, trades[i]), Trade: t}},
+		})
+	}
+	return resp
+}
+
+// filled returns the order a trade of a placement fills: the order placed or, for a trade of a stop order it
+// triggered, the stop order, which arrived in the book after the order it traded with.
+func filled(id string, t app.Trade) string {
+	switch {
+	case t.Demand.Id == id || t.Supply.Id == id:
+		return id
+	case t.Supply.Timestamp > t.Demand.Timestamp:
+		return t.Supply.Id
+	default:
+		return t.Demand.Id
+	}
+}
+
+func toCommand(req *ledgerpb.SubmitOrderRequest) (app.OrderCommand, error) {
+	var side string
+	switch req.GetSide() {
+	case ledgerpb.Side_SIDE_SUPPLY:
+		side = constants.SupplyOrderType
+	case ledgerpb.Side_SIDE_DEMAND:
+		side = constants.DemandOrderType
+	default:
+		return app.OrderCommand{}, status.Error(codes.InvalidArgument, "side is required")
+	}
+
+	return app.OrderCommand{
+		Id:             req.GetId(),
+		Participant:    req.GetParticipant(),
+		Product:        req.GetProduct(),
+		Side:           side,
+		Price:          req.GetPrice(),
+		Qty:            req.GetQty(),
+		IdempotencyKey: req.GetIdempotencyKey(),
+		Peak:           req.GetPeak(),
+		StopPrice:      req.GetStopPrice(),
+		MinQty:         req.GetMinQty(),
+		AllOrNone:      req.GetAllOrNone(),
+	}, nil
+}
+
+func toSide(orderType string) ledgerpb.Side {
+	switch orderType {
+	case constants.SupplyOrderType:
+		return ledgerpb.Side_SIDE_SUPPLY
+	case constants.DemandOrderType:
+		return ledgerpb.Side_SIDE_DEMAND
+	default:
+		return ledgerpb.Side_SIDE_UNSPECIFIED
+	}
+}
+
+func toOrder(o *order.Order) *ledgerpb.Order {
+	return &ledgerpb.Order{Id: o.Id, Participant: o.Participant, Side: toSide(o.OrderType), Price: o.Price.String(), Qty: o.Qty.String()}
+}
+
+func toOrders(orders []*order.Order) []*ledgerpb.Order {
+	result := make([]*ledgerpb.Order, 0, len(orders))
+	for _, o := range orders {
+		result = append(result, toOrder(o))
+	}
+	return result
+}
+
+func toTrades(trades []app.Trade) []*ledgerpb.Trade {
+	result := make([]*ledgerpb.Trade, 0, len(trades))
+	for _, t := range trades {
+		result = append(result, &ledgerpb.Trade{Product: t.Product, Demand: toOrder(t.Demand), Supply: toOrder(t.Supply)})
+	}
+	return result
+}
+
+func toStatus(err error) error {
+	var violation *instrument.ViolationError
+	var insufficient *settlement.InsufficientBalanceError
+	switch {
+	case err.Error() == constants.OrderNotFoundErrorMessage:
+		return status.Error(codes.NotFound, err.Error())
+	case err.Error() == constants.IdempotencyKeyReusedErrorMessage:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case err.Error() == constants.OrderNotOwnedErrorMessage:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case err.Error() == constants.ProductHaltedErrorMessage, err.Error() == constants.StopOrderAmendErrorMessage, errors.As(err, &insufficient):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &violation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Unknown, err.Error())
+	}
+}
@@ -0,0 +1,182 @@
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/api/ledgerpb"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/config"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/rpc"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"testing"
+)
+
+// newClient serves a fresh ledger in process and returns a client connected to it.
+func newClient(t *testing.T) ledgerpb.LedgerClient {
+	cfg, err := config.Load("../../../configs", "")
+	require.NoError(t, err)
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1 << 20)
+	server := rpc.NewServer(ledger)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return ledgerpb.NewLedgerClient(conn)
+}
+
+func TestServer_SubmitsAmendsAndCancelsOrders(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+
+	supply, err := client.SubmitOrder(ctx, &ledgerpb.SubmitOrderRequest{Id: "s1", Participant: "grower-1", Product: "tomato", Side: ledgerpb.Side_SIDE_SUPPLY, Price: 20, Qty: 90})
+	require.NoError(t, err)
+	assert.Equal(t, "s1", supply.GetOrderId())
+	assert.Empty(t, supply.GetTrades())
+
+	demand, err := client.SubmitOrder(ctx, &ledgerpb.SubmitOrderRequest{Participant: "buyer-1", Product: "tomato", Side: ledgerpb.Side_SIDE_DEMAND, Price: 19, Qty: 30})
+	require.NoError(t, err)
+	require.NotEmpty(t, demand.GetOrderId(), "an id is generated")
+
+	_, err = client.AmendOrder(ctx, &ledgerpb.AmendOrderRequest{Product: "tomato", Participant: "grower-1", OrderId: demand.GetOrderId(), Price: 21, Qty: 40})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	amended, err := client.AmendOrder(ctx, &ledgerpb.AmendOrderRequest{Product: "tomato", Participant: "buyer-1", OrderId: demand.GetOrderId(), Price: 21, Qty: 40})
+	require.NoError(t, err)
+	require.Len(t, amended.GetTrades(), 1)
+	assert.Equal(t, "s1", amended.GetTrades()[0].GetSupply().GetId())
+	assert.Equal(t, demand.GetOrderId(), amended.GetTrades()[0].GetDemand().GetId())
+	assert.Equal(t, "40", amended.GetTrades()[0].GetSupply().GetQty())
+
+	book, err := client.GetBook(ctx, &ledgerpb.GetBookRequest{Product: "tomato"})
+	require.NoError(t, err)
+	assert.Empty(t, book.GetDemands())
+	require.Len(t, book.GetSupplies(), 1)
+	assert.Equal(t, "50", book.GetSupplies()[0].GetQty())
+	assert.Equal(t, ledgerpb.Side_SIDE_SUPPLY, book.GetSupplies()[0].GetSide())
+
+	_, err = client.CancelOrder(ctx, &ledgerpb.CancelOrderRequest{Product: "tomato", Participant: "buyer-1", OrderId: "s1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.CancelOrder(ctx, &ledgerpb.CancelOrderRequest{Product: "tomato", Participant: "grower-1", OrderId: "s1"})
+	require.NoError(t, err)
+
+	_, err = client.CancelOrder(ctx, &ledgerpb.CancelOrderRequest{Product: "tomato", Participant: "grower-1", OrderId: "s1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.SubmitOrder(ctx, &ledgerpb.SubmitOrderRequest{Product: "tomato", Price: 20, Qty: 90})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_PagesThroughEvents(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"s1", "s2", "s3"} {
+		_, err := client.SubmitOrder(ctx, &ledgerpb.SubmitOrderRequest{Id: id, Product: "tomato", Side: ledgerpb.Side_SIDE_SUPPLY, Price: 20, Qty: 90})
+		require.NoError(t, err)
+	}
+
+	page, err := client.GetEvents(ctx, &ledgerpb.GetEventsRequest{After: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.GetEvents(), 1)
+
+	event := page.GetEvents()[0]
+	assert.Equal(t, uint64(2), event.GetSequence())
+	assert.Equal(t, "tomato", event.GetProduct())
+	assert.NotZero(t, event.GetTime().AsTime())
+
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal(event.GetData(), &data))
+	assert.Equal(t, event.GetType(), data["type"])
+	assert.Equal(t, "s2", data["order_id"])
+}
+
+func TestServer_StreamsAcksAndFillsInOrder(t *testing.T) {
+	client := newClient(t)
+
+	stream, err := client.OrderEntry(context.Background())
+	require.NoError(t, err)
+
+	requests := []*ledgerpb.SubmitOrderRequest{
+		{Id: "s1", Product: "tomato", Side: ledgerpb.Side_SIDE_SUPPLY, Price: 20, Qty: 50},
+		{Id: "s2", Product: "tomato", Side: ledgerpb.Side_SIDE_SUPPLY, Price: 21, Qty: 50},
+		{Id: "d1", Product: "tomato"},
+		{Id: "d2", Product: "tomato", Side: ledgerpb.Side_SIDE_DEMAND, Price: 22, Qty: 80},
+	}
+	assert.Equal(t, []string{
+		"ack s1",
+		"ack s2",
+		"reject side is required",
+		"ack d2",
+		"fill d2 s1 50",
+		"fill d2 s2 30",
+	}, enter(t, stream, requests))
+}
+
+func TestServer_StreamsTheFillsOfTriggeredStopsToTheStopOrders(t *testing.T) {
+	client := newClient(t)
+
+	stream, err := client.OrderEntry(context.Background())
+	require.NoError(t, err)
+
+	requests := []*ledgerpb.SubmitOrderRequest{
+		{Id: "s1", Product: "tomato", Side: ledgerpb.Side_SIDE_SUPPLY, Price: 20, Qty: 10},
+		{Id: "s2", Product: "tomato", Side: ledgerpb.Side_SIDE_SUPPLY, Price: 21, Qty: 5},
+		{Id: "stop", Product: "tomato", Side: ledgerpb.Side_SIDE_DEMAND, StopPrice: 20, Price: 25, Qty: 5},
+		{Id: "d1", Product: "tomato", Side: ledgerpb.Side_SIDE_DEMAND, Price: 20, Qty: 10},
+	}
+
+	assert.Equal(t, []string{
+		"ack s1",
+		"ack s2",
+		"ack stop",
+		"ack d1",
+		"fill d1 s1 10",
+		"fill stop s2 5",
+	}, enter(t, stream, requests))
+}
+
+// enter sends the requests on the order entry stream and describes every response it gets back.
+func enter(t *testing.T, stream ledgerpb.Ledger_OrderEntryClient, requests []*ledgerpb.SubmitOrderRequest) []string {
+	for _, req := range requests {
+		require.NoError(t, stream.Send(req))
+	}
+	require.NoError(t, stream.CloseSend())
+
+	var got []string
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return got
+		}
+		require.NoError(t, err)
+
+		switch r := resp.GetResult().(type) {
+		case *ledgerpb.OrderEntryResponse_Ack:
+			got = append(got, "ack "+r.Ack.GetOrderId())
+		case *ledgerpb.OrderEntryResponse_Fill:
+			got = append(got, "fill "+r.Fill.GetOrderId()+" "+r.Fill.GetTrade().GetSupply().GetId()+" "+r.Fill.GetTrade().GetSupply().GetQty())
+		case *ledgerpb.OrderEntryResponse_Reject:
+			got = append(got, "reject "+r.Reject.GetError())
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package settlement
+
+import (
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fees"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/order"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/shopspring/decimal"
+	"sort"
+	"sync"
+)
+
+const BalancesName = "balances"
+
+// Holding is the balance of an account. Reserved is held by resting orders and cannot back new ones.
+type Holding struct {
+	Account  Account
+	Total    decimal.Decimal
+	Reserved decimal.Decimal
+}
+
+func (h Holding) Available() decimal.Decimal {
+	return h.Total.Sub(h.Reserved)
+}
+
+type InsufficientBalanceError struct {
+	Account   Account
+	Required  decimal.Decimal
+	Available decimal.Decimal
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("insufficient balance: %s needs %s, %s available", e.Account.String(), e.Required.String(), e.Available.String())
+}
+
+type reservationKey struct {
+	streamId string
+	orderId  string
+}
+
+// reservation is what a resting order holds. Each unit of its remaining quantity holds perUnit of the
+// account, one for inventory and the limit price for cash. A demand also holds fee for the fees of its fills.
+type reservation struct {
+	account Account
+	perUnit decimal.Decimal
+	qty     decimal.Decimal
+	fee     decimal.Decimal
+}
+
+func (r reservation) amount() decimal.Decimal {
+	return r.perUnit.Mul(r.qty).Add(r.fee)
+}
+
+type Option func(b *Balances)
+
+// WithFees makes demand orders reserve the most they can be charged by the fee engine along with their cash.
+func WithFees(engine *fees.Engine) Option {
+	return func(b *Balances) {
+		b.fees = engine
+	}
+}
+
+// WithLots sets the smallest quantity each product trades in, which bounds how many fills a demand can be
+// charged for. A product without one trades in whole units.
+func WithLots(lots map[string]decimal.Decimal) Option {
+	return func(b *Balances) {
+		b.lots = lots
+	}
+}
+
+// Balances tracks the inventory and cash of every participant, starting from their opening balances. A
+// supply order reserves the inventory it offers and a demand order its quantity times its limit price in
+// cash, plus its fees. Fills turn the reservations into settled transfers, cancels release them.
+type Balances struct {
+	mtx          sync.RWMutex
+	opening      map[Account]decimal.Decimal
+	fees         *fees.Engine
+	lots         map[string]decimal.Decimal
+	totals       map[Account]decimal.Decimal
+	reserved     map[Account]decimal.Decimal
+	reservations map[reservationKey]*reservation
+}
+
+func NewBalances(opening map[Account]decimal.Decimal, opts ...Option) *Balances {
+	b := &Balances{opening: opening}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	b.Reset()
+	return b
+}
+
+func (b *Balances) Name() string {
+	return BalancesName
+}
+
+func (b *Balances) Handlers() map[string]projection.Handler {
+	return map[string]projection.Handler{
+		constants.SupplyEventType: b.reserve,
+		constants.DemandEventType: b.reserve,
+		constants.StopEventType:   b.reserve,
+		constants.TradeEventType:  b.fill,
+		constants.CancelEventType: b.cancel,
+		constants.ExpireEventType: b.cancel,
+	}
+}
+
+func (b *Balances) Reset() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.totals = make(map[Account]decimal.Decimal, len(b.opening))
+	for account, amount := range b.opening {
+		b.totals[account] = amount
+	}
+	b.reserved = make(map[Account]decimal.Decimal)
+	b.reservations = make(map[reservationKey]*reservation)
+}
+
+// Holdings returns the balances of a participant, or of every participant if participant is empty, ordered
+// by participant and asset.
+func (b *Balances) Holdings(participant string) []Holding {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	accounts := make(map[Account]bool)
+	for account := range b.totals {
+		accounts[account] = true
+	}
+	for account := range b.reserved {
+		accounts[account] = true
+	}
+
+	result := make([]Holding, 0, len(accounts))
+	for account := range accounts {
+		if participant == "" || account.Participant == participant {
+			result = append(result, Holding{Account: account, Total: b.totals[account], Reserved: b.reserved[account]})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Account.Participant != result[j].Account.Participant {
+			return result[i].Account.Participant < result[j].Account.Participant
+		}
+		return result[i].Account.Asset < result[j].Account.Asset
+	})
+	return result
+}
+
+// Check returns an InsufficientBalanceError if the participant of an order does not hold enough available
+// inventory or cash to back it.
+func (b *Balances) Check(product string, o order.Order) error {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	return b.check(b.reservationFor(product, o), decimal.Zero)
+}
+
+// CheckReplacement is Check for an order that replaces the resting order replacedId of stream, whose
+// reservation is released along with it.
+func (b *Balances) CheckReplacement(stream, product, replacedId string, o order.Order) error {
+	r := b.reservationFor(product, o)
+
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	released := decimal.Zero
+	if replaced, ok := b.reservations[reservationKey{streamId: stream, orderId: replacedId}]; ok && replaced.account == r.account {
+		released = replaced.amount()
+	}
+	return b.check(r, released)
+}
+
+func (b *Balances) check(r reservation, released decimal.Decimal) error {
+	required := r.amount()
+	available := b.totals[r.account].Sub(b.reserved[r.account]).Add(released)
+	if required.GreaterThan(available) {
+		return &InsufficientBalanceError{Account: r.account, Required: required, Available: available}
+	}
+	return nil
+}
+
+func (b *Balances) reserve(record event_sourcing.Record) {
+	placed, ok := record.Event.(event_sourcing.OrderEvent)
+	if !ok {
+		return
+	}
+	r := b.reservationFor(record.Event.Product(), placed.Order())
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.reservations[reservationKey{streamId: record.Stream, orderId: placed.Order().Id}] = &r
+	b.reserved[r.account] = b.reserved[r.account].Add(r.amount())
+}
+
+func (b *Balances) fill(record event_sourcing.Record) {
+	te, ok := record.Event.(event_sourcing.TradeEvent)
+	if !ok {
+		return
+	}
+	t := te.Trade()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	demand := reservationKey{streamId: record.Stream, orderId: t.DemandOrderId}
+	b.releaseFee(demand, t.DemandFee.Amount)
+	for _, key := range []reservationKey{{streamId: record.Stream, orderId: t.SupplyOrderId}, demand} {
+		b.release(key, t.Qty)
+	}
+
+	supplier, buyer := participantOf(t.SupplyParticipant), participantOf(t.DemandParticipant)
+	value := t.Price.Mul(t.Qty)
+	b.move(Account{Participant: supplier, Asset: t.Product}, Account{Participant: buyer, Asset: t.Product}, t.Qty)
+	b.move(Account{Participant: buyer, Asset: Cash}, Account{Participant: supplier, Asset: Cash}, value)
+	b.move(Account{Participant: supplier, Asset: Cash}, Account{Participant: Marketplace, Asset: Cash}, t.SupplyFee.Amount)
+	b.move(Account{Participant: buyer, Asset: Cash}, Account{Participant: Marketplace, Asset: Cash}, t.DemandFee.Amount)
+}
+
+func (b *Balances) cancel(record event_sourcing.Record) {
+	ce, ok := record.Event.(event_sourcing.CancelEvent)
+	if !ok {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.releaseAll(reservationKey{streamId: record.Stream, orderId: ce.OrderId()})
+}
+
+func (b *Balances) release(key reservationKey, qty decimal.Decimal) {
+	r, ok := b.reservations[key]
+	if !ok {
+		return
+	}
+
+	qty = decimal.Min(qty, r.qty)
+	r.qty = r.qty.Sub(qty)
+	b.reserved[r.account] = b.reserved[r.account].Sub(r.perUnit.Mul(qty))
+	if !r.qty.IsPositive() {
+		b.reserved[r.account] = b.reserved[r.account].Sub(r.fee)
+		delete(b.reservations, key)
+	}
+}
+
+// releaseFee releases the part of the fee reservation that a fill was charged.
+func (b *Balances) releaseFee(key reservationKey, fee decimal.Decimal) {
+	r, ok := b.reservations[key]
+	if !ok {
+		return
+	}
+
+	fee = decimal.Min(fee, r.fee)
+	r.fee = r.fee.Sub(fee)
+	b.reserved[r.account] = b.reserved[r.account].Sub(fee)
+}
+
+func (b *Balances) releaseAll(key reservationKey) {
+	if r, ok := b.reservations[key]; ok {
+		b.release(key, r.qty)
+	}
+}
+
+func (b *Balances) move(from, to Account, amount decimal.Decimal) {
+	if amount.IsZero() {
+		return
+	}
+
+	b.totals[from] = b.totals[from].Sub(amount)
+	b.totals[to] = b.totals[to].Add(amount)
+}
+
+// reservationFor reserves the cash of a demand at its price, and that of a stop demand becoming a market order
+// at its stop price. A demand also reserves the most it could be charged if it filled one lot at a time.
+func (b *Balances) reservationFor(product string, o order.Order) reservation {
+	participant := participantOf(o.Participant)
+	if o.OrderType != constants.DemandOrderType {
+		return reservation{account: Account{Participant: participant, Asset: product}, perUnit: decimal.NewFromInt(1), qty: o.Qty}
+	}
+
+	r := reservation{account: Account{Participant: participant, Asset: Cash}, perUnit: o.Price, qty: o.Qty}
+	if r.perUnit.IsZero() {
+		r.perUnit = o.StopPrice
+	}
+	if b.fees != nil {
+		if schedule, ok := b.fees.Schedule(product); ok {
+			lot, ok := b.lots[product]
+			if !ok || !lot.IsPositive() {
+				lot = decimal.NewFromInt(1)
+			}
+			fills := decimal.Max(r.qty.Div(lot).Ceil(), decimal.NewFromInt(1))
+			r.fee = schedule.Most(r.perUnit.Mul(r.qty), fills.IntPart())
+		}
+	}
+	return r
+}
@@ -0,0 +1,178 @@
+package settlement_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fees"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/product"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/repository"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/settlement"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type balancesSuite struct {
+	suite.Suite
+	repo     *repository.LedgerRepository
+	balances *settlement.Balances
+	tomato   *product.Product
+}
+
+func TestBalancesSuite(t *testing.T) {
+	suite.Run(t, new(balancesSuite))
+}
+
+func (suite *balancesSuite) SetupTest() {
+	suite.balances = settlement.NewBalances(map[settlement.Account]decimal.Decimal{
+		{Participant: "grower-1", Asset: "tomato"}:       decimal.NewFromInt(100),
+		{Participant: "buyer-1", Asset: settlement.Cash}: decimal.NewFromInt(1000),
+	})
+
+	suite.repo = repository.NewWarehouseRepository(repository.WithDefaultProductOptions(product.WithBalances(suite.balances)))
+	suite.Require().NoError(projection.NewManager(suite.repo).Register(suite.balances))
+	suite.tomato = suite.repo.Get("tomato-stream", "tomato")
+}
+
+func (suite *balancesSuite) place(supply bool, id, participant string, price, qty float64) error {
+	opts := []event_sourcing.OrderOption{event_sourcing.WithOrderId(id), event_sourcing.WithParticipant(participant)}
+
+	place := suite.tomato.DemandProduct
+	if supply {
+		place = suite.tomato.SupplyProduct
+	}
+
+	err, demands, supplies := place(price, qty, opts...)
+	if err != nil {
+		return err
+	}
+	for i := range supplies {
+		suite.Require().NoError(suite.tomato.TradeProduct(supplies[i], demands[i]))
+	}
+	return suite.repo.Save(suite.tomato)
+}
+
+func (suite *balancesSuite) holding(participant, asset string) settlement.Holding {
+	for _, h := range suite.balances.Holdings(participant) {
+		if h.Account.Asset == asset {
+			return h
+		}
+	}
+	return settlement.Holding{Account: settlement.Account{Participant: participant, Asset: asset}}
+}
+
+func (suite *balancesSuite) TestRejectsOrdersExceedingTheAvailableBalance() {
+	suite.Require().NoError(suite.place(true, "s1", "grower-1", 20, 60))
+
+	err := suite.place(true, "s2", "grower-1", 20, 50)
+	var insufficient *settlement.InsufficientBalanceError
+	suite.Require().True(errors.As(err, &insufficient))
+	suite.Assert().Equal("50", insufficient.Required.String())
+	suite.Assert().Equal("40", insufficient.Available.String())
+
+	err = suite.place(false, "d1", "buyer-1", 25, 50)
+	suite.Require().True(errors.As(err, &insufficient))
+	suite.Assert().Equal("cash:buyer-1", insufficient.Account.String())
+
+	err = suite.place(false, "d2", "buyer-2", 20, 1)
+	suite.Assert().Error(err)
+
+	_, supplies := suite.tomato.GetCurrentState().OrderBook.Get()
+	suite.Assert().Len(supplies, 1, "rejected orders never reach the book")
+}
+
+func (suite *balancesSuite) TestFillsSettleReservationsAndCancelsReleaseThem() {
+	suite.Require().NoError(suite.place(true, "s1", "grower-1", 20, 60))
+	suite.Assert().Equal("60", suite.holding("grower-1", "tomato").Reserved.String())
+
+	suite.Require().NoError(suite.place(false, "d1", "buyer-1", 22, 40))
+
+	grower := suite.holding("grower-1", "tomato")
+	suite.Assert().Equal("60", grower.Total.String())
+	suite.Assert().Equal("20", grower.Reserved.String())
+	suite.Assert().Equal("40", grower.Available().String())
+	suite.Assert().Equal("800", suite.holding("grower-1", settlement.Cash).Total.String())
+
+	buyer := suite.holding("buyer-1", settlement.Cash)
+	suite.Assert().Equal("200", buyer.Total.String())
+	suite.Assert().True(buyer.Reserved.IsZero(), "the price improvement is released with the fill")
+	suite.Assert().Equal("40", suite.holding("buyer-1", "tomato").Total.String())
+
+	suite.Require().NoError(suite.place(false, "d2", "buyer-1", 10, 15))
+	suite.Assert().Equal("150", suite.holding("buyer-1", settlement.Cash).Reserved.String())
+
+	suite.Require().NoError(suite.tomato.CancelOrder("d2"))
+	suite.Require().NoError(suite.repo.Save(suite.tomato))
+	suite.Assert().True(suite.holding("buyer-1", settlement.Cash).Reserved.IsZero())
+
+	suite.Require().NoError(suite.tomato.CancelOrder("s1"))
+	suite.Require().NoError(suite.repo.Save(suite.tomato))
+	suite.Assert().Equal("60", suite.holding("grower-1", "tomato").Available().String())
+}
+
+func (suite *balancesSuite) TestReservesTheFeesOfDemands() {
+	engine := fees.NewEngine(fees.WithDefaultSchedule(fees.Schedule{MakerRate: decimal.NewFromFloat(0.01), TakerRate: decimal.NewFromFloat(0.01), Fixed: decimal.NewFromInt(1)}))
+	suite.balances = settlement.NewBalances(map[settlement.Account]decimal.Decimal{
+		{Participant: "grower-1", Asset: "tomato"}:       decimal.NewFromInt(100),
+		{Participant: "buyer-1", Asset: settlement.Cash}: decimal.NewFromInt(1000),
+	}, settlement.WithFees(engine), settlement.WithLots(map[string]decimal.Decimal{"tomato": decimal.NewFromInt(5)}))
+	suite.repo = repository.NewWarehouseRepository(repository.WithDefaultProductOptions(product.WithBalances(suite.balances), product.WithFees(engine)))
+	suite.Require().NoError(projection.NewManager(suite.repo).Register(suite.balances))
+	suite.tomato = suite.repo.Get("tomato-stream", "tomato")
+
+	err := suite.place(false, "d1", "buyer-1", 99, 10)
+	var insufficient *settlement.InsufficientBalanceError
+	suite.Require().True(errors.As(err, &insufficient))
+	suite.Assert().Equal("1001.91", insufficient.Required.String(), "990 for the goods and 11.91 for the fees of two lots")
+
+	suite.Require().NoError(suite.place(false, "d2", "buyer-1", 98, 10))
+	suite.Assert().Equal("991.81", suite.holding("buyer-1", settlement.Cash).Reserved.String())
+
+	suite.Require().NoError(suite.place(true, "s1", "grower-1", 98, 10))
+	buyer := suite.holding("buyer-1", settlement.Cash)
+	suite.Assert().Equal("9.2", buyer.Total.String())
+	suite.Assert().True(buyer.Reserved.IsZero())
+}
+
+func (suite *balancesSuite) TestReservesTheFixedFeeOfEveryFill() {
+	engine := fees.NewEngine(fees.WithDefaultSchedule(fees.Schedule{Fixed: decimal.NewFromInt(1)}))
+	suite.balances = settlement.NewBalances(map[settlement.Account]decimal.Decimal{
+		{Participant: "grower-1", Asset: "tomato"}:       decimal.NewFromInt(100),
+		{Participant: "buyer-1", Asset: settlement.Cash}: decimal.RequireFromString("110.05"),
+	}, settlement.WithFees(engine))
+	suite.repo = repository.NewWarehouseRepository(repository.WithDefaultProductOptions(product.WithBalances(suite.balances), product.WithFees(engine)))
+	suite.Require().NoError(projection.NewManager(suite.repo).Register(suite.balances))
+	suite.tomato = suite.repo.Get("tomato-stream", "tomato")
+
+	suite.Require().NoError(suite.place(false, "d1", "buyer-1", 10, 10))
+	suite.Assert().Equal("110.05", suite.holding("buyer-1", settlement.Cash).Reserved.String(), "100 for the goods and the fixed fee of up to 10 fills")
+	var insufficient *settlement.InsufficientBalanceError
+	suite.Require().True(errors.As(suite.place(false, "d2", "buyer-1", 1, 1), &insufficient))
+
+	for i := 1; i <= 10; i++ {
+		suite.Require().NoError(suite.place(true, fmt.Sprintf("s%d", i), "grower-1", 10, 1))
+		buyer := suite.holding("buyer-1", settlement.Cash)
+		suite.Assert().False(buyer.Total.IsNegative())
+		suite.Assert().True(buyer.Total.GreaterThanOrEqual(buyer.Reserved))
+	}
+	suite.Assert().Equal("0.05", suite.holding("buyer-1", settlement.Cash).Total.String())
+}
+
+func (suite *balancesSuite) TestRebuildsReservationsFromTheEventStore() {
+	suite.Require().NoError(suite.place(true, "s1", "grower-1", 20, 60))
+	suite.Require().NoError(suite.place(false, "d1", "buyer-1", 22, 40))
+
+	before := suite.balances.Holdings("")
+	suite.balances.Reset()
+	suite.Assert().NotEqual(before, suite.balances.Holdings(""))
+
+	manager := projection.NewManager(suite.repo)
+	rebuilt := settlement.NewBalances(map[settlement.Account]decimal.Decimal{
+		{Participant: "grower-1", Asset: "tomato"}:       decimal.NewFromInt(100),
+		{Participant: "buyer-1", Asset: settlement.Cash}: decimal.NewFromInt(1000),
+	})
+	suite.Require().NoError(manager.Register(rebuilt))
+	suite.Assert().Equal(before, rebuilt.Holdings(""))
+}
@@ -0,0 +1,269 @@
+package settlement
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/shopspring/decimal"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ProjectionName = "settlement"
+
+	// Cash is the asset of the cash accounts. Every other asset is the name of a product, held as inventory.
+	Cash = "cash"
+
+	// Unassigned holds the accounts of orders that were submitted without a participant.
+	Unassigned = "unassigned"
+
+	// Marketplace holds the fees charged on trades.
+	Marketplace = "marketplace"
+)
+
+type Account struct {
+	Participant string `json:"participant"`
+	Asset       string `json:"asset"`
+}
+
+func (a Account) String() string {
+	if a.Asset == Cash {
+		return fmt.Sprintf("cash:%s", a.Participant)
+	}
+	return fmt.Sprintf("inventory:%s:%s", a.Participant, a.Asset)
+}
+
+// Line debits its account by a positive amount and credits it by a negative one.
+type Line struct {
+	Account Account
+	Amount  decimal.Decimal
+}
+
+type Entry struct {
+	Id          string
+	Sequence    uint64
+	Time        time.Time
+	Description string
+	Lines       []Line
+}
+
+// Validate checks that the lines of the entry sum to zero. Goods and cash are not interchangeable, so the
+// lines are summed per asset.
+func (e Entry) Validate() error {
+	sums := make(map[string]decimal.Decimal)
+	for _, l := range e.Lines {
+		sums[l.Account.Asset] = sums[l.Account.Asset].Add(l.Amount)
+	}
+
+	for asset, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("journal entry %s does not balance: %s lines sum to %s", e.Id, asset, sum.String())
+		}
+	}
+	return nil
+}
+
+type Balance struct {
+	Account Account
+	Debit   decimal.Decimal
+	Credit  decimal.Decimal
+}
+
+func (b Balance) Net() decimal.Decimal {
+	return b.Debit.Sub(b.Credit)
+}
+
+// Ledger posts a double-entry journal entry for every trade: the goods move from the inventory of the
+// supplier to the inventory of the buyer, the cash for them at the execution price from the buyer to the
+// supplier, and the fees of both sides to the marketplace. A trade whose entry does not balance is not posted,
+// the error is kept and reported by Verify.
+type Ledger struct {
+	mtx      sync.RWMutex
+	entries  []Entry
+	balances map[Account]*Balance
+	failed   error
+}
+
+func NewLedger() *Ledger {
+	l := &Ledger{}
+	l.Reset()
+	return l
+}
+
+func (l *Ledger) Name() string {
+	return ProjectionName
+}
+
+func (l *Ledger) Handlers() map[string]projection.Handler {
+	return map[string]projection.Handler{
+		constants.TradeEventType: l.settle,
+	}
+}
+
+func (l *Ledger) Reset() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.entries = nil
+	l.balances = make(map[Account]*Balance)
+	l.failed = nil
+}
+
+// Post appends an entry to the journal, unless it does not balance.
+func (l *Ledger) Post(entry Entry) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.post(entry)
+}
+
+// Entries returns the journal entries that touch an account of the participant, or all of them if
+// participant is empty.
+func (l *Ledger) Entries(participant string) []Entry {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	result := make([]Entry, 0)
+	for _, e := range l.entries {
+		for _, line := range e.Lines {
+			if participant == "" || line.Account.Participant == participant {
+				result = append(result, e)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// TrialBalance returns the balance of every account that was posted to, ordered by participant and asset.
+func (l *Ledger) TrialBalance() []Balance {
+	return l.Balances("")
+}
+
+// Balances returns the balances of the accounts of a participant, or of every account if participant is
+// empty.
+func (l *Ledger) Balances(participant string) []Balance {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	result := make([]Balance, 0, len(l.balances))
+	for _, b := range l.balances {
+		if participant == "" || b.Account.Participant == participant {
+			result = append(result, *b)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Account.Participant != result[j].Account.Participant {
+			return result[i].Account.Participant < result[j].Account.Participant
+		}
+		return result[i].Account.Asset < result[j].Account.Asset
+	})
+	return result
+}
+
+// Verify checks that every trade was posted, that every journal entry balances and that, per asset, the
+// debits and credits of the trial balance add up to the same total.
+func (l *Ledger) Verify() error {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	if l.failed != nil {
+		return l.failed
+	}
+
+	for _, e := range l.entries {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+
+	debits, credits := make(map[string]decimal.Decimal), make(map[string]decimal.Decimal)
+	for _, b := range l.balances {
+		debits[b.Account.Asset] = debits[b.Account.Asset].Add(b.Debit)
+		credits[b.Account.Asset] = credits[b.Account.Asset].Add(b.Credit)
+	}
+	for asset, debit := range debits {
+		if !debit.Equal(credits[asset]) {
+			return fmt.Errorf("trial balance does not balance: %s debits %s, credits %s", asset, debit.String(), credits[asset].String())
+		}
+	}
+	return nil
+}
+
+func (l *Ledger) settle(record event_sourcing.Record) {
+	te, ok := record.Event.(event_sourcing.TradeEvent)
+	if !ok {
+		return
+	}
+	t := te.Trade()
+
+	supplier, buyer := participantOf(t.SupplyParticipant), participantOf(t.DemandParticipant)
+	value := t.Price.Mul(t.Qty)
+
+	lines := []Line{
+		{Account: Account{Participant: buyer, Asset: t.Product}, Amount: t.Qty},
+		{Account: Account{Participant: supplier, Asset: t.Product}, Amount: t.Qty.Neg()},
+		{Account: Account{Participant: supplier, Asset: Cash}, Amount: value},
+		{Account: Account{Participant: buyer, Asset: Cash}, Amount: value.Neg()},
+	}
+	for _, charged := range []struct {
+		participant string
+		fee         decimal.Decimal
+	}{{supplier, t.SupplyFee.Amount}, {buyer, t.DemandFee.Amount}} {
+		if charged.fee.IsPositive() {
+			lines = append(lines,
+				Line{Account: Account{Participant: Marketplace, Asset: Cash}, Amount: charged.fee},
+				Line{Account: Account{Participant: charged.participant, Asset: Cash}, Amount: charged.fee.Neg()},
+			)
+		}
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	err := l.post(Entry{
+		Id:          t.Id,
+		Sequence:    record.Sequence,
+		Time:        t.Timestamp,
+		Description: fmt.Sprintf("%s: %s sold %s to %s at %s", t.Product, t.SupplyOrderId, t.Qty.String(), t.DemandOrderId, t.Price.String()),
+		Lines:       lines,
+	})
+	if err != nil {
+		l.failed = multierror.Append(l.failed, err)
+	}
+}
+
+func (l *Ledger) post(entry Entry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	l.entries = append(l.entries, entry)
+
+	for _, line := range entry.Lines {
+		b, ok := l.balances[line.Account]
+		if !ok {
+			b = &Balance{Account: line.Account}
+			l.balances[line.Account] = b
+		}
+
+		if line.Amount.IsPositive() {
+			b.Debit = b.Debit.Add(line.Amount)
+		} else {
+			b.Credit = b.Credit.Sub(line.Amount)
+		}
+	}
+	return nil
+}
+
+func participantOf(participant string) string {
+	if participant == "" {
+		return Unassigned
+	}
+	return participant
+}
@@ -0,0 +1,142 @@
+package settlement_test
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/fees"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/models/product"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/repository"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/settlement"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type settlementSuite struct {
+	suite.Suite
+	repo   *repository.LedgerRepository
+	ledger *settlement.Ledger
+	tomato *product.Product
+}
+
+func TestSettlementSuite(t *testing.T) {
+	suite.Run(t, new(settlementSuite))
+}
+
+func (suite *settlementSuite) SetupTest() {
+	suite.repo = repository.NewWarehouseRepository()
+	suite.ledger = settlement.NewLedger()
+	suite.Require().NoError(projection.NewManager(suite.repo).Register(suite.ledger))
+	suite.tomato = suite.repo.Get("tomato-stream", "tomato")
+}
+
+func (suite *settlementSuite) place(supply bool, id, participant string, price, qty float64) {
+	opts := []event_sourcing.OrderOption{event_sourcing.WithOrderId(id), event_sourcing.WithParticipant(participant)}
+
+	place := suite.tomato.DemandProduct
+	if supply {
+		place = suite.tomato.SupplyProduct
+	}
+
+	err, demands, supplies := place(price, qty, opts...)
+	suite.Require().NoError(err)
+	for i := range supplies {
+		suite.Require().NoError(suite.tomato.TradeProduct(supplies[i], demands[i]))
+	}
+	suite.Require().NoError(suite.repo.Save(suite.tomato))
+}
+
+func (suite *settlementSuite) balance(participant, asset string) decimal.Decimal {
+	for _, b := range suite.ledger.Balances(participant) {
+		if b.Account.Asset == asset {
+			return b.Net()
+		}
+	}
+	return decimal.Zero
+}
+
+func (suite *settlementSuite) TestPostsBalancedEntriesForEveryTrade() {
+	suite.place(true, "s1", "grower-1", 20, 30)
+	suite.place(true, "s2", "grower-2", 21, 30)
+	suite.place(false, "d1", "buyer-1", 22, 50)
+
+	entries := suite.ledger.Entries("")
+	suite.Require().Len(entries, 2)
+	for _, e := range entries {
+		suite.Assert().NoError(e.Validate())
+		suite.Assert().Len(e.Lines, 4)
+	}
+
+	suite.Assert().Equal("50", suite.balance("buyer-1", "tomato").String())
+	suite.Assert().Equal("-1020", suite.balance("buyer-1", settlement.Cash).String())
+	suite.Assert().Equal("-30", suite.balance("grower-1", "tomato").String())
+	suite.Assert().Equal("600", suite.balance("grower-1", settlement.Cash).String())
+	suite.Assert().Equal("-20", suite.balance("grower-2", "tomato").String())
+	suite.Assert().Equal("420", suite.balance("grower-2", settlement.Cash).String())
+
+	suite.Assert().Len(suite.ledger.Entries("grower-2"), 1)
+	suite.Assert().Len(suite.ledger.TrialBalance(), 6)
+	suite.Assert().NoError(suite.ledger.Verify())
+}
+
+func (suite *settlementSuite) TestPostsFeesToTheMarketplace() {
+	engine := fees.NewEngine(fees.WithDefaultSchedule(fees.Schedule{MakerRate: decimal.RequireFromString("0.001"), TakerRate: decimal.RequireFromString("0.002")}))
+	suite.repo = repository.NewWarehouseRepository(repository.WithDefaultProductOptions(product.WithFees(engine)))
+	suite.ledger = settlement.NewLedger()
+	suite.Require().NoError(projection.NewManager(suite.repo).Register(suite.ledger))
+	suite.tomato = suite.repo.Get("tomato-stream", "tomato")
+
+	suite.place(true, "s1", "grower-1", 20, 50)
+	suite.place(false, "d1", "buyer-1", 20, 50)
+
+	entries := suite.ledger.Entries("")
+	suite.Require().Len(entries, 1)
+	suite.Assert().Len(entries[0].Lines, 8)
+	suite.Assert().Equal("999", suite.balance("grower-1", settlement.Cash).String())
+	suite.Assert().Equal("-1002", suite.balance("buyer-1", settlement.Cash).String())
+	suite.Assert().Equal("3", suite.balance(settlement.Marketplace, settlement.Cash).String())
+	suite.Assert().NoError(suite.ledger.Verify())
+}
+
+func (suite *settlementSuite) TestSettlesOrdersWithoutParticipantToUnassigned() {
+	suite.place(true, "s1", "", 20, 10)
+	suite.place(false, "d1", "buyer-1", 20, 10)
+
+	suite.Assert().Equal("-10", suite.balance(settlement.Unassigned, "tomato").String())
+	suite.Assert().Equal("200", suite.balance(settlement.Unassigned, settlement.Cash).String())
+}
+
+func (suite *settlementSuite) TestRejectsUnbalancedEntries() {
+	err := suite.ledger.Post(settlement.Entry{
+		Id: "adjustment",
+		Lines: []settlement.Line{
+			{Account: settlement.Account{Participant: "grower-1", Asset: "tomato"}, Amount: decimal.NewFromInt(10)},
+			{Account: settlement.Account{Participant: "buyer-1", Asset: settlement.Cash}, Amount: decimal.NewFromInt(-10)},
+		},
+	})
+	suite.Assert().Error(err)
+	suite.Assert().Empty(suite.ledger.Entries(""))
+
+	err = suite.ledger.Post(settlement.Entry{
+		Id: "transfer",
+		Lines: []settlement.Line{
+			{Account: settlement.Account{Participant: "grower-1", Asset: settlement.Cash}, Amount: decimal.NewFromInt(10)},
+			{Account: settlement.Account{Participant: "buyer-1", Asset: settlement.Cash}, Amount: decimal.NewFromInt(-10)},
+		},
+	})
+	suite.Assert().NoError(err)
+	suite.Assert().NoError(suite.ledger.Verify())
+}
+
+func (suite *settlementSuite) TestRebuildsFromTheEventStore() {
+	suite.place(true, "s1", "grower-1", 20, 30)
+	suite.place(false, "d1", "buyer-1", 22, 10)
+
+	suite.ledger.Reset()
+	suite.Assert().Empty(suite.ledger.TrialBalance())
+
+	rebuilt := settlement.NewLedger()
+	suite.Require().NoError(projection.NewManager(suite.repo).Register(rebuilt))
+	suite.Assert().Len(rebuilt.Entries(""), 1)
+	suite.Assert().Equal("200", rebuilt.Balances("grower-1")[0].Net().String())
+}
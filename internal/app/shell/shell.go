@@ -0,0 +1,348 @@
+package shell
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/event_sourcing"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/ingestion"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/projection"
+	"github.com/shopspring/decimal"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLast = 20
+
+	help = `commands:
+  <id> <time> <product> <price>/kg <qty>kg   place an order, as in order files, and print its trades
+  book <product>                             print the resting orders of a product
+  cancel <id>                                cancel a resting order
+  events [product] [--last n]                print the last n events, 20 by default
+  trades [product] [--since hh:mm|time]      print the trades since a time of today or an RFC 3339 time
+  undo                                       take back the last order or cancel of this shell
+  help                                       print this help
+  quit                                       leave the shell
+`
+)
+
+// errQuit ends Run.
+var errQuit = errors.New("quit")
+
+type Option func(s *Shell)
+
+// WithPrompt prints prompt before reading each command.
+func WithPrompt(prompt string) Option {
+	return func(s *Shell) {
+		s.prompt = prompt
+	}
+}
+
+// WithClock replaces time.Now, to tell which day a --since time of day refers to.
+func WithClock(now func() time.Time) Option {
+	return func(s *Shell) {
+		s.now = now
+	}
+}
+
+// action is an order placed or cancelled from the shell, which undo takes back.
+type action struct {
+	placed    *app.OrderCommand
+	cancelled *projection.OpenOrder
+}
+
+// Shell runs operator commands against a ledger, one line at a time.
+type Shell struct {
+	ledger  *app.App
+	out     io.Writer
+	prompt  string
+	now     func() time.Time
+	actions []action
+}
+
+func New(ledger *app.App, out io.Writer, opts ...Option) *Shell {
+	s := &Shell{ledger: ledger, out: out, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run executes the commands read from in until it ends, ctx is done or quit is entered. A failing command
+// is reported and does not end the shell.
+func (s *Shell) Run(ctx context.Context, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		if s.prompt != "" {
+			fmt.Fprint(s.out, s.prompt)
+		}
+		if ctx.Err() != nil || !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		err := s.Execute(scanner.Text())
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(s.out, "error: %s\n", err)
+		}
+	}
+}
+
+// Execute runs a single command.
+func (s *Shell) Execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	switch fields[0] {
+	case "help":
+		_, err := fmt.Fprint(s.out, help)
+		return err
+	case "quit", "exit":
+		return errQuit
+	case "book":
+		if len(fields) != 2 {
+			return errors.New("usage: book <product>")
+		}
+		return s.book(fields[1])
+	case "cancel":
+		if len(fields) != 2 {
+			return errors.New("usage: cancel <id>")
+		}
+		return s.cancel(fields[1])
+	case "events":
+		return s.events(fields[1:])
+	case "trades":
+		return s.trades(fields[1:])
+	case "undo":
+		return s.undo()
+	default:
+		cmd, err := ingestion.ParseLine(line)
+		if err != nil {
+			return fmt.Errorf("unknown command %q, or invalid order: %w", fields[0], err)
+		}
+		return s.place(cmd)
+	}
+}
+
+func (s *Shell) place(cmd app.OrderCommand) error {
+	trades, err := s.ledger.Submit(cmd)
+	if err != nil {
+		return err
+	}
+
+	s.actions = append(s.actions, action{placed: &cmd})
+	s.printTrades(cmd.Id, trades)
+	return nil
+}
+
+func (s *Shell) printTrades(id string, trades []app.Trade) {
+	if len(trades) == 0 {
+		fmt.Fprintf(s.out, "%s resting\n", id)
+	}
+	for _, t := range trades {
+		fmt.Fprintln(s.out, t.String())
+	}
+}
+
+func (s *Shell) book(name string) error {
+	demands, supplies, err := s.ledger.Book(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(s.out, name)
+	for _, o := range append(demands, supplies...) {
+		fmt.Fprintf(s.out, "%-6s %-10s %s/kg %skg\n", strings.ToLower(o.OrderType), o.Id, o.Price.String(), o.Qty.String())
+	}
+	return nil
+}
+
+func (s *Shell) cancel(id string) error {
+	open, ok := s.ledger.OpenOrder(id)
+	if !ok {
+		return fmt.Errorf("no open order %s", id)
+	}
+
+	if err := s.ledger.Cancel(open.Product, open.Participant, id); err != nil {
+		return err
+	}
+
+	s.actions = append(s.actions, action{cancelled: &open})
+	fmt.Fprintf(s.out, "cancelled %s, %skg were left\n", id, open.Qty.String())
+	return nil
+}
+
+// undo takes back the last action. A placed order is cancelled, its trades stand; a cancelled order is
+// placed again as it was, with the quantity it had left, behind the orders that arrived meanwhile.
+func (s *Shell) undo() error {
+	if len(s.actions) == 0 {
+		return errors.New("nothing to undo")
+	}
+	last := s.actions[len(s.actions)-1]
+
+	if last.placed != nil {
+		open, ok := s.ledger.OpenOrder(last.placed.Id)
+		if !ok {
+			return fmt.Errorf("%s is no longer open, its trades cannot be undone", last.placed.Id)
+		}
+		if err := s.ledger.Cancel(open.Product, open.Participant, open.Id); err != nil {
+			return err
+		}
+
+		s.actions = s.actions[:len(s.actions)-1]
+		if open.Qty.LessThan(decimal.NewFromFloat(last.placed.Qty)) {
+			fmt.Fprintf(s.out, "undone %s, cancelled the %skg left, its trades stand\n", open.Id, open.Qty.String())
+		} else {
+			fmt.Fprintf(s.out, "undone %s\n", open.Id)
+		}
+		return nil
+	}
+
+	cancelled := last.cancelled
+	price, _ := cancelled.Price.Float64()
+	// the hidden reserve of an iceberg order is left too
+	left := cancelled.Qty.Add(cancelled.Hidden)
+	qty, _ := left.Float64()
+	peak, _ := cancelled.Peak.Float64()
+	stopPrice, _ := cancelled.StopPrice.Float64()
+	minQty, _ := decimal.Min(cancelled.MinQty, left).Float64()
+	trades, err := s.ledger.Submit(app.OrderCommand{
+		Id:          cancelled.Id,
+		Participant: cancelled.Participant,
+		Product:     cancelled.Product,
+		Side:        cancelled.OrderType,
+		Price:       price,
+		Qty:         qty,
+		Peak:        peak,
+		StopPrice:   stopPrice,
+		MinQty:      minQty,
+		AllOrNone:   cancelled.AllOrNone,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.actions = s.actions[:len(s.actions)-1]
+	fmt.Fprintf(s.out, "undone cancel of %s, placed it again\n", cancelled.Id)
+	s.printTrades(cancelled.Id, trades)
+	return nil
+}
+
+func (s *Shell) events(args []string) error {
+	positional, opts, err := parseArgs(args, "last")
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("usage: events [product] [--last n]")
+	}
+
+	last := defaultLast
+	if v, ok := opts["last"]; ok {
+		if last, err = strconv.Atoi(v); err != nil || last <= 0 {
+			return fmt.Errorf("invalid --last %q", v)
+		}
+	}
+
+	var records []event_sourcing.Record
+	for _, r := range s.ledger.Events(0, 0) {
+		if len(positional) == 0 || r.Event.Product() == positional[0] {
+			records = append(records, r)
+		}
+	}
+	if len(records) > last {
+		records = records[len(records)-last:]
+	}
+
+	for _, r := range records {
+		data, err := event_sourcing.Marshal(r.Event)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "%d %s %s\n", r.Sequence, r.Time.Format(time.TimeOnly), data)
+	}
+	return nil
+}
+
+func (s *Shell) trades(args []string) error {
+	positional, opts, err := parseArgs(args, "since")
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("usage: trades [product] [--since hh:mm|time]")
+	}
+
+	var since time.Time
+	if v, ok := opts["since"]; ok {
+		if since, err = s.parseSince(v); err != nil {
+			return err
+		}
+	}
+
+	product := ""
+	if len(positional) == 1 {
+		product = positional[0]
+	}
+
+	for _, t := range s.ledger.Trades(product, "") {
+		if t.Timestamp.Before(since) {
+			continue
+		}
+		fmt.Fprintf(s.out, "%s %s %s %s %s/kg %skg\n", t.Timestamp.Format(time.TimeOnly), t.Product, t.DemandOrderId, t.SupplyOrderId, t.Price.String(), t.Qty.String())
+	}
+	return nil
+}
+
+// parseSince reads a time of today such as 09:45, or an RFC 3339 time.
+func (s *Shell) parseSince(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	clock, err := time.Parse("15:04", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q, expected hh:mm or an RFC 3339 time", v)
+	}
+
+	now := s.now()
+	return time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location()), nil
+}
+
+// parseArgs splits arguments into positional ones and the values of the named --options.
+func parseArgs(args []string, names ...string) ([]string, map[string]string, error) {
+	var positional []string
+	opts := make(map[string]string)
+
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			positional = append(positional, args[i])
+			continue
+		}
+
+		name := strings.TrimPrefix(args[i], "--")
+		known := false
+		for _, n := range names {
+			known = known || n == name
+		}
+		if !known {
+			return nil, nil, fmt.Errorf("unknown option --%s", name)
+		}
+		if i+1 == len(args) {
+			return nil, nil, fmt.Errorf("--%s needs a value", name)
+		}
+
+		opts[name] = args[i+1]
+		i++
+	}
+	return positional, opts, nil
+}
@@ -0,0 +1,145 @@
+package shell_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/config"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/shell"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func run(t *testing.T, commands ...string) []string {
+	cfg, err := config.Load("../../../configs", "")
+	require.NoError(t, err)
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, shell.New(ledger, &out).Run(context.Background(), strings.NewReader(strings.Join(commands, "\n"))))
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+}
+
+func TestShell_PlacesOrdersAndPrintsMatches(t *testing.T) {
+	out := run(t,
+		"s1 09:45 tomato 24/kg 100kg",
+		"d1 09:46 tomato 25/kg 30kg",
+		"book tomato",
+		"cancel s1",
+		"book tomato",
+	)
+
+	assert.Equal(t, []string{
+		"s1 resting",
+		"d1 s1 24/kg 30kg",
+		"tomato",
+		"supply s1         24/kg 70kg",
+		"cancelled s1, 70kg were left",
+		"tomato",
+	}, out)
+}
+
+func TestShell_UndoesOrdersAndCancels(t *testing.T) {
+	out := run(t,
+		"s1 09:45 tomato 24/kg 100kg",
+		"s2 09:46 tomato 23/kg 10kg",
+		"cancel s1",
+		"undo",
+		"undo",
+		"d1 09:47 tomato 25/kg 30kg",
+		"undo",
+		"book tomato",
+		"undo",
+	)
+
+	assert.Equal(t, []string{
+		"s1 resting",
+		"s2 resting",
+		"cancelled s1, 100kg were left",
+		"undone cancel of s1, placed it again",
+		"s1 resting",
+		"undone s2",
+		"d1 s1 24/kg 30kg",
+		"error: d1 is no longer open, its trades cannot be undone",
+		"tomato",
+		"supply s1         24/kg 70kg",
+		"error: d1 is no longer open, its trades cannot be undone",
+	}, out)
+}
+
+func TestShell_UndoesTheCancelOfAnOrderWithItsOptions(t *testing.T) {
+	cfg, err := config.Load("../../../configs", "")
+	require.NoError(t, err)
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	for _, cmd := range []app.OrderCommand{
+		{Id: "s1", Participant: "grower-1", Product: "tomato", Side: "SUPPLY", Price: 24, Qty: 100, Peak: 20, MinQty: 5},
+		{Id: "d1", Participant: "buyer-1", Product: "tomato", Side: "DEMAND", Price: 24, Qty: 30},
+		{Id: "stop", Participant: "buyer-1", Product: "tomato", Side: "DEMAND", StopPrice: 30, Price: 31, Qty: 10, AllOrNone: true},
+	} {
+		_, err := ledger.Submit(cmd)
+		require.NoError(t, err)
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, shell.New(ledger, &out).Run(context.Background(), strings.NewReader("cancel s1\ncancel stop\nundo\nundo")))
+
+	s1, ok := ledger.OpenOrder("s1")
+	require.True(t, ok)
+	assert.Equal(t, "70", s1.Qty.Add(s1.Hidden).String(), "the hidden reserve is placed again")
+	assert.Equal(t, "20", s1.Peak.String())
+	assert.Equal(t, "5", s1.MinQty.String())
+
+	_, supplies, err := ledger.Book("tomato")
+	require.NoError(t, err)
+	require.Len(t, supplies, 1)
+	assert.Equal(t, "20", supplies[0].Qty.String(), "only the peak is shown")
+
+	stop, ok := ledger.OpenOrder("stop")
+	require.True(t, ok)
+	assert.Equal(t, "30", stop.StopPrice.String())
+	assert.True(t, stop.AllOrNone)
+}
+
+func TestShell_PrintsEventsAndTrades(t *testing.T) {
+	out := run(t,
+		"s1 09:45 tomato 24/kg 100kg",
+		"s2 09:45 potato 10/kg 100kg",
+		"d1 09:46 tomato 25/kg 30kg",
+		"events tomato --last 2",
+		"trades --since "+time.Now().Add(time.Hour).Format(time.RFC3339),
+		"trades tomato --since 2000-01-01T00:00:00Z",
+		"trades --until 09:45",
+		"s3 tomato",
+	)
+
+	require.Len(t, out, 8)
+	assert.Contains(t, out[3], `"type":"demand"`)
+	assert.Contains(t, out[4], `"type":"trade"`)
+	assert.True(t, strings.HasSuffix(out[5], " tomato d1 s1 24/kg 30kg"), out[5])
+	assert.Equal(t, "error: unknown option --until", out[6])
+	assert.Equal(t, `error: unknown command "s3", or invalid order: expected 5 fields, got 2`, out[7])
+}
+
+func TestShell_ReadsTimesOfTodayAndQuits(t *testing.T) {
+	cfg, err := config.Load("../../../configs", "")
+	require.NoError(t, err)
+
+	ledger, err := app.New(cfg, logging.Discard())
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	tomorrow := func() time.Time { return time.Now().Add(24 * time.Hour) }
+	s := shell.New(ledger, &out, shell.WithPrompt("> "), shell.WithClock(tomorrow))
+	require.NoError(t, s.Run(context.Background(), strings.NewReader("s1 09:45 tomato 24/kg 100kg\nd1 09:46 tomato 25/kg 30kg\ntrades --since 00:00\nquit\nbook tomato\n")))
+
+	assert.Equal(t, "> s1 resting\n> d1 s1 24/kg 30kg\n> > ", out.String())
+}
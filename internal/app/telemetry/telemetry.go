@@ -0,0 +1,34 @@
+package telemetry
+
+import (
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/metrics"
+)
+
+type Metrics struct {
+	Orders          *metrics.Counter
+	Trades          *metrics.Counter
+	Rejections      *metrics.Counter
+	Cancels         *metrics.Counter
+	MatchingLatency *metrics.Histogram
+	AppendLatency   *metrics.Histogram
+	BookDepth       *metrics.Gauge
+	RestingQty      *metrics.Gauge
+}
+
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		Orders:          metrics.NewCounter(registry, "ledger_orders_total", "Supply and demand orders accepted.", "product", "side"),
+		Trades:          metrics.NewCounter(registry, "ledger_trades_total", "Trades executed.", "product"),
+		Rejections:      metrics.NewCounter(registry, "ledger_rejections_total", "Orders and commands rejected.", "product", "reason"),
+		Cancels:         metrics.NewCounter(registry, "ledger_cancels_total", "Resting orders cancelled.", "product", "side"),
+		MatchingLatency: metrics.NewHistogram(registry, "ledger_matching_duration_seconds", "Time spent matching an incoming order.", metrics.DefaultBuckets, "product"),
+		AppendLatency:   metrics.NewHistogram(registry, "ledger_event_store_append_duration_seconds", "Time spent appending events to the event store.", metrics.DefaultBuckets),
+		BookDepth:       metrics.NewGauge(registry, "ledger_book_depth", "Resting orders in the book.", "product", "side"),
+		RestingQty:      metrics.NewGauge(registry, "ledger_resting_quantity", "Quantity resting in the book.", "product", "side"),
+	}
+}
+
+// Discard returns metrics bound to a private registry, for components that were not given one.
+func Discard() *Metrics {
+	return NewMetrics(metrics.NewRegistry())
+}
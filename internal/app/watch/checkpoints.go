@@ -0,0 +1,117 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Entry is the checkpoint of a file. Source names the file in the origins of the orders read from it, so
+// that a file dropped again under the same name once the first was archived is read as a new one.
+type Entry struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Offset int64  `json:"offset"`
+}
+
+func (e Entry) Position() file_ops.Position {
+	return file_ops.Position{Line: e.Line, Offset: e.Offset}
+}
+
+// Checkpoints keeps how far each file was read in a local JSON file, replaced atomically on every save.
+type Checkpoints struct {
+	mtx     sync.Mutex
+	path    string
+	entries map[string]Entry
+}
+
+// LoadCheckpoints reads the checkpoints saved at path, none if the file does not exist yet.
+func LoadCheckpoints(path string) (*Checkpoints, error) {
+	c := &Checkpoints{path: path, entries: make(map[string]Entry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Checkpoints) Get(name string) (Entry, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[name]
+	return e, ok
+}
+
+func (c *Checkpoints) Put(name string, entry Entry) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.entries[name] = entry
+	return c.save()
+}
+
+func (c *Checkpoints) Delete(name string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	delete(c.entries, name)
+	return c.save()
+}
+
+// Retain drops the checkpoints of the files keep does not hold.
+func (c *Checkpoints) Retain(keep func(name string) bool) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	changed := false
+	for name := range c.entries {
+		if !keep(name) {
+			delete(c.entries, name)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return c.save()
+}
+
+func (c *Checkpoints) save() error {
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), "."+filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path)
+}
@@ -0,0 +1,360 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/ingestion"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInterval = time.Second
+	defaultIdle     = 30 * time.Second
+
+	// commitEvery is the number of lines between checkpoint saves while a file is read. Lines read since the
+	// last save are skipped on restart using the origins recorded with their orders.
+	commitEvery = 1000
+)
+
+// Ledger is what the watcher feeds the orders it reads to.
+type Ledger interface {
+	Submit(cmd app.OrderCommand) ([]app.Trade, error)
+	Ingested(source string) int
+}
+
+type Option func(w *Watcher)
+
+// WithArchive moves completed files of a watched directory to dir.
+func WithArchive(dir string) Option {
+	return func(w *Watcher) {
+		w.archive = dir
+	}
+}
+
+// WithInterval sets how often the watched directory or file is polled for new lines.
+func WithInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.interval = interval
+	}
+}
+
+// WithIdle sets how long a file of a watched directory must stay unchanged to be complete.
+func WithIdle(idle time.Duration) Option {
+	return func(w *Watcher) {
+		w.idle = idle
+	}
+}
+
+func WithColumns(columns map[string]string) Option {
+	return func(w *Watcher) {
+		w.columns = columns
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(w *Watcher) {
+		w.logger = logger
+	}
+}
+
+// WithClock replaces time.Now, to tell when files became idle.
+func WithClock(now func() time.Time) Option {
+	return func(w *Watcher) {
+		w.now = now
+	}
+}
+
+type file struct {
+	name    string
+	path    string
+	entry   Entry
+	lines   *file_ops.LineReader
+	parser  ingestion.Parser
+	pending int
+	size    int64
+	modTime time.Time
+	changed time.Time
+}
+
+// Watcher reads order files as they are written, either every file dropped into a directory or a single
+// append-only file. Only complete lines are read, how far each file was read is saved to the checkpoints
+// and every order line takes effect once across restarts: lines whose orders were recorded are skipped
+// using their origins even if the checkpoint lags behind.
+type Watcher struct {
+	ledger      Ledger
+	checkpoints *Checkpoints
+	out         io.Writer
+	archive     string
+	interval    time.Duration
+	idle        time.Duration
+	columns     map[string]string
+	logger      *slog.Logger
+	now         func() time.Time
+	files       map[string]*file
+}
+
+// New returns a watcher feeding ledger and writing the resulting trades to out, one per line.
+func New(ledger Ledger, checkpoints *Checkpoints, out io.Writer, opts ...Option) *Watcher {
+	w := &Watcher{
+		ledger:      ledger,
+		checkpoints: checkpoints,
+		out:         out,
+		interval:    defaultInterval,
+		idle:        defaultIdle,
+		logger:      slog.Default(),
+		now:         time.Now,
+		files:       make(map[string]*file),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// WatchDir polls dir until ctx is done. Hidden files and directories are ignored, files are read in name
+// order and moved to the archive once they stayed unchanged for the idle period.
+func (w *Watcher) WatchDir(ctx context.Context, dir string) error {
+	return w.run(ctx, func() error { return w.PollDir(ctx, dir) })
+}
+
+// TailFile polls the append-only file at path until ctx is done.
+func (w *Watcher) TailFile(ctx context.Context, path string) error {
+	return w.run(ctx, func() error { return w.PollFile(ctx, path) })
+}
+
+// PollDir reads the lines added to the files of dir since the last poll and archives the complete ones.
+func (w *Watcher) PollDir(ctx context.Context, dir string) error {
+	if w.archive == "" {
+		return errors.New("watching a directory requires an archive directory")
+	}
+	if err := os.MkdirAll(w.archive, 0o755); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		present[e.Name()] = true
+
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+
+		f, err := w.file(e.Name(), filepath.Join(dir, e.Name()), info)
+		if err != nil {
+			w.logger.Warn("cannot read order file", slog.String("file", e.Name()), slog.String("error", err.Error()))
+			continue
+		}
+
+		if err = w.drain(ctx, f); err != nil {
+			return err
+		}
+
+		if info.Size() != f.size || !info.ModTime().Equal(f.modTime) {
+			f.size, f.modTime, f.changed = info.Size(), info.ModTime(), w.now()
+		} else if w.now().Sub(f.changed) >= w.idle {
+			if err = w.complete(ctx, f); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, f := range w.files {
+		if !present[name] {
+			_ = f.lines.Close()
+			delete(w.files, name)
+		}
+	}
+	return w.checkpoints.Retain(func(name string) bool { return present[name] })
+}
+
+// PollFile reads the lines appended to the file at path since the last poll. A file that does not exist
+// yet is waited for.
+func (w *Watcher) PollFile(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := w.file(path, path, info)
+	if err != nil {
+		return err
+	}
+	return w.drain(ctx, f)
+}
+
+// Close saves how far every open file was read and closes them.
+func (w *Watcher) Close() error {
+	var result error
+	for name, f := range w.files {
+		if err := w.commit(f); err != nil {
+			result = err
+		}
+		_ = f.lines.Close()
+		delete(w.files, name)
+	}
+	return result
+}
+
+func (w *Watcher) run(ctx context.Context, poll func() error) error {
+	defer w.Close()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := poll(); err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// file opens a file the first time it is seen, resuming after the lines already read from it.
+func (w *Watcher) file(name, path string, info os.FileInfo) (*file, error) {
+	if f, ok := w.files[name]; ok {
+		return f, nil
+	}
+
+	entry, ok := w.checkpoints.Get(name)
+	if !ok {
+		entry = Entry{Source: uuid.New().String()}
+		if err := w.checkpoints.Put(name, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	resume := entry.Position()
+	if line := w.ledger.Ingested(entry.Source); line > resume.Line {
+		resume = file_ops.Position{Line: line}
+	}
+
+	lines, err := file_ops.Open(path, file_ops.WithFollow())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []ingestion.Option{ingestion.WithResume(resume), ingestion.WithColumns(w.columns)}
+	if format, ok := ingestion.FormatOf(name); ok {
+		opts = append(opts, ingestion.WithFormat(format))
+	}
+
+	f := &file{
+		name:    name,
+		path:    path,
+		entry:   entry,
+		lines:   lines,
+		parser:  ingestion.NewSourceParser(lines, opts...),
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		changed: w.now(),
+	}
+	w.files[name] = f
+	return f, nil
+}
+
+// drain reads the complete lines written to a file since it was last drained.
+func (w *Watcher) drain(ctx context.Context, f *file) error {
+	for {
+		line, err := f.parser.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return w.commit(f)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				w.logger.Warn("cannot read order file", slog.String("file", f.name), slog.String("error", err.Error()))
+			}
+			return w.commit(f)
+		}
+
+		if err = w.process(f, line); err != nil {
+			return err
+		}
+
+		f.entry.Line, f.entry.Offset = line.Position.Line, line.Position.Offset
+		f.pending++
+		if f.pending >= commitEvery {
+			if err = w.commit(f); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *Watcher) process(f *file, line ingestion.Line) error {
+	if line.Err != nil {
+		w.logger.Warn("order rejected", slog.String("file", f.name), slog.Int("line", line.Number), slog.String("error", line.Err.Error()))
+		return nil
+	}
+
+	cmd := line.Command
+	cmd.Origin = ingestion.Origin(f.entry.Source, line.Number)
+
+	trades, err := w.ledger.Submit(cmd)
+	if err != nil {
+		w.logger.Warn("order rejected", slog.String("file", f.name), slog.Int("line", line.Number), slog.String("error", err.Error()))
+		return nil
+	}
+
+	for _, t := range trades {
+		if _, err = fmt.Fprintln(w.out, t.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) commit(f *file) error {
+	if f.pending == 0 {
+		return nil
+	}
+
+	f.pending = 0
+	return w.checkpoints.Put(f.name, f.entry)
+}
+
+// complete reads a last line left without a newline, then archives the file and forgets its checkpoint.
+func (w *Watcher) complete(ctx context.Context, f *file) error {
+	f.lines.StopFollowing()
+	if err := w.drain(ctx, f); err != nil {
+		return err
+	}
+	_ = f.lines.Close()
+	delete(w.files, f.name)
+
+	target := filepath.Join(w.archive, f.name)
+	if _, err := os.Stat(target); err == nil {
+		target = filepath.Join(w.archive, fmt.Sprintf("%s.%d", f.name, w.now().UnixNano()))
+	}
+	if err := os.Rename(f.path, target); err != nil {
+		return err
+	}
+
+	w.logger.Info("order file archived", slog.String("file", f.name), slog.Int("lines", f.entry.Line), slog.String("archive", target))
+	return w.checkpoints.Delete(f.name)
+}
@@ -0,0 +1,174 @@
+package watch_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/config"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/constants"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/app/watch"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/logging"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type watchSuite struct {
+	suite.Suite
+	dir     string
+	inbox   string
+	archive string
+	clock   time.Time
+	out     bytes.Buffer
+}
+
+func TestWatchSuite(t *testing.T) {
+	suite.Run(t, new(watchSuite))
+}
+
+func (suite *watchSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	suite.inbox = filepath.Join(suite.dir, "inbox")
+	suite.archive = filepath.Join(suite.dir, "archive")
+	suite.Require().NoError(os.MkdirAll(suite.inbox, 0o755))
+	suite.clock = time.Date(2023, 3, 1, 9, 0, 0, 0, time.UTC)
+	suite.out.Reset()
+}
+
+// start opens the ledger and checkpoints kept in the test directory, as a restarted daemon would.
+func (suite *watchSuite) start() (*app.App, *watch.Watcher) {
+	cfg, err := config.Load("../../../configs", "")
+	suite.Require().NoError(err)
+	cfg.Storage = config.Storage{Backend: config.FileBackend, Path: filepath.Join(suite.dir, "events.jsonl")}
+
+	ledger, err := app.New(cfg, logging.Discard())
+	suite.Require().NoError(err)
+
+	checkpoints, err := watch.LoadCheckpoints(filepath.Join(suite.dir, "checkpoints.json"))
+	suite.Require().NoError(err)
+
+	return ledger, watch.New(ledger, checkpoints, &suite.out,
+		watch.WithArchive(suite.archive),
+		watch.WithIdle(time.Minute),
+		watch.WithLogger(logging.Discard()),
+		watch.WithClock(func() time.Time { return suite.clock }),
+	)
+}
+
+func (suite *watchSuite) write(name, content string) {
+	f, err := os.OpenFile(filepath.Join(suite.inbox, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	suite.Require().NoError(err)
+	_, err = f.WriteString(content)
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.Close())
+}
+
+func (suite *watchSuite) TestReadsCompleteLinesAsTheyAreWritten() {
+	_, w := suite.start()
+	defer w.Close()
+
+	suite.write("partner-1.txt", "s1 09:45 tomato 24/kg 100kg\nd1 09:46 tomato 2")
+	suite.Require().NoError(w.PollDir(context.Background(), suite.inbox))
+	suite.Assert().Empty(suite.out.String())
+
+	suite.write("partner-1.txt", "5/kg 30kg\n")
+	suite.Require().NoError(w.PollDir(context.Background(), suite.inbox))
+	suite.Assert().Equal("d1 s1 24/kg 30kg\n", suite.out.String())
+}
+
+func (suite *watchSuite) TestArchivesFilesOnceTheyStayUnchanged() {
+	_, w := suite.start()
+	defer w.Close()
+
+	suite.write("partner-1.csv", "id,product,price,qty\ns1,tomato,24,100\nd1,tomato,25,30")
+	suite.Require().NoError(w.PollDir(context.Background(), suite.inbox))
+	suite.Assert().Empty(suite.out.String(), "the last line may still be being written")
+
+	suite.clock = suite.clock.Add(2 * time.Minute)
+	suite.Require().NoError(w.PollDir(context.Background(), suite.inbox))
+	suite.Assert().Equal("d1 s1 24/kg 30kg\n", suite.out.String())
+
+	suite.Assert().NoFileExists(filepath.Join(suite.inbox, "partner-1.csv"))
+	suite.Assert().FileExists(filepath.Join(suite.archive, "partner-1.csv"))
+}
+
+func (suite *watchSuite) TestProcessesEveryLineOnceAcrossRestarts() {
+	_, w := suite.start()
+	suite.write("partner-1.txt", "s1 09:45 tomato 24/kg 100kg\nd1 09:46 tomato 25/kg 30kg\n")
+	suite.Require().NoError(w.PollDir(context.Background(), suite.inbox))
+
+	// The daemon dies before saving its checkpoint of the lines it read.
+	checkpoints, err := os.ReadFile(filepath.Join(suite.dir, "checkpoints.json"))
+	suite.Require().NoError(err)
+	suite.Require().Contains(string(checkpoints), `"line": 2`)
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "checkpoints.json"), bytes.ReplaceAll(checkpoints, []byte(`"line": 2`), []byte(`"line": 0`)), 0o600))
+
+	ledger, w := suite.start()
+	defer w.Close()
+	suite.write("partner-1.txt", "d2 09:47 tomato 24/kg 20kg\n")
+	suite.Require().NoError(w.PollDir(context.Background(), suite.inbox))
+
+	suite.Assert().Equal([]string{"d1 s1 24/kg 30kg", "d2 s1 24/kg 20kg"}, strings.Split(strings.TrimSpace(suite.out.String()), "\n"))
+	suite.Assert().Len(ledger.Trades("tomato", ""), 2)
+
+	_, supplies, err := ledger.Book("tomato")
+	suite.Require().NoError(err)
+	suite.Require().Len(supplies, 1)
+	suite.Assert().Equal("50", supplies[0].Qty.String())
+}
+
+func (suite *watchSuite) TestSkipsRejectedLinesAcrossRestarts() {
+	_, w := suite.start()
+	suite.write("partner-1.txt", "s1 09:45 tomato 24/kg 100kg\ns2 09:46 tomato 24.005/kg 10kg\n")
+	suite.Require().NoError(w.PollDir(context.Background(), suite.inbox))
+
+	// The daemon dies before saving its checkpoint of the lines it read.
+	checkpoints, err := os.ReadFile(filepath.Join(suite.dir, "checkpoints.json"))
+	suite.Require().NoError(err)
+	suite.Require().Contains(string(checkpoints), `"line": 2`)
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "checkpoints.json"), bytes.ReplaceAll(checkpoints, []byte(`"line": 2`), []byte(`"line": 0`)), 0o600))
+
+	ledger, w := suite.start()
+	defer w.Close()
+	suite.Require().NoError(w.PollDir(context.Background(), suite.inbox))
+
+	rejected := 0
+	for _, record := range ledger.Events(0, 0) {
+		if record.Event.Type() == constants.RejectEventType {
+			rejected++
+		}
+	}
+	suite.Assert().Equal(1, rejected, "the off-tick line is not submitted a second time")
+}
+
+func (suite *watchSuite) TestTailsAnAppendOnlyFile() {
+	_, w := suite.start()
+	path := filepath.Join(suite.inbox, "orders.jsonl")
+
+	suite.Require().NoError(w.PollFile(context.Background(), path), "the file is waited for")
+
+	suite.write("orders.jsonl", `{"id":"s1","product":"tomato","price":24,"qty":100}`+"\n")
+	suite.Require().NoError(w.PollFile(context.Background(), path))
+	suite.Require().NoError(w.Close())
+
+	ledger, w := suite.start()
+	defer w.Close()
+	suite.write("orders.jsonl", `{"id":"d1","product":"tomato","price":24,"qty":10}`+"\n")
+	suite.Require().NoError(w.PollFile(context.Background(), path))
+
+	suite.Assert().Equal("d1 s1 24/kg 10kg\n", suite.out.String())
+	suite.Assert().Equal(2, ledger.Ingested(suite.source(path)))
+	suite.Assert().FileExists(path, "tailed files are never archived")
+}
+
+func (suite *watchSuite) source(name string) string {
+	checkpoints, err := watch.LoadCheckpoints(filepath.Join(suite.dir, "checkpoints.json"))
+	suite.Require().NoError(err)
+
+	entry, ok := checkpoints.Get(name)
+	suite.Require().True(ok)
+	return entry.Source
+}
@@ -0,0 +1,179 @@
+package file_ops
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// Position is how far an input was read: the number of the last line read and the byte offset just after it.
+type Position struct {
+	Line   int
+	Offset int64
+}
+
+type Line struct {
+	Number int
+	Text   string
+	// End is the byte offset just after the line, where reading resumes once it was processed.
+	End int64
+}
+
+func (l Line) Position() Position {
+	return Position{Line: l.Number, Offset: l.End}
+}
+
+// Progress is reported while reading. Size is 0 when the size of the input is not known.
+type Progress struct {
+	Position
+	Size int64
+}
+
+// Percent returns how much of the input was read, or -1 when its size is not known.
+func (p Progress) Percent() float64 {
+	if p.Size <= 0 {
+		return -1
+	}
+	return float64(p.Offset) * 100 / float64(p.Size)
+}
+
+type LineOption func(lr *LineReader)
+
+// WithProgress calls report every every lines and once the input was read entirely.
+func WithProgress(every int, report func(Progress)) LineOption {
+	return func(lr *LineReader) {
+		lr.every = every
+		lr.report = report
+	}
+}
+
+// WithFollow leaves a last line that does not end with a newline pending until its newline is written, for
+// inputs that are still being appended to. Next keeps returning io.EOF at the end of the input, and new
+// lines once they are appended.
+func WithFollow() LineOption {
+	return func(lr *LineReader) {
+		lr.follow = true
+	}
+}
+
+// LineReader reads an input one line at a time, so that inputs larger than memory can be streamed. Lines
+// may end with \n or \r\n, which is not part of their text.
+type LineReader struct {
+	r        io.Reader
+	reader   *bufio.Reader
+	closer   io.Closer
+	position Position
+	size     int64
+	every    int
+	report   func(Progress)
+	reported *Position
+	follow   bool
+	partial  string
+}
+
+func NewLineReader(r io.Reader, opts ...LineOption) *LineReader {
+	lr := &LineReader{r: r, reader: bufio.NewReader(r)}
+	for _, opt := range opts {
+		opt(lr)
+	}
+	return lr
+}
+
+// Open streams the lines of the file at path. The reader must be closed once done with.
+func Open(path string, opts ...LineOption) (*LineReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	lr := NewLineReader(f, opts...)
+	lr.closer = f
+	lr.size = info.Size()
+	return lr, nil
+}
+
+// Next returns the next line, or io.EOF once the input was read entirely. It stops with the error of ctx
+// once ctx is done.
+func (lr *LineReader) Next(ctx context.Context) (Line, error) {
+	if err := ctx.Err(); err != nil {
+		return Line{}, err
+	}
+
+	raw, err := lr.reader.ReadString('\n')
+	raw, lr.partial = lr.partial+raw, ""
+	if errors.Is(err, io.EOF) && lr.follow {
+		lr.partial = raw
+		raw = ""
+	}
+	if errors.Is(err, io.EOF) && raw == "" {
+		lr.progress(true)
+		return Line{}, io.EOF
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Line{}, err
+	}
+
+	lr.position.Line++
+	lr.position.Offset += int64(len(raw))
+	lr.progress(false)
+
+	return Line{Number: lr.position.Line, Text: strings.TrimRight(raw, "\r\n"), End: lr.position.Offset}, nil
+}
+
+// StopFollowing returns a pending last line with the next read, once the input is known to be complete.
+func (lr *LineReader) StopFollowing() {
+	lr.follow = false
+}
+
+// Position returns how far the input was read.
+func (lr *LineReader) Position() Position {
+	return lr.position
+}
+
+// Seek resumes reading at a position returned by an earlier read of the same input. The underlying
+// reader must be an io.Seeker.
+func (lr *LineReader) Seek(position Position) error {
+	seeker, ok := lr.r.(io.Seeker)
+	if !ok {
+		return errors.New("input does not support seeking")
+	}
+
+	if _, err := seeker.Seek(position.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	lr.reader.Reset(lr.r)
+	lr.position = position
+	lr.partial = ""
+	return nil
+}
+
+func (lr *LineReader) Close() error {
+	if lr.closer == nil {
+		return nil
+	}
+	return lr.closer.Close()
+}
+
+func (lr *LineReader) progress(done bool) {
+	if lr.report == nil {
+		return
+	}
+	if lr.reported != nil && *lr.reported == lr.position {
+		return
+	}
+
+	if done || (lr.every > 0 && lr.position.Line%lr.every == 0) {
+		position := lr.position
+		lr.reported = &position
+		lr.report(Progress{Position: position, Size: lr.size})
+	}
+}
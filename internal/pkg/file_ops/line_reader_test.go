@@ -0,0 +1,58 @@
+package file_ops_test
+
+import (
+	"context"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/file_ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLineReader_StreamsLinesWithTheirOffsets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.txt")
+	require.NoError(t, os.WriteFile(path, []byte("first\r\nsecond\n\nlast"), 0o600))
+
+	var reported []file_ops.Progress
+	lr, err := file_ops.Open(path, file_ops.WithProgress(2, func(p file_ops.Progress) { reported = append(reported, p) }))
+	require.NoError(t, err)
+	defer lr.Close()
+
+	var lines []file_ops.Line
+	for {
+		line, err := lr.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		lines = append(lines, line)
+	}
+
+	assert.Equal(t, []file_ops.Line{
+		{Number: 1, Text: "first", End: 7},
+		{Number: 2, Text: "second", End: 14},
+		{Number: 3, Text: "", End: 15},
+		{Number: 4, Text: "last", End: 19},
+	}, lines)
+
+	require.Len(t, reported, 2, "the end of the input is reported once")
+	assert.Equal(t, file_ops.Position{Line: 2, Offset: 14}, reported[0].Position)
+	assert.Equal(t, float64(100), reported[1].Percent())
+
+	require.NoError(t, lr.Seek(lines[1].Position()))
+	line, err := lr.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, lines[2], line)
+}
+
+func TestLineReader_StopsWhenTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	lr := file_ops.NewLineReader(nil)
+	_, err := lr.Next(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Error(t, lr.Seek(file_ops.Position{Line: 1, Offset: 3}))
+}
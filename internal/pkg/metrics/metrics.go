@@ -0,0 +1,301 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var DefaultBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+type collector interface {
+	describe() (name, help, kind string)
+	write(w io.Writer) error
+}
+
+// Registry collects metrics and renders them in the Prometheus text exposition format.
+type Registry struct {
+	mtx        sync.RWMutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+func (r *Registry) register(c collector) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	name, _, _ := c.describe()
+	if _, ok := r.collectors[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.collectors[name] = c
+}
+
+func (r *Registry) Write(w io.Writer) error {
+	r.mtx.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	r.mtx.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.mtx.RLock()
+		c := r.collectors[name]
+		r.mtx.RUnlock()
+
+		_, help, kind := c.describe()
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind); err != nil {
+			return err
+		}
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
+
+type vec struct {
+	mtx        sync.Mutex
+	name       string
+	help       string
+	labelNames []string
+}
+
+func (v *vec) describe(kind string) (string, string, string) {
+	return v.name, v.help, kind
+}
+
+func (v *vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (v *vec) labels(key string, extra ...string) string {
+	pairs := make([]string, 0, len(v.labelNames)+1)
+	if len(v.labelNames) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, v.labelNames[i], escapeLabel(value)))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+type Counter struct {
+	vec
+	values map[string]float64
+}
+
+func NewCounter(r *Registry, name, help string, labelNames ...string) *Counter {
+	c := &Counter{vec: vec{name: name, help: help, labelNames: labelNames}, values: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.name))
+	}
+
+	key := c.key(labelValues)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.values[key] += value
+}
+
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) describe() (string, string, string) {
+	return c.vec.describe("counter")
+}
+
+func (c *Counter) write(w io.Writer) error {
+	return writeSamples(&c.vec, c.values, w)
+}
+
+type Gauge struct {
+	vec
+	values map[string]float64
+}
+
+func NewGauge(r *Registry, name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{vec: vec{name: name, help: help, labelNames: labelNames}, values: make(map[string]float64)}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	key := g.key(labelValues)
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.values[key] = value
+}
+
+func (g *Gauge) Value(labelValues ...string) float64 {
+	key := g.key(labelValues)
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.values[key]
+}
+
+func (g *Gauge) describe() (string, string, string) {
+	return g.vec.describe("gauge")
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	return writeSamples(&g.vec, g.values, w)
+}
+
+type histogramValue struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type Histogram struct {
+	vec
+	buckets []float64
+	values  map[string]*histogramValue
+}
+
+func NewHistogram(r *Registry, name, help string, buckets []float64, labelNames ...string) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	h := &Histogram{vec: vec{name: name, help: help, labelNames: labelNames}, buckets: sorted, values: make(map[string]*histogramValue)}
+	r.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+
+	for i, upper := range h.buckets {
+		if value <= upper {
+			hv.counts[i]++
+		}
+	}
+	hv.sum += value
+	hv.count++
+}
+
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if hv, ok := h.values[key]; ok {
+		return hv.count
+	}
+	return 0
+}
+
+func (h *Histogram) describe() (string, string, string) {
+	return h.vec.describe("histogram")
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		for i, upper := range h.buckets {
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(key, "le", formatFloat(upper)), hv.counts[i]); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(key, "le", "+Inf"), hv.count); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.name, h.labels(key), formatFloat(hv.sum), h.name, h.labels(key), hv.count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeSamples(v *vec, values map[string]float64, w io.Writer) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for _, key := range sortedKeys(values) {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", v.name, v.labels(key), formatFloat(values[key])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func escapeHelp(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"bytes"
+	"github.com/hiteshpattanayak-tw/SupplyDemandLedger/internal/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry_WritesPrometheusTextFormat(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	orders := metrics.NewCounter(registry, "orders_total", "Orders accepted.", "product", "side")
+	depth := metrics.NewGauge(registry, "book_depth", "Resting orders.", "product")
+	latency := metrics.NewHistogram(registry, "append_seconds", "Append latency.", []float64{0.1, 1})
+
+	orders.Inc("tomato", "supply")
+	orders.Add(2, "tomato", "demand")
+	depth.Set(3, `say "hi"`)
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(2)
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.Write(&buf))
+
+	expected := `# HELP append_seconds Append latency.
+# TYPE append_seconds histogram
+append_seconds_bucket{le="0.1"} 1
+append_seconds_bucket{le="1"} 2
+append_seconds_bucket{le="+Inf"} 3
+append_seconds_sum 2.55
+append_seconds_count 3
+# HELP book_depth Resting orders.
+# TYPE book_depth gauge
+book_depth{product="say \"hi\""} 3
+# HELP orders_total Orders accepted.
+# TYPE orders_total counter
+orders_total{product="tomato",side="demand"} 2
+orders_total{product="tomato",side="supply"} 1
+`
+	assert.Equal(t, expected, buf.String())
+	assert.Equal(t, float64(2), orders.Value("tomato", "demand"))
+	assert.Equal(t, uint64(3), latency.Count())
+}
+
+func TestRegistry_ServesMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.NewCounter(registry, "trades_total", "Trades executed.").Inc()
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	assert.Contains(t, rec.Body.String(), "trades_total 1\n")
+}
+
+func TestRegistry_PanicsOnDuplicateOrWrongLabels(t *testing.T) {
+	registry := metrics.NewRegistry()
+	c := metrics.NewCounter(registry, "orders_total", "Orders accepted.", "product")
+
+	assert.Panics(t, func() { metrics.NewCounter(registry, "orders_total", "Orders accepted.") })
+	assert.Panics(t, func() { c.Inc() })
+	assert.Panics(t, func() { c.Add(-1, "tomato") })
+}